@@ -181,7 +181,7 @@ func TestDeleteFile(ctx context.Context, fileID string) (testRes consts.SingleAp
 		res, err = invoker.SdkMgr.DeleteFile(ctx, params)
 		mgrErrHandler(err, string(res.Response().Body()))
 	} else {
-		err = invoker.Services.AwosService.Remove(file.Guid)
+		err = invoker.Services.Storage.Remove(file.Guid)
 		if err != nil {
 			errHandler(err)
 			fmt.Println(fmt.Sprintf("awos remove file failed, guid: %s, err:%e", file.Guid, err))
@@ -90,7 +90,7 @@ func TestBase(ctx context.Context, fileTypes []sdk.FileType) (testResMap map[sdk
 		tmpBaseRes.CreateFileRes = testCreateRes
 		tmpBaseRes.CreateCopyRes = TestCreateFileCopy(ctx, fileId)
 		// Create a placeholder file for previewing
-		err = invoker.Services.AwosService.Save(fileId, []byte{})
+		err = invoker.Services.Storage.Save(fileId, []byte{})
 		if err != nil {
 			elog.Error("TestBase TestCreatePreview", l.S("error: ", err.Error()))
 			tmpBaseRes.CreatePreviewRes = consts.SingleApiTestRes{
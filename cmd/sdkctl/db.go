@@ -0,0 +1,117 @@
+package sdkctl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/spf13/cobra"
+
+	"sdk-demo-go/cmd"
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/migrations"
+	"sdk-demo-go/pkg/models/db"
+)
+
+var (
+	rollbackSteps int
+	dedupeBatch   int
+)
+
+var DBCtl = &cobra.Command{
+	Use:   "db",
+	Short: "Database schema management",
+	Long:  `Database schema management, works the same against MySQL and SQLite`,
+	PersistentPreRun: func(c *cobra.Command, args []string) {
+		// cobra only runs the closest PersistentPreRun, so load the config explicitly
+		cmd.RootCommand.PersistentPreRun(c, args)
+		// Only open the connection; the schema may not exist yet
+		if err := invoker.OpenDB(); err != nil {
+			elog.Panic("open db failed", l.E(err))
+		}
+	},
+}
+
+var DBMigrate = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending migrations",
+	Long:  `Apply every pending schema migration in version order`,
+	Run: func(c *cobra.Command, args []string) {
+		applied, err := migrations.Up(invoker.DB)
+		for _, m := range applied {
+			fmt.Printf("applied  %04d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			elog.Panic("migrate failed", l.E(err))
+		}
+		if len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+	},
+}
+
+var DBRollback = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll back applied migrations",
+	Long:  `Roll back the latest applied migrations, accepts 1 parameter: number of steps (default 1)`,
+	Run: func(c *cobra.Command, args []string) {
+		rolledBack, err := migrations.Down(invoker.DB, rollbackSteps)
+		for _, m := range rolledBack {
+			fmt.Printf("reverted %04d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			elog.Panic("rollback failed", l.E(err))
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("nothing to roll back")
+		}
+	},
+}
+
+var DBStatus = &cobra.Command{
+	Use:   "status",
+	Short: "Show migration status",
+	Long:  `Show every known migration and when it was applied`,
+	Run: func(c *cobra.Command, args []string) {
+		statuses, err := migrations.GetStatus(invoker.DB)
+		if err != nil {
+			elog.Panic("get migration status failed", l.E(err))
+		}
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = time.Unix(s.AppliedAt, 0).Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d %-30s %s\n", s.Version, s.Name, appliedAt)
+		}
+	},
+}
+
+var DBDedupeEvents = &cobra.Command{
+	Use:   "dedupe-events",
+	Short: "Collapse duplicate callback events",
+	Long:  `Backfill the dedupe key of events stored before deduplication, the oldest copy of a delivery is kept and its replays are removed`,
+	Run: func(c *cobra.Command, args []string) {
+		updated, removed, err := db.DedupeEvents(invoker.DB, dedupeBatch)
+		fmt.Printf("keyed    %d events\n", updated)
+		fmt.Printf("removed  %d duplicates\n", removed)
+		if err != nil {
+			elog.Panic("dedupe events failed", l.E(err))
+		}
+	},
+}
+
+func init() {
+	SdkCtl.AddCommand(DBCtl)
+
+	DBCtl.AddCommand(DBMigrate)
+
+	DBRollback.Flags().IntVar(&rollbackSteps, "steps", 1, "Number of migrations to roll back")
+	DBCtl.AddCommand(DBRollback)
+
+	DBCtl.AddCommand(DBStatus)
+
+	DBDedupeEvents.Flags().IntVar(&dedupeBatch, "batch", 500, "Number of events read per batch")
+	DBCtl.AddCommand(DBDedupeEvents)
+}
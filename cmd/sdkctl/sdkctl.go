@@ -4,7 +4,8 @@ import (
 	"context"
 	"encoding/json"
 
-	"github.com/ego-component/egorm"
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/elog"
 	sdk "github.com/shimo-open/sdk-kit-go"
 	sdkapi "github.com/shimo-open/sdk-kit-go/api"
 	"github.com/spf13/cast"
@@ -49,9 +50,13 @@ var SdkCtl = &cobra.Command{
 	Use:   "sdk-ctl",
 	Short: "sdk demo command-line tool",
 	Long:  `sdk demo command-line tool`,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRun: func(c *cobra.Command, args []string) {
+		// cobra only runs the closest PersistentPreRun, so load the config before opening the configured database
+		cmd.RootCommand.PersistentPreRun(c, args)
 		// ShimoSDK = shimo.InitTest()
-		invoker.DB = egorm.Load("mysql").Build()
+		if err := invoker.InitDB(); err != nil {
+			elog.Panic("init db failed", l.E(err))
+		}
 		initParams()
 		invoker.InitShimo()
 	},
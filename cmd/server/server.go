@@ -9,7 +9,9 @@ import (
 
 	"sdk-demo-go/cmd"
 	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/jobs"
 	"sdk-demo-go/pkg/server/http"
+	"sdk-demo-go/pkg/tasks"
 )
 
 // CmdRun is the cobra command for running the HTTP server
@@ -28,7 +30,7 @@ func init() {
 // CmdFunc is the entry point for the server command
 func CmdFunc(c *cobra.Command, args []string) {
 	e := ego.New(ego.WithDisableFlagConfig(true))
-	e.Invoker(invoker.Init)
+	e.Invoker(invoker.Init, tasks.Start, jobs.Start)
 	if err := e.Serve(
 		egovernor.Load("server.governor").Build(),
 		http.ServeHTTP(),
@@ -0,0 +1,188 @@
+// Package access computes the effective permissions of a user on a file from the layered sources
+// that can grant them, and records which layer decided every flag so the result can be explained.
+package access
+
+import "sort"
+
+// FlagFormFillable is the permission to fill in a form, granted to everyone on forms
+const FlagFormFillable = "formFillable"
+
+// Sources of a permission flag, from the highest precedence to the lowest
+const (
+	// SourceForm is the form rule: every user, anonymous ones included, may fill in a form
+	SourceForm = "form"
+	// SourceAdmin is the full permission set of the app itself (admin callbacks)
+	SourceAdmin = "admin"
+	// SourceCreator is the full permission set of the file creator
+	SourceCreator = "creator"
+	// SourceShareLink is the share link the user opened the file with, it only adds flags
+	SourceShareLink = "shareLink"
+	// SourceAnonymous is the anonymous rule: anonymous users get nothing but the form rule
+	SourceAnonymous = "anonymous"
+	// SourceDirect is a grant of the user on the file itself, it overrides the grants of the parent folders
+	SourceDirect = "direct"
+	// SourceGroup is a grant of a team or department of the user on the file itself
+	SourceGroup = "group"
+	// SourceInherited is a grant on a parent folder, the nearest folder wins
+	SourceInherited = "inherited"
+	// SourceAppDefault is the app-wide default for signed-in users
+	SourceAppDefault = "appDefault"
+	// SourceNone means no layer grants the flag
+	SourceNone = "none"
+)
+
+// Layer is a grant of the user, or of one of its groups, on the file or on a parent folder
+type Layer struct {
+	// FolderId is the GUID of the folder, empty for grants on the file itself
+	FolderId string
+	// Group is the team or department holding the grant (e.g. "department:12"), empty for user grants
+	Group string
+	// Permissions are the flags set by the grant
+	Permissions map[string]bool
+}
+
+// Input holds every layer that may decide the permissions of a user on a file
+type Input struct {
+	// Full is the permission set of the creator and of the app, its flags are always reported
+	Full map[string]bool
+	// Admin is set when the app itself asks, without a user
+	Admin bool
+	// Creator is set when the user created the file
+	Creator bool
+	// Anonymous is set when the user is not signed in
+	Anonymous bool
+	// Form is set when the file is a form
+	Form bool
+	// Link are the flags of the share link the user opened the file or a parent folder with
+	Link map[string]bool
+	// Direct is the grant of the user on the file, nil when there is none
+	Direct map[string]bool
+	// Groups are the grants of the teams and departments of the user on the file
+	Groups []Layer
+	// Inherited are the grants of the user and of its groups on the parent folders, nearest folder
+	// first, the grants on one folder next to each other
+	Inherited []Layer
+	// AppDefault are the flags granted to every signed-in user
+	AppDefault map[string]bool
+}
+
+// Grant is the effective value of a flag and the layer that decided it
+type Grant struct {
+	// Value is the effective value of the flag
+	Value bool `json:"value"`
+	// Source is the layer that decided the value
+	Source string `json:"source"`
+	// FolderId is the folder the flag is inherited from, only set for SourceInherited
+	FolderId string `json:"folderId,omitempty"`
+	// Group is the team or department whose grant decided the flag
+	Group string `json:"group,omitempty"`
+}
+
+// Result is the outcome of Resolve
+type Result struct {
+	// Permissions are the effective flags
+	Permissions map[string]bool `json:"permissions"`
+	// Explain tells, for every flag, which layer decided it
+	Explain map[string]Grant `json:"explain"`
+}
+
+// Resolve computes the effective permissions, every flag is decided by the first layer that sets it:
+// form rule, admin, creator, share link (granted flags only), anonymous rule, grants on the file, grants on the parent folders (nearest
+// folder first) and app default. A flag no layer sets is false.
+// Among the grants on one file or folder, the grant of the user wins over the group grants, and a
+// flag is granted when any group grants it.
+func Resolve(in *Input) *Result {
+	res := &Result{
+		Permissions: map[string]bool{},
+		Explain:     map[string]Grant{},
+	}
+	for _, flag := range in.flags() {
+		g := in.resolve(flag)
+		res.Permissions[flag] = g.Value
+		res.Explain[flag] = g
+	}
+	return res
+}
+
+func (in *Input) resolve(flag string) Grant {
+	switch {
+	case in.Form && flag == FlagFormFillable:
+		return Grant{Value: true, Source: SourceForm}
+	case in.Admin:
+		return Grant{Value: in.Full[flag], Source: SourceAdmin}
+	case in.Creator:
+		return Grant{Value: in.Full[flag], Source: SourceCreator}
+	case in.Link[flag]:
+		return Grant{Value: true, Source: SourceShareLink}
+	case in.Anonymous:
+		return Grant{Source: SourceAnonymous}
+	}
+	if layer, v, ok := pick(append([]Layer{{Permissions: in.Direct}}, in.Groups...), flag); ok {
+		if layer.Group == "" {
+			return Grant{Value: v, Source: SourceDirect}
+		}
+		return Grant{Value: v, Source: SourceGroup, Group: layer.Group}
+	}
+	for start := 0; start < len(in.Inherited); {
+		end := start + 1
+		for end < len(in.Inherited) && in.Inherited[end].FolderId == in.Inherited[start].FolderId {
+			end++
+		}
+		if layer, v, ok := pick(in.Inherited[start:end], flag); ok {
+			return Grant{Value: v, Source: SourceInherited, FolderId: layer.FolderId, Group: layer.Group}
+		}
+		start = end
+	}
+	if v, ok := in.AppDefault[flag]; ok {
+		return Grant{Value: v, Source: SourceAppDefault}
+	}
+	return Grant{Source: SourceNone}
+}
+
+// pick decides a flag from the grants on one file or folder: the grant of the user wins,
+// otherwise the first group granting the flag, otherwise the first group denying it
+func pick(layers []Layer, flag string) (layer Layer, value bool, ok bool) {
+	for _, l := range layers {
+		v, set := l.Permissions[flag]
+		if !set {
+			continue
+		}
+		if l.Group == "" {
+			return l, v, true
+		}
+		if !ok || (v && !value) {
+			layer, value, ok = l, v, true
+		}
+	}
+	return
+}
+
+// flags returns every flag any layer knows about, sorted
+func (in *Input) flags() []string {
+	set := map[string]bool{}
+	add := func(p map[string]bool) {
+		for k := range p {
+			set[k] = true
+		}
+	}
+	add(in.Full)
+	add(in.Link)
+	add(in.Direct)
+	for _, layer := range in.Groups {
+		add(layer.Permissions)
+	}
+	for _, layer := range in.Inherited {
+		add(layer.Permissions)
+	}
+	add(in.AppDefault)
+	if in.Form {
+		set[FlagFormFillable] = true
+	}
+
+	flags := make([]string, 0, len(set))
+	for k := range set {
+		flags = append(flags, k)
+	}
+	sort.Strings(flags)
+	return flags
+}
@@ -0,0 +1,108 @@
+package access
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	full := map[string]bool{"readable": true, "editable": true, "manageable": true}
+	tests := []struct {
+		name string
+		in   Input
+		want map[string]Grant
+	}{
+		{
+			name: "creator",
+			in:   Input{Full: full, Creator: true, Direct: map[string]bool{"editable": false}},
+			want: map[string]Grant{
+				"readable":   {Value: true, Source: SourceCreator},
+				"editable":   {Value: true, Source: SourceCreator},
+				"manageable": {Value: true, Source: SourceCreator},
+			},
+		},
+		{
+			name: "direct overrides inherited",
+			in: Input{
+				Full:   full,
+				Direct: map[string]bool{"editable": false},
+				Inherited: []Layer{
+					{FolderId: "parent", Permissions: map[string]bool{"editable": true}},
+					{FolderId: "root", Permissions: map[string]bool{"readable": true, "manageable": true}},
+				},
+			},
+			want: map[string]Grant{
+				"readable":   {Value: true, Source: SourceInherited, FolderId: "root"},
+				"editable":   {Value: false, Source: SourceDirect},
+				"manageable": {Value: true, Source: SourceInherited, FolderId: "root"},
+			},
+		},
+		{
+			name: "group grants",
+			in: Input{
+				Full: full,
+				Groups: []Layer{
+					{Group: "team:1", Permissions: map[string]bool{"readable": true, "editable": false}},
+					{Group: "department:2", Permissions: map[string]bool{"editable": true}},
+				},
+				Inherited: []Layer{
+					{FolderId: "parent", Permissions: map[string]bool{"manageable": false}},
+					{FolderId: "parent", Group: "team:1", Permissions: map[string]bool{"manageable": true}},
+				},
+			},
+			want: map[string]Grant{
+				"readable":   {Value: true, Source: SourceGroup, Group: "team:1"},
+				"editable":   {Value: true, Source: SourceGroup, Group: "department:2"},
+				"manageable": {Value: false, Source: SourceInherited, FolderId: "parent"},
+			},
+		},
+		{
+			name: "app default",
+			in:   Input{Full: full, AppDefault: map[string]bool{"readable": true}},
+			want: map[string]Grant{
+				"readable":   {Value: true, Source: SourceAppDefault},
+				"editable":   {Source: SourceNone},
+				"manageable": {Source: SourceNone},
+			},
+		},
+		{
+			name: "anonymous form",
+			in:   Input{Full: full, Anonymous: true, Form: true, AppDefault: map[string]bool{"readable": true}},
+			want: map[string]Grant{
+				"readable":       {Source: SourceAnonymous},
+				"editable":       {Source: SourceAnonymous},
+				"manageable":     {Source: SourceAnonymous},
+				FlagFormFillable: {Value: true, Source: SourceForm},
+			},
+		},
+		{
+			name: "anonymous share link",
+			in:   Input{Full: full, Anonymous: true, Link: map[string]bool{"readable": true, "editable": false}},
+			want: map[string]Grant{
+				"readable":   {Value: true, Source: SourceShareLink},
+				"editable":   {Source: SourceAnonymous},
+				"manageable": {Source: SourceAnonymous},
+			},
+		},
+		{
+			name: "admin",
+			in:   Input{Full: map[string]bool{"readable": true}, Admin: true},
+			want: map[string]Grant{
+				"readable": {Value: true, Source: SourceAdmin},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resolve(&tt.in)
+			if !reflect.DeepEqual(got.Explain, tt.want) {
+				t.Errorf("Resolve() explain = %v, want %v", got.Explain, tt.want)
+			}
+			for flag, g := range tt.want {
+				if got.Permissions[flag] != g.Value {
+					t.Errorf("Resolve() %s = %v, want %v", flag, got.Permissions[flag], g.Value)
+				}
+			}
+		})
+	}
+}
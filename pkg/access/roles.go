@@ -0,0 +1,108 @@
+package access
+
+import "sort"
+
+// Built-in role templates, the UI offers them as a role picker instead of the raw permission flags
+const (
+	// RoleViewer can read the file
+	RoleViewer = "viewer"
+	// RoleCommenter can read and comment
+	RoleCommenter = "commenter"
+	// RoleEditor can do everything but manage the file
+	RoleEditor = "editor"
+	// RoleManager can do everything, including managing the collaborators
+	RoleManager = "manager"
+	// RoleFormFiller can only fill in a form
+	RoleFormFiller = "form-filler"
+	// RoleCustom is reported for permissions that match no role template
+	RoleCustom = "custom"
+)
+
+// builtinRoles lists the built-in role templates in the order they are offered
+var builtinRoles = []string{RoleViewer, RoleCommenter, RoleEditor, RoleManager, RoleFormFiller}
+
+// DefaultRoles returns the built-in role templates, the flags each role grants
+func DefaultRoles() map[string][]string {
+	editor := []string{
+		"readable", "commentable", "editable", "exportable", "copyable", "lockable", "unlockable",
+		"attachmentCopyable", "attachmentPreviewable", "attachmentDownloadable", "copyablePasteClipboard",
+		"cutable", "imageDownloadable",
+	}
+	return map[string][]string{
+		RoleViewer:     {"readable"},
+		RoleCommenter:  {"readable", "commentable"},
+		RoleEditor:     editor,
+		RoleManager:    append(append([]string{}, editor...), "manageable"),
+		RoleFormFiller: {FlagFormFillable},
+	}
+}
+
+// RoleFlags returns the flags a role template grants. The flags checked by the demo itself are also set
+// when the template does not grant them, so that giving a collaborator a role overwrites them
+func RoleFlags(template []string) map[string]bool {
+	flags := map[string]bool{FlagFormFillable: false}
+	for _, f := range template {
+		flags[f] = true
+	}
+	return flags
+}
+
+// MergeLocalFlags copies to base the flags checked by the demo itself that src sets,
+// the SDK flags are merged by the caller
+func MergeLocalFlags(base, src map[string]bool) {
+	if v, ok := src[FlagFormFillable]; ok {
+		base[FlagFormFillable] = v
+	}
+}
+
+// RoleNames returns the names of the roles, the built-in ones first and the others sorted
+func RoleNames(roles map[string]map[string]bool) []string {
+	names := make([]string, 0, len(roles))
+	for _, name := range builtinRoles {
+		if _, ok := roles[name]; ok {
+			names = append(names, name)
+		}
+	}
+	var others []string
+	for name := range roles {
+		if !isBuiltinRole(name) {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// MatchRole returns the first role, in RoleNames order, granting exactly the flags granted by p,
+// RoleCustom when there is none. Flags missing from a map count as false
+func MatchRole(roles map[string]map[string]bool, p map[string]bool) string {
+	for _, name := range RoleNames(roles) {
+		if sameFlags(roles[name], p) {
+			return name
+		}
+	}
+	return RoleCustom
+}
+
+func sameFlags(a, b map[string]bool) bool {
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	for k, v := range b {
+		if a[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func isBuiltinRole(name string) bool {
+	for _, r := range builtinRoles {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package access
+
+import "testing"
+
+func TestMatchRole(t *testing.T) {
+	roles := map[string]map[string]bool{
+		RoleViewer:    {"readable": true, "editable": false},
+		RoleCommenter: {"readable": true, "commentable": true},
+		"auditor":     {"readable": true},
+	}
+	tests := []struct {
+		name string
+		p    map[string]bool
+		want string
+	}{
+		{name: "exact", p: map[string]bool{"readable": true, "commentable": true}, want: RoleCommenter},
+		{name: "missing flags are false", p: map[string]bool{"readable": true}, want: RoleViewer},
+		{name: "false flags ignored", p: map[string]bool{"readable": true, "commentable": false, "manageable": false}, want: RoleViewer},
+		{name: "custom", p: map[string]bool{"readable": true, "editable": true}, want: RoleCustom},
+		{name: "nothing granted", p: map[string]bool{}, want: RoleCustom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchRole(roles, tt.p); got != tt.want {
+				t.Errorf("MatchRole() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoleNames(t *testing.T) {
+	roles := map[string]map[string]bool{"zeta": nil, RoleEditor: nil, "alpha": nil, RoleViewer: nil}
+	got := RoleNames(roles)
+	want := []string{RoleViewer, RoleEditor, "alpha", "zeta"}
+	if len(got) != len(want) {
+		t.Fatalf("RoleNames() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("RoleNames() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRoleChange(t *testing.T) {
+	roles := map[string]map[string]bool{}
+	for name, template := range DefaultRoles() {
+		roles[name] = RoleFlags(template)
+	}
+	tests := []struct {
+		name string
+		from string
+		to   string
+	}{
+		{name: "form-filler to viewer", from: RoleFormFiller, to: RoleViewer},
+		{name: "form-filler to editor", from: RoleFormFiller, to: RoleEditor},
+		{name: "form-filler to manager", from: RoleFormFiller, to: RoleManager},
+		{name: "viewer to form-filler", from: RoleViewer, to: RoleFormFiller},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := map[string]bool{}
+			for k, v := range roles[tt.from] {
+				stored[k] = v
+			}
+			// The SDK flags are replaced by the caller, only the local ones are merged
+			for k := range stored {
+				if k != FlagFormFillable {
+					delete(stored, k)
+				}
+			}
+			for k, v := range roles[tt.to] {
+				if k != FlagFormFillable {
+					stored[k] = v
+				}
+			}
+			MergeLocalFlags(stored, roles[tt.to])
+			if got := MatchRole(roles, stored); got != tt.to {
+				t.Errorf("MatchRole() = %v, want %v", got, tt.to)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Package audit computes the before/after diff stored with the audit log of mutating API calls.
+// Snapshots are compared through their JSON representation, so any value the API returns can be
+// used as a snapshot.
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Change is the old and new value of a changed field, nil when the field is absent
+type Change struct {
+	// From is the value before the request
+	From interface{} `json:"from"`
+	// To is the value after the request
+	To interface{} `json:"to"`
+}
+
+// Diff returns the top-level fields that differ between two snapshots
+// A nil snapshot stands for a created or deleted target, every field of the other one is a change;
+// snapshots that are not JSON objects are reported under the empty key
+func Diff(before, after interface{}) (map[string]Change, error) {
+	b, err := normalize(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := normalize(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]Change{}
+	bm, bok := b.(map[string]interface{})
+	am, aok := a.(map[string]interface{})
+	if (!bok && b != nil) || (!aok && a != nil) {
+		if !reflect.DeepEqual(a, b) {
+			changes[""] = Change{From: b, To: a}
+		}
+		return changes, nil
+	}
+	for k, v := range bm {
+		if w, ok := am[k]; !ok || !reflect.DeepEqual(v, w) {
+			changes[k] = Change{From: v, To: am[k]}
+		}
+	}
+	for k, w := range am {
+		if _, ok := bm[k]; !ok {
+			changes[k] = Change{To: w}
+		}
+	}
+	return changes, nil
+}
+
+// normalize round-trips a snapshot through JSON so structs, maps and raw JSON compare alike
+func normalize(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, ok := v.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var out interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
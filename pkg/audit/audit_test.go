@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	type file struct {
+		Name string `json:"name"`
+		Size int    `json:"size"`
+	}
+	tests := []struct {
+		name   string
+		before interface{}
+		after  interface{}
+		want   map[string]Change
+	}{
+		{
+			name:   "changed field",
+			before: file{Name: "a", Size: 1},
+			after:  file{Name: "b", Size: 1},
+			want:   map[string]Change{"name": {From: "a", To: "b"}},
+		},
+		{
+			name:   "unchanged",
+			before: map[string]interface{}{"name": "a"},
+			after:  file{Name: "a"},
+			want:   map[string]Change{"size": {To: float64(0)}},
+		},
+		{
+			name:   "created",
+			before: nil,
+			after:  map[string]string{"name": "a"},
+			want:   map[string]Change{"name": {To: "a"}},
+		},
+		{
+			name:   "deleted",
+			before: map[string]string{"name": "a"},
+			after:  nil,
+			want:   map[string]Change{"name": {From: "a"}},
+		},
+		{
+			name:   "nested maps compare deeply",
+			before: map[string]interface{}{"1": map[string]bool{"editable": true}},
+			after:  map[string]interface{}{"1": map[string]bool{"editable": true}, "2": map[string]bool{"readable": true}},
+			want:   map[string]Change{"2": {To: map[string]interface{}{"readable": true}}},
+		},
+		{
+			name:   "scalar snapshots",
+			before: 1,
+			after:  2,
+			want:   map[string]Change{"": {From: float64(1), To: float64(2)}},
+		},
+		{
+			name:   "both nil",
+			before: nil,
+			after:  nil,
+			want:   map[string]Change{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+// Package events decodes the callback events pushed by the Shimo SDK into typed payloads.
+// Every event kind registers a factory under its Shimo-Sdk-Event header value; kinds
+// without a registration are decoded as Unknown so they are still stored and indexed.
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrMalformed is returned for payloads that are not valid JSON or fail validation
+var ErrMalformed = errors.New("malformed event payload")
+
+// Payload is a decoded event
+type Payload interface {
+	// Common returns the fields shared by every event
+	Common() *Base
+	// ActorId returns the ID of the user who triggered the event
+	ActorId() string
+	// FileIds returns the GUIDs of the files the event refers to
+	FileIds() []string
+	// UserIds returns the IDs of the users the event involves, the actor first
+	UserIds() []string
+	// Validate reports missing or inconsistent fields
+	Validate() error
+}
+
+var registry = map[string]func() Payload{}
+
+// Register maps an event type to the factory of its payload, the payload is filled with json.Unmarshal
+func Register(eventType string, factory func() Payload) {
+	if _, ok := registry[eventType]; ok {
+		panic("duplicate event type " + eventType)
+	}
+	registry[eventType] = factory
+}
+
+// Decode decodes and validates the raw payload of an incoming event
+// Unregistered event types are decoded as Unknown
+func Decode(eventType string, raw []byte) (Payload, error) {
+	p, err := Parse(eventType, raw)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformed, err)
+	}
+	return p, nil
+}
+
+// Parse decodes the raw payload without validating it, for events that have already been stored
+func Parse(eventType string, raw []byte) (Payload, error) {
+	factory, ok := registry[eventType]
+	if !ok {
+		factory = func() Payload { return &Unknown{} }
+	}
+	p := factory()
+	if err := json.Unmarshal(raw, p); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformed, err)
+	}
+	return p, nil
+}
+
+// ID is a user or file ID, the SDK sends some of them as numbers
+type ID string
+
+// UnmarshalJSON accepts a string or a number
+func (id *ID) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*id = ""
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = ID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid id %s", data)
+	}
+	if i, err := n.Int64(); err == nil {
+		*id = ID(strconv.FormatInt(i, 10))
+		return nil
+	}
+	*id = ID(n.String())
+	return nil
+}
+
+// IDList is a list of IDs, a single ID is accepted as a list of one
+type IDList []ID
+
+// UnmarshalJSON accepts an array of IDs or a single ID
+func (l *IDList) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		var ids []ID
+		if err := json.Unmarshal(data, &ids); err != nil {
+			return err
+		}
+		*l = ids
+		return nil
+	}
+	var id ID
+	if err := json.Unmarshal(data, &id); err != nil {
+		return err
+	}
+	*l = nil
+	if id != "" {
+		*l = IDList{id}
+	}
+	return nil
+}
+
+// Base holds the fields shared by every event
+type Base struct {
+	// Kind is the event category
+	Kind string `json:"kind"`
+	// Type is the event sub type
+	Type string `json:"type"`
+	// Action is the event action (create/update/...)
+	Action string `json:"action"`
+	// FileId is the GUID of the file the event happened on
+	FileId ID `json:"fileId"`
+	// UserId is the ID of the user who triggered the event
+	UserId ID `json:"userId"`
+}
+
+// Common returns the shared fields
+func (b *Base) Common() *Base {
+	return b
+}
+
+// ActorId returns the event user
+func (b *Base) ActorId() string {
+	return string(b.UserId)
+}
+
+// FileIds returns the event file
+func (b *Base) FileIds() []string {
+	return appendIds(nil, b.FileId)
+}
+
+// UserIds returns the event user
+func (b *Base) UserIds() []string {
+	return appendIds(nil, b.UserId)
+}
+
+// Validate accepts any payload
+func (b *Base) Validate() error {
+	return nil
+}
+
+// requireFile reports a missing file ID
+func (b *Base) requireFile() error {
+	if b.FileId == "" {
+		return errors.New("missing fileId")
+	}
+	return nil
+}
+
+// Unknown is the payload of event types without a registration, only the shared fields are read
+type Unknown struct {
+	Base
+}
+
+// appendIds appends the non-empty IDs as strings
+func appendIds(dst []string, ids ...ID) []string {
+	for _, id := range ids {
+		if id != "" {
+			dst = append(dst, string(id))
+		}
+	}
+	return dst
+}
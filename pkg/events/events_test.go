@@ -0,0 +1,116 @@
+package events
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		raw       string
+		actor     string
+		fileIds   []string
+		userIds   []string
+	}{
+		{
+			name:      "comment create",
+			eventType: "Comment",
+			raw:       `{"action":"create","fileId":"f1","userId":"1","comment":{"guid":"c","userIds":["2","3"]}}`,
+			actor:     "1",
+			fileIds:   []string{"f1"},
+			userIds:   []string{"1", "2", "3"},
+		},
+		{
+			name:      "comment update ignores addressees",
+			eventType: "Comment",
+			raw:       `{"action":"update","fileId":"f1","userId":"1","comment":{"userIds":["2"]}}`,
+			actor:     "1",
+			fileIds:   []string{"f1"},
+			userIds:   []string{"1"},
+		},
+		{
+			name:      "mention in body",
+			eventType: "MentionAt",
+			raw:       `{"type":"mention_at","fileId":"f1","userId":1,"mentionAt":{"userId":"4"}}`,
+			actor:     "1",
+			fileIds:   []string{"f1"},
+			userIds:   []string{"1", "4"},
+		},
+		{
+			name:      "mention in discussion",
+			eventType: "MentionAt",
+			raw:       `{"type":"discussion","fileId":"f1","userId":"1","discussion":{"userIds":["5"]}}`,
+			actor:     "1",
+			fileIds:   []string{"f1"},
+			userIds:   []string{"1", "5"},
+		},
+		{
+			name:      "date mention create",
+			eventType: "DateMention",
+			raw:       `{"action":"create","userId":"1","createData":{"fileId":"f2","authorId":"1","remindUserIds":["6"]}}`,
+			actor:     "1",
+			fileIds:   []string{"f2"},
+			userIds:   []string{"1", "1", "6"},
+		},
+		{
+			name:      "auto mention",
+			eventType: "FileContent",
+			raw:       `{"type":"auto_mention","fileId":"f1","autoMention":{"editorProviderId":7}}`,
+			actor:     "7",
+			fileIds:   []string{"f1"},
+			userIds:   []string{"7"},
+		},
+		{
+			name:      "unknown type passthrough",
+			eventType: "SomethingNew",
+			raw:       `{"fileId":"f1","userId":"1","extra":{"a":1}}`,
+			actor:     "1",
+			fileIds:   []string{"f1"},
+			userIds:   []string{"1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Decode(tt.eventType, []byte(tt.raw))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got := p.ActorId(); got != tt.actor {
+				t.Errorf("ActorId() = %q, want %q", got, tt.actor)
+			}
+			if got := p.FileIds(); !reflect.DeepEqual(got, tt.fileIds) {
+				t.Errorf("FileIds() = %v, want %v", got, tt.fileIds)
+			}
+			if got := p.UserIds(); !reflect.DeepEqual(got, tt.userIds) {
+				t.Errorf("UserIds() = %v, want %v", got, tt.userIds)
+			}
+		})
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		raw       string
+	}{
+		{"invalid json", "Comment", `{"fileId":`},
+		{"invalid id", "Comment", `{"fileId":"f1","userId":{}}`},
+		{"missing file", "Discussion", `{"userId":"1"}`},
+		{"comment create without comment", "Comment", `{"action":"create","fileId":"f1"}`},
+		{"mention without target", "MentionAt", `{"type":"comment","fileId":"f1"}`},
+		{"date mention without data", "DateMention", `{"action":"update","fileId":"f1"}`},
+		{"unknown type invalid json", "SomethingNew", `[`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.eventType, []byte(tt.raw))
+			if !errors.Is(err, ErrMalformed) {
+				t.Errorf("Decode() error = %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package events
+
+import (
+	"errors"
+)
+
+func init() {
+	Register("Comment", func() Payload { return &Comment{} })
+	Register("Discussion", func() Payload { return &Discussion{} })
+	Register("MentionAt", func() Payload { return &MentionAt{} })
+	Register("DateMention", func() Payload { return &DateMention{} })
+	Register("FileContent", func() Payload { return &FileContent{} })
+	Register("Collaborator", func() Payload { return &Collaborator{} })
+}
+
+// CommentData is a comment carried by Comment and MentionAt events
+type CommentData struct {
+	Guid             string `json:"guid"`
+	Content          string `json:"content"`
+	UserIds          IDList `json:"userIds"`
+	SelectionGuid    string `json:"selectionGuid"`
+	SelectionContent string `json:"selectionContent"`
+}
+
+// DiscussionData is a discussion message carried by Discussion and MentionAt events
+type DiscussionData struct {
+	Id       string `json:"id"`
+	Unixus   int64  `json:"unixus"`
+	Content  string `json:"content"`
+	Position string `json:"position"`
+	UserIds  IDList `json:"userIds"`
+}
+
+// Comment is sent when a comment is created or updated
+type Comment struct {
+	Base
+	Comment *CommentData `json:"comment"`
+}
+
+// UserIds returns the commenter and, for new comments, the users the comment is addressed to
+func (e *Comment) UserIds() []string {
+	ids := e.Base.UserIds()
+	if e.Action == "create" && e.Comment != nil {
+		ids = appendIds(ids, e.Comment.UserIds...)
+	}
+	return ids
+}
+
+// Validate requires the file and, for new comments, the comment
+func (e *Comment) Validate() error {
+	if err := e.requireFile(); err != nil {
+		return err
+	}
+	if e.Action == "create" && e.Comment == nil {
+		return errors.New("missing comment")
+	}
+	return nil
+}
+
+// Discussion is sent when a discussion message is posted
+type Discussion struct {
+	Base
+	Discussion *DiscussionData `json:"discussion"`
+}
+
+// Validate requires the file
+func (e *Discussion) Validate() error {
+	return e.requireFile()
+}
+
+// MentionAtData is a mention inside the document body
+type MentionAtData struct {
+	UserId IDList `json:"userId"`
+}
+
+// MentionAt is sent when users are mentioned, Type tells where (comment/discussion/mention_at)
+type MentionAt struct {
+	Base
+	Comment    *CommentData    `json:"comment"`
+	Discussion *DiscussionData `json:"discussion"`
+	MentionAt  *MentionAtData  `json:"mentionAt"`
+}
+
+// UserIds returns the mentioning user and the mentioned users
+func (e *MentionAt) UserIds() []string {
+	ids := e.Base.UserIds()
+	switch {
+	case e.Type == "comment" && e.Comment != nil:
+		ids = appendIds(ids, e.Comment.UserIds...)
+	case e.Type == "discussion" && e.Discussion != nil:
+		ids = appendIds(ids, e.Discussion.UserIds...)
+	case e.Type == "mention_at" && e.MentionAt != nil:
+		ids = appendIds(ids, e.MentionAt.UserId...)
+	}
+	return ids
+}
+
+// Validate requires the file and the object matching Type
+func (e *MentionAt) Validate() error {
+	if err := e.requireFile(); err != nil {
+		return err
+	}
+	switch {
+	case e.Type == "comment" && e.Comment == nil:
+		return errors.New("missing comment")
+	case e.Type == "discussion" && e.Discussion == nil:
+		return errors.New("missing discussion")
+	case e.Type == "mention_at" && e.MentionAt == nil:
+		return errors.New("missing mentionAt")
+	}
+	return nil
+}
+
+// DateMentionData is a date reminder
+type DateMentionData struct {
+	FileId        IDList `json:"fileId"`
+	AuthorId      IDList `json:"authorId"`
+	RemindUserIds IDList `json:"remindUserIds"`
+}
+
+// DateMention is sent when a date reminder is created or updated
+type DateMention struct {
+	Base
+	CreateData *DateMentionData `json:"createData"`
+	UpdateData *DateMentionData `json:"updateData"`
+}
+
+// FileIds returns the event file and the file of a new reminder
+func (e *DateMention) FileIds() []string {
+	ids := e.Base.FileIds()
+	if e.Action == "create" && e.CreateData != nil {
+		ids = appendIds(ids, e.CreateData.FileId...)
+	}
+	return ids
+}
+
+// UserIds returns the event user, the reminder author and the reminded users
+func (e *DateMention) UserIds() []string {
+	ids := e.Base.UserIds()
+	switch {
+	case e.Action == "create" && e.CreateData != nil:
+		ids = appendIds(ids, e.CreateData.AuthorId...)
+		ids = appendIds(ids, e.CreateData.RemindUserIds...)
+	case e.Action == "update" && e.UpdateData != nil:
+		ids = appendIds(ids, e.UpdateData.RemindUserIds...)
+	}
+	return ids
+}
+
+// Validate requires a file and the reminder matching Action
+func (e *DateMention) Validate() error {
+	switch {
+	case e.Action == "create" && e.CreateData == nil:
+		return errors.New("missing createData")
+	case e.Action == "update" && e.UpdateData == nil:
+		return errors.New("missing updateData")
+	}
+	if len(e.FileIds()) == 0 {
+		return errors.New("missing fileId")
+	}
+	return nil
+}
+
+// AutoMentionData identifies the editor of an automatic mention
+type AutoMentionData struct {
+	EditorProviderId ID `json:"editorProviderId"`
+}
+
+// FileContent is sent when the content of a file changes
+type FileContent struct {
+	Base
+	AutoMention *AutoMentionData `json:"autoMention"`
+}
+
+// ActorId returns the editor for auto_mention events, which carry no userId
+func (e *FileContent) ActorId() string {
+	if e.Type == "auto_mention" && e.AutoMention != nil && e.AutoMention.EditorProviderId != "" {
+		return string(e.AutoMention.EditorProviderId)
+	}
+	return e.Base.ActorId()
+}
+
+// UserIds returns the event user and the auto mention editor
+func (e *FileContent) UserIds() []string {
+	ids := e.Base.UserIds()
+	if e.AutoMention != nil {
+		ids = appendIds(ids, e.AutoMention.EditorProviderId)
+	}
+	return ids
+}
+
+// Validate requires the file
+func (e *FileContent) Validate() error {
+	return e.requireFile()
+}
+
+// Collaborator is sent when the collaborators of a file change
+type Collaborator struct {
+	Base
+}
+
+// Validate requires the file
+func (e *Collaborator) Validate() error {
+	return e.requireFile()
+}
@@ -1,14 +1,17 @@
 package invoker
 
 import (
-	"log"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/gotomicro/cetus/l"
 	"github.com/gotomicro/ego/client/ehttp"
 	"github.com/gotomicro/ego/core/elog"
 
-	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/migrations"
 	"sdk-demo-go/pkg/services"
 	"sdk-demo-go/ui"
 
@@ -32,66 +35,92 @@ var (
 )
 
 func Init() error {
-	var err error
 	Gin = egin.Load("server.http").Build(egin.WithEmbedFs(ui.WebUI))
 	Services = services.NewServices()
-	if econf.GetBool("mysql.use") {
-		DB = egorm.Load("mysql").Build()
-	} else {
-		// Connect to an in-memory database so each run shares the same connection
-		dsn := "file::memory:?cache=shared" // Use an in-memory database with a shared cache
-		DB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{})
-		if err != nil {
-			return err
-		}
-		// Configure the connection pool
-		sqlDB, err := DB.DB()
-		if err != nil {
-			return err
-		}
-
-		// Set the maximum number of open connections
-		sqlDB.SetMaxOpenConns(100)
-		// Set the maximum number of idle connections
-		sqlDB.SetMaxIdleConns(50)
-		// Set the maximum connection lifetime
-		sqlDB.SetConnMaxLifetime(0)
-		// Create the tables
-		if err = initTables(); err != nil {
-			return err
-		}
+	if err := InitDB(); err != nil {
+		return err
 	}
 	InitShimo()
 	return nil
 }
 
-func initTables() error {
-	models := []interface{}{
-		&db.Team{}, // Base table
-		&db.User{}, // Base table
-
-		&db.AppClient{},  // Depends on users
-		&db.Department{}, // Depends on teams
-		&db.TeamRole{},   // Depends on teams and users
-
-		&db.DeptMember{}, // Depends on departments and users
-		&db.File{},       // File table
+// InitDB opens the database and applies pending schema migrations
+// Embedded SQLite always migrates on startup; MySQL only when mysql.autoMigrate is enabled
+func InitDB() error {
+	if err := OpenDB(); err != nil {
+		return err
+	}
+	if econf.GetBool("mysql.use") && !econf.GetBool("mysql.autoMigrate") {
+		return nil
+	}
+	_, err := migrations.Up(DB)
+	return err
+}
 
-		&db.Event{},           // Depends on files
-		&db.FilePermissions{}, // Depends on files and users
+// OpenDB connects to MySQL when mysql.use is enabled, otherwise to the embedded SQLite database
+// The server and sdk-ctl both go through here so they always share the same backend
+func OpenDB() error {
+	if econf.GetBool("mysql.use") {
+		DB = egorm.Load("mysql").Build()
+		return nil
+	}
 
-		&db.KnowledgeBase{}, // Knowledge base table
-		&db.TestApi{},       // Standalone table
+	dsn, err := sqliteDSN()
+	if err != nil {
+		return err
 	}
-	err := DB.AutoMigrate(models...)
+	DB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+	// Configure the connection pool
+	sqlDB, err := DB.DB()
 	if err != nil {
-		log.Panicf("failed to migrate tables: %v", err)
 		return err
 	}
 
+	// Set the maximum number of open connections
+	sqlDB.SetMaxOpenConns(100)
+	// Set the maximum number of idle connections
+	sqlDB.SetMaxIdleConns(50)
+	// Set the maximum connection lifetime
+	sqlDB.SetConnMaxLifetime(0)
 	return nil
 }
 
+// sqliteDSN builds the SQLite DSN from the sqlite config section
+// An empty sqlite.path (or ":memory:") keeps the previous shared in-memory database
+func sqliteDSN() (string, error) {
+	path := econf.GetString("sqlite.path")
+	if path == "" || path == ":memory:" {
+		// Connect to an in-memory database so each run shares the same connection
+		return "file::memory:?cache=shared", nil
+	}
+
+	// Make sure the directory holding the database file exists
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	journalMode := econf.GetString("sqlite.journalMode")
+	if journalMode == "" {
+		journalMode = "WAL"
+	}
+	busyTimeout := econf.GetDuration("sqlite.busyTimeout")
+	if busyTimeout == 0 {
+		busyTimeout = 5 * time.Second
+	}
+
+	params := url.Values{}
+	params.Set("_journal_mode", journalMode)
+	params.Set("_busy_timeout", strconv.FormatInt(busyTimeout.Milliseconds(), 10))
+	// NORMAL is durable enough under WAL and avoids an fsync per transaction
+	params.Set("_synchronous", "NORMAL")
+
+	elog.Info("using sqlite database", l.S("path", path), l.S("journalMode", journalMode))
+	return "file:" + path + "?" + params.Encode(), nil
+}
+
 func InitShimo() {
 	// Initialize the sdk-sdk service
 	shimoHost := econf.GetString("shimoSDK.host")
@@ -0,0 +1,137 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/elog"
+	sdkapi "github.com/shimo-open/sdk-kit-go/api"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/utils"
+)
+
+const (
+	// TypeImport polls a file import
+	// Payload: version ("v2" for the v2 import API), rollback (remove the file on failure),
+	// createPreview (create a preview once imported)
+	TypeImport = "import"
+	// TypeExport polls a file export, the result holds the final progress response with the download URL
+	TypeExport = "export"
+	// TypeKnowledgeBaseImport polls a file import into an AI knowledge base
+	// Payload: knowledgeBaseGuid
+	TypeKnowledgeBaseImport = "kb_import"
+)
+
+func init() {
+	register(TypeImport, Handler{Poll: pollImport, OnFail: rollbackFile})
+	register(TypeExport, Handler{Poll: pollExport})
+	register(TypeKnowledgeBaseImport, Handler{Poll: pollKnowledgeBaseImport})
+}
+
+func pollImport(ctx context.Context, job *db.Job) (bool, error) {
+	auth := utils.GetAuth(job.UserId)
+	params := sdkapi.GetImportProgReq{
+		Metadata: auth,
+		TaskId:   job.TaskId,
+	}
+	var res sdkapi.GetImportProgRes
+	var err error
+	if job.Payload.String("version") == "v2" {
+		res, err = invoker.SdkMgr.GetImportV2Progress(ctx, params)
+	} else {
+		res, err = invoker.SdkMgr.GetImportProgress(ctx, params)
+	}
+	if err != nil {
+		err = fmt.Errorf("get import progress failed: %w", err)
+		if permanentStatus(res.Response().StatusCode()) {
+			return false, Permanent(err)
+		}
+		return false, err
+	}
+	// A non-zero status is a failed import, it will not make progress anymore
+	if res.Status != 0 {
+		return false, Permanent(fmt.Errorf("import failed, got: %d, resp body: %s",
+			res.Status, string(res.Response().Body())))
+	}
+	job.Progress = int(res.Data.Progress)
+	if job.Progress < 100 {
+		return false, nil
+	}
+
+	if job.Payload.Bool("createPreview") {
+		previewRes, err := invoker.SdkMgr.CreatePreview(ctx, sdkapi.CreatePreviewReq{
+			Metadata: auth,
+			FileID:   job.FileGuid,
+		})
+		if err != nil || previewRes.Code != "" {
+			return false, Permanent(fmt.Errorf("create preview failed, got: %d, resp body: %s",
+				previewRes.Response().StatusCode(), string(previewRes.Response().Body())))
+		}
+	}
+	return true, nil
+}
+
+// rollbackFile removes the file created for a failed import
+func rollbackFile(_ context.Context, job *db.Job) {
+	if !job.Payload.Bool("rollback") {
+		return
+	}
+	if err := db.RemoveFileByGuid(invoker.DB, job.FileGuid); err != nil {
+		elog.Warn("rollback file failed", l.S("jobId", job.Guid), l.E(err))
+	}
+}
+
+func pollExport(ctx context.Context, job *db.Job) (bool, error) {
+	res, err := invoker.SdkMgr.GetExportProgress(ctx, sdkapi.GetExportProgReq{
+		Metadata: utils.GetAuth(job.UserId),
+		TaskId:   job.TaskId,
+	})
+	if err != nil {
+		return false, fmt.Errorf("get export progress failed: %w", err)
+	}
+	job.Progress = int(res.Data.Progress)
+	if job.Progress < 100 {
+		return false, nil
+	}
+
+	result := db.JobData{}
+	if err = json.Unmarshal(res.Response().Body(), &result); err != nil {
+		return false, fmt.Errorf("decode export progress failed: %w", err)
+	}
+	job.Result = result
+	return true, nil
+}
+
+func pollKnowledgeBaseImport(ctx context.Context, job *db.Job) (bool, error) {
+	res, err := invoker.SdkMgr.GetImportFileToAiProgressV2(ctx, sdkapi.GetImportFileToAiProgressV2Req{
+		Metadata: utils.GetAuth(job.UserId),
+		GetImportFileToAiProgressV2ReqBody: sdkapi.GetImportFileToAiProgressV2ReqBody{
+			TaskID: job.TaskId,
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("get knowledge base import progress failed: %w", err)
+	}
+	job.Progress = int(res.Progress)
+	if res.Status == "failed" {
+		return false, Permanent(fmt.Errorf("knowledge base import failed: %v", res.Message))
+	}
+	if res.Status != "completed" || job.Progress < 100 {
+		return false, nil
+	}
+
+	// Add the file to the local knowledge base
+	kb := &db.KnowledgeBase{
+		Guid:     job.Payload.String("knowledgeBaseGuid"),
+		FileGuid: job.FileGuid,
+		CreateBy: job.UserId,
+	}
+	if err = db.CreateKnowledgeBase(invoker.DB, kb); err != nil {
+		return false, fmt.Errorf("save knowledge base file failed: %w", err)
+	}
+	return true, nil
+}
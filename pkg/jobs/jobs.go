@@ -0,0 +1,227 @@
+// Package jobs runs long SDK tasks (imports, exports, knowledge-base imports) in the
+// background. HTTP handlers start the task, Enqueue a job with the SDK task ID and
+// return the job ID right away; a worker pool then polls the task with backoff.
+// Jobs live in the jobs table, so polling resumes where it left off after a restart.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/stream"
+	"sdk-demo-go/pkg/utils"
+)
+
+// Handler polls the SDK task behind one job type
+type Handler struct {
+	// Poll checks the task once and reports whether it finished successfully
+	// A Permanent error fails the job right away, other errors are retried with backoff
+	Poll func(ctx context.Context, job *db.Job) (done bool, err error)
+	// OnFail cleans up after a job that failed or timed out, it may be nil
+	OnFail func(ctx context.Context, job *db.Job)
+}
+
+var handlers = map[string]Handler{}
+
+func register(jobType string, h Handler) {
+	if _, ok := handlers[jobType]; ok {
+		panic("duplicate job type " + jobType)
+	}
+	handlers[jobType] = h
+}
+
+// permanentError marks an error that retrying will not fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails without further retries
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// permanentStatus tells whether an SDK error response will not change on retry: a 4xx status
+// other than a timeout or a rate limit
+func permanentStatus(code int) bool {
+	return code >= 400 && code < 500 && code != 408 && code != 429
+}
+
+// config holds the [jobs] settings
+type config struct {
+	workers    int
+	backoffMin time.Duration
+	backoffMax time.Duration
+	timeout    time.Duration
+	maxErrors  int
+	lease      time.Duration
+}
+
+var cfg config
+
+func loadConfig() config {
+	c := config{
+		workers:    econf.GetInt("jobs.workers"),
+		backoffMin: econf.GetDuration("jobs.backoffMin"),
+		backoffMax: econf.GetDuration("jobs.backoffMax"),
+		timeout:    econf.GetDuration("jobs.timeout"),
+		maxErrors:  econf.GetInt("jobs.maxErrors"),
+		lease:      time.Minute,
+	}
+	if c.workers <= 0 {
+		c.workers = 4
+	}
+	if c.backoffMin <= 0 {
+		c.backoffMin = time.Second
+	}
+	if c.backoffMax < c.backoffMin {
+		c.backoffMax = 30 * time.Second
+	}
+	if c.timeout <= 0 {
+		c.timeout = 10 * time.Minute
+	}
+	if c.maxErrors <= 0 {
+		c.maxErrors = 5
+	}
+	return c
+}
+
+// Start launches the dispatcher and the worker pool, it is meant to run after invoker.Init
+func Start() error {
+	cfg = loadConfig()
+	queue := make(chan db.Job)
+	for i := 0; i < cfg.workers; i++ {
+		go func() {
+			for job := range queue {
+				run(&job)
+			}
+		}()
+	}
+	go dispatch(queue)
+	return nil
+}
+
+// Enqueue persists a job for an SDK task that has already been started
+func Enqueue(job *db.Job) error {
+	if cfg.workers == 0 {
+		cfg = loadConfig()
+	}
+	now := time.Now()
+	job.Guid = utils.GenFileGuid()
+	job.Status = db.JobPending
+	job.NextRunAt = now.Add(cfg.backoffMin).Unix()
+	job.DeadlineAt = now.Add(cfg.timeout).Unix()
+	if job.Payload == nil {
+		job.Payload = db.JobData{}
+	}
+	if job.Result == nil {
+		job.Result = db.JobData{}
+	}
+	return db.CreateJob(invoker.DB, job)
+}
+
+// dispatch claims due jobs once per second and hands them to the workers
+func dispatch(queue chan<- db.Job) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now().Unix()
+		due, err := db.FindDueJobs(invoker.DB, now, cfg.workers)
+		if err != nil {
+			elog.Error("find due jobs failed", l.E(err))
+			continue
+		}
+		for i := range due {
+			ok, err := db.ClaimJob(invoker.DB, &due[i], now, now+int64(cfg.lease.Seconds()))
+			if err != nil {
+				elog.Error("claim job failed", l.S("jobId", due[i].Guid), l.E(err))
+				continue
+			}
+			if ok {
+				queue <- due[i]
+			}
+		}
+	}
+}
+
+// run polls a claimed job once and records the outcome
+func run(job *db.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.lease/2)
+	defer cancel()
+
+	job.Attempts++
+	var done bool
+	var err error
+	h, ok := handlers[job.Type]
+	if ok {
+		done, err = h.Poll(ctx, job)
+	} else {
+		err = Permanent(errors.New("unknown job type " + job.Type))
+	}
+
+	now := time.Now()
+	var permanent permanentError
+	switch {
+	case err == nil && done:
+		job.Status = db.JobSucceeded
+		job.Progress = 100
+		job.LastError = ""
+		job.FinishedAt = now.Unix()
+	case errors.As(err, &permanent):
+		fail(ctx, h, job, err)
+	case err != nil && job.Errors+1 >= cfg.maxErrors:
+		fail(ctx, h, job, err)
+	case now.Unix() >= job.DeadlineAt:
+		fail(ctx, h, job, errors.New("job timed out"))
+	default:
+		if err != nil {
+			job.Errors++
+			job.LastError = err.Error()
+			elog.Warn("job poll failed", l.S("jobId", job.Guid), l.S("type", job.Type), l.E(err))
+		} else {
+			job.Errors = 0
+		}
+		job.Status = db.JobPending
+		job.NextRunAt = now.Add(backoff(job.Attempts)).Unix()
+	}
+	job.LockedUntil = 0
+
+	if err = db.SaveJob(invoker.DB, job); err != nil {
+		elog.Error("save job failed", l.S("jobId", job.Guid), l.E(err))
+		return
+	}
+	stream.Publish(job.UserId, stream.TypeJob, *job)
+}
+
+func fail(ctx context.Context, h Handler, job *db.Job, err error) {
+	job.Status = db.JobFailed
+	job.LastError = err.Error()
+	job.FinishedAt = time.Now().Unix()
+	elog.Warn("job failed", l.S("jobId", job.Guid), l.S("type", job.Type), l.E(err))
+	if h.OnFail != nil {
+		h.OnFail(ctx, job)
+	}
+}
+
+// backoff doubles the poll interval with every attempt, from backoffMin up to backoffMax
+func backoff(attempts int) time.Duration {
+	d := cfg.backoffMin
+	for i := 1; i < attempts && d < cfg.backoffMax; i++ {
+		d *= 2
+	}
+	if d > cfg.backoffMax {
+		d = cfg.backoffMax
+	}
+	return d
+}
@@ -0,0 +1,196 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// The initial schema, replacing the old database/1_init.up.sql and the SQLite-only AutoMigrate.
+// Tables that already exist (e.g. created from the SQL file) are kept as they are.
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "init",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, initModels()...)
+		},
+		Down: func(tx *gorm.DB) error {
+			models := initModels()
+			for i, j := 0, len(models)-1; i < j; i, j = i+1, j-1 {
+				models[i], models[j] = models[j], models[i]
+			}
+			return dropTables(tx, models...)
+		},
+	})
+}
+
+func initModels() []interface{} {
+	return []interface{}{
+		&v1Team{}, // Base table
+		&v1User{}, // Base table
+
+		&v1AppClient{},  // Depends on users
+		&v1Department{}, // Depends on teams
+		&v1TeamRole{},   // Depends on teams and users
+
+		&v1DeptMember{}, // Depends on departments and users
+		&v1File{},       // File table
+
+		&v1Event{},           // Depends on files
+		&v1FilePermissions{}, // Depends on files and users
+
+		&v1KnowledgeBase{}, // Knowledge base table
+		&v1TestApi{},       // Standalone table
+	}
+}
+
+// v1Team is the teams table as of this migration
+type v1Team struct {
+	baseModel
+	Name string `gorm:"comment:'Team name'"`
+}
+
+func (*v1Team) TableName() string {
+	return "teams"
+}
+
+// v1User is the users table as of this migration
+type v1User struct {
+	baseModel
+	Name      string `gorm:"comment:'User name'"`
+	Email     string `gorm:"index:idx_email;unique;comment:'Email address'"`
+	Avatar    string `gorm:"comment:'Avatar URL'"`
+	Password  string `gorm:"comment:'Password'"`
+	AppID     string `gorm:"comment:'appId'"`
+	CanBother bool   `gorm:"comment:'Can bother'"`
+}
+
+func (*v1User) TableName() string {
+	return "users"
+}
+
+// v1AppClient is the app_clients table as of this migration
+type v1AppClient struct {
+	baseModel
+	AppID     string
+	AppSecret string
+}
+
+func (*v1AppClient) TableName() string {
+	return "app_clients"
+}
+
+// v1Department is the departments table as of this migration
+type v1Department struct {
+	baseModel
+	Name      string `gorm:"comment:'Department name'"`
+	ParentID  int64  `gorm:"index:idx_parent_id;comment:'Parent ID'"`
+	TeamID    int64  `gorm:"index:idx_team_id;comment:'Team ID'"`
+	CanBother bool   `gorm:"comment:'Can bother'"`
+}
+
+func (*v1Department) TableName() string {
+	return "departments"
+}
+
+// v1TeamRole is the team_role table as of this migration
+type v1TeamRole struct {
+	baseModel
+	TeamID int64  `gorm:"uniqueIndex:uniq_team_id_user_id;comment:'Team ID'"`
+	UserID int64  `gorm:"uniqueIndex:uniq_team_id_user_id;index:idx_team_user_id;comment:'User ID'"`
+	Role   string `gorm:"check:role IN ('creator','manager','member');comment:'Role (creator/manager/member)'"`
+}
+
+func (*v1TeamRole) TableName() string {
+	return "team_role"
+}
+
+// v1DeptMember is the dept_members table as of this migration
+type v1DeptMember struct {
+	baseModel
+	DeptID int64 `gorm:"index:idx_dept_id_user_id;uniqueIndex:uniq_dept_id_user_id;comment:'Department ID'"`
+	UserID int64 `gorm:"index:idx_member_user_id;uniqueIndex:uniq_dept_id_user_id;comment:'Member ID'"`
+}
+
+func (*v1DeptMember) TableName() string {
+	return "dept_members"
+}
+
+// v1File is the files table as of this migration
+type v1File struct {
+	baseModel
+	Guid        string `gorm:"uniqueIndex:uniq_guid;comment:'File GUID (unique identifier)'"`
+	Name        string `gorm:"comment:'File name'"`
+	Type        string `gorm:"comment:'File type'"`
+	FilePath    string `gorm:"comment:'File path'"`
+	CreatorId   int64  `gorm:"index:files_id_creator_id_index;comment:'Creator ID'"`
+	IsShimoFile int    `gorm:"comment:'Is Shimo file'"`
+	ShimoType   string `gorm:"comment:'Shimo file type'"`
+}
+
+func (*v1File) TableName() string {
+	return "files"
+}
+
+// v1Event is the events table as of this migration
+type v1Event struct {
+	baseModel
+	Type    string `gorm:"comment:'Event type'"`
+	FileId  string `gorm:"comment:'File ID'"`
+	UserId  string `gorm:"index:idx_event_user_id;comment:'Related user ID'"`
+	RawData string `gorm:"comment:'Message content'"`
+	Headers string `gorm:"comment:'Event headers'"`
+}
+
+func (*v1Event) TableName() string {
+	return "events"
+}
+
+// v1FilePermissions is the file_permissions table as of this migration
+type v1FilePermissions struct {
+	baseModel
+	FileId      int64  `gorm:"uniqueIndex:uniq_file_id_user_id;comment:'File ID'"`
+	UserId      int64  `gorm:"uniqueIndex:uniq_file_id_user_id;comment:'User ID'"`
+	Role        string `gorm:"default:'collaborator';comment:'Role (owner/collaborator)'"`
+	Permissions string `gorm:"comment:'Permissions'"`
+}
+
+func (*v1FilePermissions) TableName() string {
+	return "file_permissions"
+}
+
+// v1KnowledgeBase is the knowledge_bases table as of this migration
+type v1KnowledgeBase struct {
+	baseModel
+	Guid     string
+	FileGuid string
+	CreateBy int64
+	DeleteAt int64
+	Name     string
+}
+
+func (*v1KnowledgeBase) TableName() string {
+	return "knowledge_bases"
+}
+
+// v1TestApi is the test_api table as of this migration
+type v1TestApi struct {
+	baseModel
+	TestId        string `gorm:"comment:'Test UUID'"`
+	TestType      string `gorm:"comment:'Test type'"`
+	ApiName       string `gorm:"comment:'API name'"`
+	Success       int    `gorm:"comment:'Success (0-false;1-true)'"`
+	HttpCode      int    `gorm:"comment:'Status code'"`
+	HttpResp      string `gorm:"comment:'Response result'"`
+	ErrMsg        string `gorm:"comment:'Error message'"`
+	PathStr       string `gorm:"comment:'API request path'"`
+	BodyReq       string `gorm:"comment:'Body parameters'"`
+	Query         string `gorm:"comment:'Query parameters'"`
+	FormData      string `gorm:"comment:'Form data parameters'"`
+	FileExt       string `gorm:"comment:'File extension/Export file type'"`
+	TimeConsuming string `gorm:"comment:'Time consuming'"`
+	StartTime     int64  `gorm:"comment:'Test start time'"`
+}
+
+func (*v1TestApi) TableName() string {
+	return "test_api"
+}
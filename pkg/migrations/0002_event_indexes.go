@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Events are listed by file and type. Databases created from database/1_init.up.sql already index
+// these columns under their legacy names and are left as they are
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "event_indexes",
+		Up: func(tx *gorm.DB) error {
+			for _, idx := range v2EventIndexes {
+				if tx.Migrator().HasIndex(&v2Event{}, idx.legacy) {
+					continue
+				}
+				if err := createIndexes(tx, &v2Event{}, idx.name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, idx := range v2EventIndexes {
+				if err := dropIndexes(tx, &v2Event{}, idx.name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// v2EventIndexes are the indexes this migration creates, with the legacy index on the same column
+var v2EventIndexes = []struct {
+	name   string
+	legacy string
+}{
+	{name: "idx_event_file_id", legacy: "idx_file_id"},
+	{name: "idx_event_type", legacy: "idx_type"},
+}
+
+// v2Event holds the columns of the events table this migration works on
+type v2Event struct {
+	baseModel
+	Type   string `gorm:"index:idx_event_type;comment:'Event type'"`
+	FileId string `gorm:"index:idx_event_file_id;comment:'File ID'"`
+}
+
+func (*v2Event) TableName() string {
+	return "events"
+}
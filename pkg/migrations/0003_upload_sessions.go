@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Resumable multipart uploads keep their session and part bookkeeping in the database
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "upload_sessions",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v3UploadSession{}, &v3UploadPart{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v3UploadPart{}, &v3UploadSession{})
+		},
+	})
+}
+
+// v3UploadSession is the upload_sessions table as of this migration
+type v3UploadSession struct {
+	baseModel
+	Guid      string `gorm:"uniqueIndex:uniq_upload_session_guid;comment:'Upload session GUID'"`
+	UserId    int64  `gorm:"index:idx_upload_session_user_id;comment:'User ID'"`
+	FileGuid  string `gorm:"comment:'File GUID'"`
+	FileName  string `gorm:"comment:'File name'"`
+	FileType  string `gorm:"comment:'File MIME type'"`
+	Size      int64  `gorm:"comment:'Announced total size'"`
+	UploadId  string `gorm:"comment:'Storage multipart upload ID'"`
+	Status    string `gorm:"index:idx_upload_session_status;comment:'Upload status'"`
+	ExpiresAt int64  `gorm:"comment:'Expiry timestamp'"`
+}
+
+func (*v3UploadSession) TableName() string {
+	return "upload_sessions"
+}
+
+// v3UploadPart is the upload_parts table as of this migration
+type v3UploadPart struct {
+	baseModel
+	SessionId  int64  `gorm:"uniqueIndex:uniq_upload_part;comment:'Upload session ID'"`
+	PartNumber int64  `gorm:"uniqueIndex:uniq_upload_part;comment:'Part number'"`
+	Size       int64  `gorm:"comment:'Part size'"`
+	ETag       string `gorm:"column:etag;comment:'Part ETag'"`
+}
+
+func (*v3UploadPart) TableName() string {
+	return "upload_parts"
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Import, export and knowledge-base import tasks are tracked as persisted jobs
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "jobs",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v4Job{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v4Job{})
+		},
+	})
+}
+
+// v4Job is the jobs table as of this migration
+type v4Job struct {
+	baseModel
+	Guid        string `gorm:"uniqueIndex:uniq_job_guid;comment:'Job GUID'"`
+	UserId      int64  `gorm:"index:idx_job_user_id;comment:'User ID'"`
+	Type        string `gorm:"comment:'Job type'"`
+	Status      string `gorm:"index:idx_job_status_next_run,priority:1;comment:'Job status'"`
+	FileGuid    string `gorm:"comment:'File GUID'"`
+	TaskId      string `gorm:"comment:'SDK task ID'"`
+	Payload     string `gorm:"type:text;comment:'Job input'"`
+	Result      string `gorm:"type:text;comment:'Job output'"`
+	Progress    int    `gorm:"comment:'Progress'"`
+	Attempts    int    `gorm:"comment:'Poll attempts'"`
+	Errors      int    `gorm:"comment:'Consecutive errors'"`
+	LastError   string `gorm:"type:text;comment:'Last error'"`
+	NextRunAt   int64  `gorm:"index:idx_job_status_next_run,priority:2;comment:'Next poll timestamp'"`
+	LockedUntil int64  `gorm:"comment:'Lease expiry timestamp'"`
+	DeadlineAt  int64  `gorm:"comment:'Deadline timestamp'"`
+	FinishedAt  int64  `gorm:"comment:'Finished timestamp'"`
+}
+
+func (*v4Job) TableName() string {
+	return "jobs"
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Callback events are fanned out to per-recipient notification rows
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "notifications",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v5Notification{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v5Notification{})
+		},
+	})
+}
+
+// v5Notification is the notifications table as of this migration
+type v5Notification struct {
+	baseModel
+	UserId  int64  `gorm:"index:idx_notification_user_read,priority:1;comment:'Recipient user ID'"`
+	ReadAt  int64  `gorm:"index:idx_notification_user_read,priority:2;comment:'Read timestamp'"`
+	EventId int64  `gorm:"index:idx_notification_event_id;comment:'Event ID'"`
+	Type    string `gorm:"comment:'Event type'"`
+	Action  string `gorm:"comment:'Event action'"`
+	FileId  string `gorm:"comment:'File ID'"`
+	ActorId string `gorm:"comment:'Actor user ID'"`
+}
+
+func (*v5Notification) TableName() string {
+	return "notifications"
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Callback events are relayed to outbound webhooks through a persistent delivery queue
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "webhooks",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v6WebhookSubscription{}, &v6WebhookDelivery{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v6WebhookDelivery{}, &v6WebhookSubscription{})
+		},
+	})
+}
+
+// v6WebhookSubscription is the webhook_subscriptions table as of this migration
+type v6WebhookSubscription struct {
+	baseModel
+	Guid       string `gorm:"uniqueIndex:uniq_webhook_subscription_guid;comment:'Webhook GUID'"`
+	UserId     int64  `gorm:"index:idx_webhook_subscription_user_id;comment:'Owner user ID'"`
+	URL        string `gorm:"column:url;comment:'Endpoint URL'"`
+	EventTypes string `gorm:"comment:'Event type filter'"`
+	Secret     string `gorm:"comment:'Signing secret'"`
+	Active     bool   `gorm:"comment:'Active'"`
+}
+
+func (*v6WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// v6WebhookDelivery is the webhook_deliveries table as of this migration
+type v6WebhookDelivery struct {
+	baseModel
+	SubscriptionId int64  `gorm:"index:idx_webhook_delivery_subscription;comment:'Webhook subscription ID'"`
+	EventId        int64  `gorm:"comment:'Event ID'"`
+	EventType      string `gorm:"comment:'Event type'"`
+	Payload        string `gorm:"type:text;comment:'Event payload'"`
+	Status         string `gorm:"index:idx_webhook_delivery_status_next_run,priority:1;comment:'Delivery status'"`
+	Attempts       int    `gorm:"comment:'Delivery attempts'"`
+	ResponseCode   int    `gorm:"comment:'Last response status'"`
+	LastError      string `gorm:"type:text;comment:'Last error'"`
+	NextRunAt      int64  `gorm:"index:idx_webhook_delivery_status_next_run,priority:2;comment:'Next attempt timestamp'"`
+	LockedUntil    int64  `gorm:"comment:'Lease expiry timestamp'"`
+	DeliveredAt    int64  `gorm:"comment:'Delivered timestamp'"`
+}
+
+func (*v6WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// SDK retries of a callback event are stored once, keyed by delivery ID or payload hash;
+// existing rows keep a NULL key until `sdk-ctl db dedupe-events` backfills them
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "event_dedupe",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&v7Event{}, "DedupeKey") {
+				if err := tx.Migrator().AddColumn(&v7Event{}, "DedupeKey"); err != nil {
+					return err
+				}
+			}
+			return createIndexes(tx, &v7Event{}, "uniq_event_dedupe_key")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropIndexes(tx, &v7Event{}, "uniq_event_dedupe_key"); err != nil {
+				return err
+			}
+			if !tx.Migrator().HasColumn(&v7Event{}, "DedupeKey") {
+				return nil
+			}
+			return tx.Migrator().DropColumn(&v7Event{}, "DedupeKey")
+		},
+	})
+}
+
+// v7Event holds the columns of the events table this migration works on
+type v7Event struct {
+	baseModel
+	DedupeKey *string `gorm:"uniqueIndex:uniq_event_dedupe_key;size:80;comment:'Delivery dedupe key'"`
+}
+
+func (*v7Event) TableName() string {
+	return "events"
+}
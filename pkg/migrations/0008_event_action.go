@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// eventActionBatch is how many events are backfilled at a time
+const eventActionBatch = 500
+
+// Events are filtered and aggregated by action, which used to live only in the raw payload;
+// the column is backfilled from the payload of the stored events
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "event_action",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&v8Event{}, "Action") {
+				if err := tx.Migrator().AddColumn(&v8Event{}, "Action"); err != nil {
+					return err
+				}
+			}
+			if err := createIndexes(tx, &v8Event{}, "idx_event_action"); err != nil {
+				return err
+			}
+			return backfillEventActions(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropIndexes(tx, &v8Event{}, "idx_event_action"); err != nil {
+				return err
+			}
+			if !tx.Migrator().HasColumn(&v8Event{}, "Action") {
+				return nil
+			}
+			return tx.Migrator().DropColumn(&v8Event{}, "Action")
+		},
+	})
+}
+
+// backfillEventActions copies the payload action of every stored event into the action column
+func backfillEventActions(tx *gorm.DB) error {
+	var lastId int64
+	for {
+		var rows []v8Event
+		err := tx.Unscoped().Select("id", "raw_data").
+			Where("id > ?", lastId).Order("id").Limit(eventActionBatch).Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+		for _, e := range rows {
+			lastId = e.ID
+			var p v8Payload
+			if err := json.Unmarshal([]byte(e.RawData), &p); err != nil || p.Action == "" {
+				continue
+			}
+			err = tx.Unscoped().Model(&v8Event{}).Where("id = ?", e.ID).UpdateColumn("action", p.Action).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// v8Event holds the columns of the events table this migration works on
+type v8Event struct {
+	baseModel
+	Action  string `gorm:"index:idx_event_action;size:64;comment:'Event action'"`
+	RawData string `gorm:"comment:'Message content'"`
+}
+
+func (*v8Event) TableName() string {
+	return "events"
+}
+
+// v8Payload is the part of a stored payload the action is read from
+type v8Payload struct {
+	Action string `json:"action"`
+}
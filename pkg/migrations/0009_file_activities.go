@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Actions taken on files through the demo are recorded for the file activity timeline
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "file_activities",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v9FileActivity{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v9FileActivity{})
+		},
+	})
+}
+
+// v9FileActivity is the file_activities table as of this migration
+type v9FileActivity struct {
+	baseModel
+	FileGuid string `gorm:"index:idx_file_activity_file_guid;comment:'File GUID'"`
+	UserId   int64  `gorm:"comment:'Actor user ID'"`
+	Action   string `gorm:"comment:'Action'"`
+	Detail   string `gorm:"type:text;comment:'Action detail'"`
+}
+
+func (*v9FileActivity) TableName() string {
+	return "file_activities"
+}
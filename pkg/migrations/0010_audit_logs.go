@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Mutating API requests are recorded in an audit log for compliance reviews
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "audit_logs",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v10AuditLog{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v10AuditLog{})
+		},
+	})
+}
+
+// v10AuditLog is the audit_logs table as of this migration
+type v10AuditLog struct {
+	baseModel
+	ActorId    int64  `gorm:"index:idx_audit_log_actor_id;comment:'Actor user ID'"`
+	Action     string `gorm:"index:idx_audit_log_action;size:191;comment:'Action'"`
+	Path       string `gorm:"comment:'Request path'"`
+	TargetType string `gorm:"index:idx_audit_log_target,priority:1;size:64;comment:'Target type'"`
+	TargetId   string `gorm:"index:idx_audit_log_target,priority:2;size:191;comment:'Target ID'"`
+	Status     int    `gorm:"comment:'Response status'"`
+	Before     string `gorm:"type:text;comment:'Snapshot before'"`
+	After      string `gorm:"type:text;comment:'Snapshot after'"`
+	Diff       string `gorm:"type:text;comment:'Changed fields'"`
+	RequestId  string `gorm:"index:idx_audit_log_request_id;size:64;comment:'Request ID'"`
+	IP         string `gorm:"column:ip;comment:'Client IP'"`
+	UserAgent  string `gorm:"comment:'User agent'"`
+}
+
+func (*v10AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Files are organised in folders: a parent reference and a folder flag on every file
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "folders",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"ParentGuid", "IsFolder"} {
+				if tx.Migrator().HasColumn(&v11File{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&v11File{}, field); err != nil {
+					return err
+				}
+			}
+			return createIndexes(tx, &v11File{}, "idx_file_parent_guid")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropIndexes(tx, &v11File{}, "idx_file_parent_guid"); err != nil {
+				return err
+			}
+			for _, field := range []string{"IsFolder", "ParentGuid"} {
+				if !tx.Migrator().HasColumn(&v11File{}, field) {
+					continue
+				}
+				if err := tx.Migrator().DropColumn(&v11File{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// v11File holds the columns of the files table this migration works on
+type v11File struct {
+	baseModel
+	ParentGuid string `gorm:"index:idx_file_parent_guid;size:64;comment:'Parent folder GUID'"`
+	IsFolder   bool   `gorm:"comment:'Is folder'"`
+}
+
+func (*v11File) TableName() string {
+	return "files"
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Files can be shared with a whole team or department
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "file_group_permissions",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v12FileGroupPermissions{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v12FileGroupPermissions{})
+		},
+	})
+}
+
+// v12FileGroupPermissions is the file_group_permissions table as of this migration
+type v12FileGroupPermissions struct {
+	baseModel
+	FileId      int64  `gorm:"uniqueIndex:uniq_file_group;comment:'File ID'"`
+	GroupType   string `gorm:"uniqueIndex:uniq_file_group;index:idx_file_group_group;size:16;comment:'Group type (team/department)'"`
+	GroupId     int64  `gorm:"uniqueIndex:uniq_file_group;index:idx_file_group_group;comment:'Team or department ID'"`
+	Permissions string `gorm:"comment:'Permissions'"`
+}
+
+func (*v12FileGroupPermissions) TableName() string {
+	return "file_group_permissions"
+}
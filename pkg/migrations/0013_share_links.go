@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Files can be shared through public links with an expiry, a password and a uses limit
+func init() {
+	register(Migration{
+		Version: 13,
+		Name:    "share_links",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v13ShareLink{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v13ShareLink{})
+		},
+	})
+}
+
+// v13ShareLink is the share_links table as of this migration
+type v13ShareLink struct {
+	baseModel
+	Guid        string `gorm:"uniqueIndex:uniq_share_link_guid;size:32;comment:'Share link GUID'"`
+	Token       string `gorm:"uniqueIndex:uniq_share_link_token;size:64;comment:'Share link token'"`
+	FileGuid    string `gorm:"index:idx_share_link_file_guid;size:64;comment:'File GUID'"`
+	CreatorId   int64  `gorm:"comment:'Creator ID'"`
+	Permissions string `gorm:"comment:'Permissions'"`
+	ExpiresAt   int64  `gorm:"comment:'Expiry timestamp'"`
+	Password    string `gorm:"comment:'Password hash'"`
+	MaxUses     int    `gorm:"comment:'Max uses'"`
+	Uses        int    `gorm:"comment:'Uses'"`
+	RevokedAt   int64  `gorm:"comment:'Revoked timestamp'"`
+}
+
+func (*v13ShareLink) TableName() string {
+	return "share_links"
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Deleted files go to a recycle bin they can be restored from until they expire
+func init() {
+	register(Migration{
+		Version: 14,
+		Name:    "trash_items",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v14TrashItem{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v14TrashItem{})
+		},
+	})
+}
+
+// v14TrashItem is the trash_items table as of this migration
+type v14TrashItem struct {
+	baseModel
+	FileGuid   string `gorm:"index:idx_trash_item_file_guid;size:64;comment:'File GUID'"`
+	Name       string `gorm:"comment:'File name'"`
+	Type       string `gorm:"comment:'File type'"`
+	IsFolder   bool   `gorm:"comment:'Is folder'"`
+	ParentGuid string `gorm:"size:64;comment:'Parent folder GUID'"`
+	OwnerId    int64  `gorm:"index:idx_trash_item_owner_id;comment:'Owner ID'"`
+	DeleterId  int64  `gorm:"index:idx_trash_item_deleter_id;comment:'Deleter ID'"`
+	TrashedAt  int64  `gorm:"index:idx_trash_item_trashed_at;comment:'Trashed timestamp'"`
+}
+
+func (*v14TrashItem) TableName() string {
+	return "trash_items"
+}
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Users get a history of the files they opened and can star and pin files
+func init() {
+	register(Migration{
+		Version: 15,
+		Name:    "user_files",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v15UserFile{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v15UserFile{})
+		},
+	})
+}
+
+// v15UserFile is the user_files table as of this migration
+type v15UserFile struct {
+	baseModel
+	UserId       int64 `gorm:"uniqueIndex:uniq_user_file;comment:'User ID'"`
+	FileId       int64 `gorm:"uniqueIndex:uniq_user_file;index:idx_user_file_file_id;comment:'File ID'"`
+	LastOpenedAt int64 `gorm:"comment:'Last opened timestamp'"`
+	StarredAt    int64 `gorm:"comment:'Starred timestamp'"`
+	PinnedAt     int64 `gorm:"comment:'Pinned timestamp'"`
+}
+
+func (*v15UserFile) TableName() string {
+	return "user_files"
+}
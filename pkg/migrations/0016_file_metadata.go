@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Files can carry user-defined tags and custom key/value metadata
+func init() {
+	register(Migration{
+		Version: 16,
+		Name:    "file_metadata",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v16FileTag{}, &v16FileMetadata{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v16FileTag{}, &v16FileMetadata{})
+		},
+	})
+}
+
+// v16FileTag is the file_tags table as of this migration
+type v16FileTag struct {
+	baseModel
+	FileId    int64  `gorm:"uniqueIndex:uniq_file_tag;comment:'File ID'"`
+	Tag       string `gorm:"uniqueIndex:uniq_file_tag;index:idx_file_tag_tag;size:64;comment:'Tag'"`
+	CreatorId int64  `gorm:"comment:'Creator ID'"`
+}
+
+func (*v16FileTag) TableName() string {
+	return "file_tags"
+}
+
+// v16FileMetadata is the file_metadata table as of this migration
+type v16FileMetadata struct {
+	baseModel
+	FileId int64  `gorm:"uniqueIndex:uniq_file_metadata;comment:'File ID'"`
+	Name   string `gorm:"uniqueIndex:uniq_file_metadata;index:idx_file_metadata_name_value;size:64;comment:'Metadata key'"`
+	Value  string `gorm:"index:idx_file_metadata_name_value;size:255;comment:'Metadata value'"`
+}
+
+func (*v16FileMetadata) TableName() string {
+	return "file_metadata"
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// The plain text of collaborative files is kept for the full-text search index
+func init() {
+	register(Migration{
+		Version: 17,
+		Name:    "file_texts",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &v17FileText{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v17FileText{})
+		},
+	})
+}
+
+// v17FileText is the file_texts table as of this migration
+type v17FileText struct {
+	baseModel
+	FileGuid  string `gorm:"uniqueIndex:uniq_file_text_file_guid;size:64;comment:'File GUID'"`
+	Content   string `gorm:"type:longtext;comment:'Plain text'"`
+	IndexedAt int64  `gorm:"comment:'Indexed timestamp'"`
+}
+
+func (*v17FileText) TableName() string {
+	return "file_texts"
+}
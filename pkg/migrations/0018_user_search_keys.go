@@ -0,0 +1,182 @@
+package migrations
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// userSearchKeyBatch is how many users get their search keys at a time
+	userSearchKeyBatch = 500
+	// v18MaxTermLength is the size of the term column, in bytes
+	v18MaxTermLength = 128
+)
+
+// Users are searched by prefix on their name, pinyin and email through an indexed table of terms;
+// the terms of the existing users are backfilled
+func init() {
+	register(Migration{
+		Version: 18,
+		Name:    "user_search_keys",
+		Up: func(tx *gorm.DB) error {
+			if err := createTables(tx, &v18UserSearchKey{}); err != nil {
+				return err
+			}
+			return backfillUserSearchKeys(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, &v18UserSearchKey{})
+		},
+	})
+}
+
+// backfillUserSearchKeys saves the search keys of every user
+func backfillUserSearchKeys(tx *gorm.DB) error {
+	var lastId int64
+	for {
+		var users []v18User
+		err := tx.Select("id", "name", "email").
+			Where("id > ?", lastId).Order("id").Limit(userSearchKeyBatch).Find(&users).Error
+		if err != nil || len(users) == 0 {
+			return err
+		}
+		var rows []v18UserSearchKey
+		for _, u := range users {
+			lastId = u.ID
+			for _, term := range v18SearchTerms(u.Name, u.Email) {
+				rows = append(rows, v18UserSearchKey{UserId: u.ID, Term: term})
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		err = tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, userSearchKeyBatch).Error
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// v18SearchTerms returns the distinct search terms of a user name and email: the name, its words, the pinyin
+// of a Chinese name and its initials, the email and the parts of its local part
+func v18SearchTerms(name string, email string) []string {
+	terms := v18NameKeys(name)
+	if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		terms = append(terms, email)
+		local, _, _ := strings.Cut(email, "@")
+		terms = append(terms, strings.FieldsFunc(local, func(r rune) bool {
+			return strings.ContainsRune(".-_+", r)
+		})...)
+	}
+
+	seen := map[string]bool{}
+	res := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if len(term) <= v18MaxTermLength && !seen[term] {
+			seen[term] = true
+			res = append(res, term)
+		}
+	}
+	return res
+}
+
+// v18NameKeys returns the lower-cased terms of a name, with the pinyin of the whole name and of the given name
+func v18NameKeys(name string) []string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil
+	}
+	var keys []string
+	seen := map[string]bool{}
+	add := func(key string) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	add(name)
+	for _, word := range strings.Fields(name) {
+		add(word)
+	}
+	syllables := v18Spell(name)
+	if len(syllables) == 0 {
+		return keys
+	}
+	add(strings.Join(syllables, ""))
+	add(v18Initials(syllables))
+	if runes := []rune(name); len(syllables) > 1 && len(syllables) == len(runes) {
+		add(string(runes[1:]))
+		add(strings.Join(syllables[1:], ""))
+		if len(syllables) > 2 {
+			add(v18Initials(syllables[1:]))
+		}
+	}
+	return keys
+}
+
+// v18Surnames holds the reading of the characters that are read differently as a surname
+var v18Surnames = map[rune]string{
+	'单': "shan", '曾': "zeng", '解': "xie", '区': "ou", '仇': "qiu", '朴': "piao", '查': "zha",
+	'乐': "yue", '覃': "qin", '缪': "miao", '翟': "zhai", '种': "chong", '秘': "bi", '盖': "ge",
+	'员': "yun", '句': "gou", '郇': "xun", '重': "chong", '薄': "bo", '长': "chang",
+}
+
+// v18ByRune maps every character of v18Readings to its pinyin
+var v18ByRune = func() map[rune]string {
+	res := map[rune]string{}
+	for syllable, chars := range v18Readings {
+		for _, r := range chars {
+			res[r] = syllable
+		}
+	}
+	return res
+}()
+
+// v18Spell returns the pinyin of the characters of a name that have one, the first one read as a surname
+func v18Spell(name string) []string {
+	var res []string
+	for i, r := range []rune(name) {
+		if s, ok := v18Surnames[r]; ok && i == 0 {
+			res = append(res, s)
+			continue
+		}
+		if s, ok := v18ByRune[r]; ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// v18Initials returns the first letter of every syllable
+func v18Initials(syllables []string) string {
+	var b strings.Builder
+	for _, s := range syllables {
+		b.WriteByte(s[0])
+	}
+	return b.String()
+}
+
+// v18User holds the columns of the users table the search keys are built from
+type v18User struct {
+	baseModel
+	Name  string
+	Email string
+}
+
+func (*v18User) TableName() string {
+	return "users"
+}
+
+// v18UserSearchKey is the user_search_keys table as of this migration
+type v18UserSearchKey struct {
+	baseModel
+	UserId int64  `gorm:"uniqueIndex:uniq_user_search_key;comment:'User ID'"`
+	Term   string `gorm:"uniqueIndex:uniq_user_search_key;index:idx_user_search_key_term;size:128;comment:'Search term'"`
+}
+
+func (*v18UserSearchKey) TableName() string {
+	return "user_search_keys"
+}
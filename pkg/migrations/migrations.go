@@ -0,0 +1,228 @@
+// Package migrations keeps the database schema in sync across MySQL and SQLite.
+//
+// Each migration is a numbered Go step built on the gorm Migrator, so the same
+// code produces equivalent DDL for both backends. Applied versions are recorded
+// in the schema_migrations table.
+package migrations
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/elog"
+	"gorm.io/gorm"
+	"gorm.io/plugin/soft_delete"
+)
+
+// Migration is a single numbered schema change
+type Migration struct {
+	// Version orders migrations; it must be unique and never reused
+	Version int64
+	// Name is a short description shown by `sdk-ctl db status`
+	Name string
+	// Up applies the change
+	Up func(tx *gorm.DB) error
+	// Down reverts the change
+	Down func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	// Version is the migration version
+	Version int64 `gorm:"primaryKey;autoIncrement:false;comment:'Migration version'" json:"version"`
+	// Name is the migration name
+	Name string `gorm:"comment:'Migration name'" json:"name"`
+	// AppliedAt is the Unix timestamp when the migration was applied
+	AppliedAt int64 `gorm:"comment:'Applied timestamp'" json:"appliedAt"`
+}
+
+func (s *SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status describes a known migration and whether it has been applied
+type Status struct {
+	Version   int64  `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt int64  `json:"appliedAt"`
+}
+
+var registry []Migration
+
+// baseModel holds the columns every table is created with. Migrations declare their own copy of the
+// tables they change rather than using the models of pkg/models/db, so that changing a model later
+// does not change what an already applied migration did
+type baseModel struct {
+	ID        int64                 `gorm:"primaryKey; auto_increment"`
+	CreatedAt int64                 `gorm:"comment:'Created timestamp';autoCreateTime"`
+	UpdatedAt int64                 `gorm:"comment:'Updated timestamp';autoUpdateTime'"`
+	DeletedAt soft_delete.DeletedAt `gorm:"default:0;index;comment:'Deleted timestamp'"`
+}
+
+// register adds a migration to the registry, panicking on duplicate versions
+func register(m Migration) {
+	for _, r := range registry {
+		if r.Version == m.Version {
+			panic(fmt.Sprintf("duplicate migration version %d", m.Version))
+		}
+	}
+	registry = append(registry, m)
+	sort.Slice(registry, func(i, j int) bool {
+		return registry[i].Version < registry[j].Version
+	})
+}
+
+// All returns every registered migration in version order
+func All() []Migration {
+	return registry
+}
+
+// Up applies every pending migration in version order
+func Up(db *gorm.DB) (applied []Migration, err error) {
+	done, err := appliedVersions(db)
+	if err != nil {
+		return
+	}
+
+	for _, m := range registry {
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now().Unix(),
+			}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		elog.Info("migration applied", l.I64("version", m.Version), l.S("name", m.Name))
+		applied = append(applied, m)
+	}
+	return
+}
+
+// Down rolls back the latest `steps` applied migrations, newest first
+func Down(db *gorm.DB, steps int) (rolledBack []Migration, err error) {
+	done, err := appliedVersions(db)
+	if err != nil {
+		return
+	}
+
+	for i := len(registry) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		m := registry[i]
+		if _, ok := done[m.Version]; !ok {
+			continue
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Where("version = ?", m.Version).Delete(&SchemaMigration{}).Error
+		})
+		if err != nil {
+			return rolledBack, fmt.Errorf("rollback %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		elog.Info("migration rolled back", l.I64("version", m.Version), l.S("name", m.Name))
+		rolledBack = append(rolledBack, m)
+	}
+	return
+}
+
+// GetStatus lists every registered migration together with its applied state
+func GetStatus(db *gorm.DB) (statuses []Status, err error) {
+	done, err := appliedVersions(db)
+	if err != nil {
+		return
+	}
+
+	statuses = make([]Status, len(registry))
+	for i, m := range registry {
+		sm, ok := done[m.Version]
+		statuses[i] = Status{
+			Version:   m.Version,
+			Name:      m.Name,
+			Applied:   ok,
+			AppliedAt: sm.AppliedAt,
+		}
+	}
+	return
+}
+
+// appliedVersions ensures schema_migrations exists and returns its rows keyed by version
+func appliedVersions(db *gorm.DB) (done map[int64]SchemaMigration, err error) {
+	if err = db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return
+	}
+
+	var rows []SchemaMigration
+	if err = db.Find(&rows).Error; err != nil {
+		return
+	}
+
+	done = make(map[int64]SchemaMigration, len(rows))
+	for _, r := range rows {
+		done[r.Version] = r
+	}
+	return
+}
+
+// createTables creates each missing table; existing tables are left untouched
+func createTables(tx *gorm.DB, models ...interface{}) error {
+	for _, m := range models {
+		if tx.Migrator().HasTable(m) {
+			continue
+		}
+		if err := tx.Migrator().CreateTable(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropTables drops the given tables if they exist
+func dropTables(tx *gorm.DB, models ...interface{}) error {
+	for _, m := range models {
+		if !tx.Migrator().HasTable(m) {
+			continue
+		}
+		if err := tx.Migrator().DropTable(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createIndexes creates each named index on the model if it is missing
+func createIndexes(tx *gorm.DB, model interface{}, names ...string) error {
+	for _, name := range names {
+		if tx.Migrator().HasIndex(model, name) {
+			continue
+		}
+		if err := tx.Migrator().CreateIndex(model, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropIndexes drops each named index on the model if it exists
+func dropIndexes(tx *gorm.DB, model interface{}, names ...string) error {
+	for _, name := range names {
+		if !tx.Migrator().HasIndex(model, name) {
+			continue
+		}
+		if err := tx.Migrator().DropIndex(model, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/gotomicro/ego/core/econf"
+)
+
+// AuditLog represents one mutating API request, who made it and what it changed
+type AuditLog struct {
+	BaseModel
+	// ActorId is the ID of the user who made the request (0 when unauthenticated)
+	ActorId int64 `gorm:"index:idx_audit_log_actor_id;comment:'Actor user ID'" json:"actorId"`
+	// Action is the route of the request (e.g., DELETE /api/files/:fileGuid)
+	Action string `gorm:"index:idx_audit_log_action;size:191;comment:'Action'" json:"action"`
+	// Path is the requested URL path
+	Path string `gorm:"comment:'Request path'" json:"path"`
+	// TargetType is the kind of the changed object (e.g., fileGuid, teamId)
+	TargetType string `gorm:"index:idx_audit_log_target,priority:1;size:64;comment:'Target type'" json:"targetType"`
+	// TargetId is the ID of the changed object, comma separated for batch requests
+	TargetId string `gorm:"index:idx_audit_log_target,priority:2;size:191;comment:'Target ID'" json:"targetId"`
+	// Status is the HTTP status of the response
+	Status int `gorm:"comment:'Response status'" json:"status"`
+	// Before is the JSON snapshot of the target before the request
+	Before JSONText `gorm:"type:text;comment:'Snapshot before'" json:"before"`
+	// After is the JSON snapshot of the target after the request
+	After JSONText `gorm:"type:text;comment:'Snapshot after'" json:"after"`
+	// Diff is the JSON object of the changed fields, each with its from and to value
+	Diff JSONText `gorm:"type:text;comment:'Changed fields'" json:"diff"`
+	// RequestId is the X-Request-Id of the request
+	RequestId string `gorm:"index:idx_audit_log_request_id;size:64;comment:'Request ID'" json:"requestId"`
+	// IP is the client IP of the request
+	IP string `gorm:"column:ip;comment:'Client IP'" json:"ip"`
+	// UserAgent is the User-Agent of the request
+	UserAgent string `gorm:"comment:'User agent'" json:"userAgent"`
+}
+
+// TableName returns the database table name for AuditLog
+func (a *AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// JSONText is a JSON document stored as text, it is embedded as is when marshalled
+type JSONText string
+
+// MarshalJSON returns the document, or null when it is empty
+func (t JSONText) MarshalJSON() ([]byte, error) {
+	if t == "" {
+		return []byte("null"), nil
+	}
+	return []byte(t), nil
+}
+
+// AuditLogFilter selects the audit logs returned by the audit log queries, empty fields are ignored
+type AuditLogFilter struct {
+	// ActorId is the ID of the user who made the requests
+	ActorId int64
+	// Action is the route of the requests
+	Action string
+	// TargetType is the kind of the changed objects
+	TargetType string
+	// TargetId is the ID of the changed object
+	TargetId string
+	// From is the Unix timestamp the requests are made at or after
+	From int64
+	// To is the Unix timestamp the requests are made before
+	To int64
+}
+
+// CreateAuditLog inserts an audit log entry
+func CreateAuditLog(db *gorm.DB, a *AuditLog) error {
+	return db.Create(a).Error
+}
+
+// FindAuditLogs queries audit logs, newest first
+func FindAuditLogs(db *gorm.DB, f *AuditLogFilter, page int, limit int) (logs []AuditLog, err error) {
+	if page <= 0 {
+		page = 1
+	}
+	err = auditLogQuery(db, f).Select("a.*").Order("a.id desc").Offset((page - 1) * limit).Limit(limit).Find(&logs).Error
+	return
+}
+
+// FindAuditLogsAfter queries the audit logs following the cursor ID, oldest first, for exports
+func FindAuditLogsAfter(db *gorm.DB, f *AuditLogFilter, cursor int64, limit int) (logs []AuditLog, err error) {
+	err = auditLogQuery(db, f).Select("a.*").Where("a.id > ?", cursor).Order("a.id").Limit(limit).Find(&logs).Error
+	return
+}
+
+// CountAuditLogs returns the number of audit logs matching the filter
+func CountAuditLogs(db *gorm.DB, f *AuditLogFilter) (count int64, err error) {
+	err = auditLogQuery(db, f).Count(&count).Error
+	return
+}
+
+// auditLogQuery filters the audit logs of the configured app, unauthenticated requests included
+func auditLogQuery(db *gorm.DB, f *AuditLogFilter) *gorm.DB {
+	query := db.Table("audit_logs as a").
+		Joins("left join users as u on u.id = a.actor_id").
+		Where("(a.actor_id = 0 OR u.app_id = ?)", econf.GetString("shimoSDK.appId"))
+	if f.ActorId != 0 {
+		query = query.Where("a.actor_id = ?", f.ActorId)
+	}
+	if f.Action != "" {
+		query = query.Where("a.action = ?", f.Action)
+	}
+	if f.TargetType != "" {
+		query = query.Where("a.target_type = ?", f.TargetType)
+	}
+	if f.TargetId != "" {
+		query = query.Where("a.target_id = ?", f.TargetId)
+	}
+	if f.From > 0 {
+		query = query.Where("a.created_at >= ?", f.From)
+	}
+	if f.To > 0 {
+		query = query.Where("a.created_at < ?", f.To)
+	}
+	return query
+}
@@ -276,7 +276,7 @@ func LeaveDepartment(db *gorm.DB, userId int64) (err error) {
 	return
 }
 
-// RemoveDepartmentWithMembers deletes a department together with its members
+// RemoveDepartmentWithMembers deletes a department together with its members and its file grants
 func RemoveDepartmentWithMembers(db *gorm.DB, deptId int64) (err error) {
 	err = db.Transaction(func(tx *gorm.DB) error {
 		err = tx.Where("dept_id = ?", deptId).Delete(&DeptMember{}).Error
@@ -288,7 +288,7 @@ func RemoveDepartmentWithMembers(db *gorm.DB, deptId int64) (err error) {
 		if err != nil {
 			return err
 		}
-		return nil
+		return RemoveGroupPermissionsByGroup(tx, GroupDepartment, deptId)
 	})
 	return
 }
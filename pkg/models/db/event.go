@@ -1,29 +1,101 @@
 package db
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gotomicro/cetus/l"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/gotomicro/ego/core/econf"
 	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/events"
 )
 
 // Event represents a system event
 type Event struct {
 	BaseModel
 	// Type is the event type (e.g., Comment, Discussion, MentionAt, etc.)
-	Type string `gorm:"comment:'Event type'" json:"type"`
+	Type string `gorm:"index:idx_event_type;comment:'Event type'" json:"type"`
 	// FileId is the file ID associated with this event
-	FileId string `gorm:"comment:'File ID'" json:"fileId"`
+	FileId string `gorm:"index:idx_event_file_id;comment:'File ID'" json:"fileId"`
 	// UserId is the user ID who triggered this event
 	UserId string `gorm:"index:idx_event_user_id;comment:'Related user ID'" json:"userId"`
+	// Action is the event action from the payload (e.g., create, update, delete)
+	Action string `gorm:"index:idx_event_action;size:64;comment:'Event action'" json:"action"`
 	// RawData is the raw event data in JSON format
 	RawData string `gorm:"comment:'Message content'" json:"rawData"`
 	// Headers is the event headers in JSON format
 	Headers string `gorm:"comment:'Event headers'" json:"headers"`
+	// DedupeKey identifies the delivery so SDK retries are stored once (NULL for rows stored before deduplication)
+	DedupeKey *string `gorm:"uniqueIndex:uniq_event_dedupe_key;size:80;comment:'Delivery dedupe key'" json:"-"`
+}
+
+// defaultEventDedupeWindow is how long a payload is deduplicated when events.dedupeWindow is not set
+const defaultEventDedupeWindow = 10 * time.Minute
+
+// EventDedupeKey returns the key that identifies the delivery of an event
+// The delivery ID header configured by events.deliveryIdHeader is used when the stored headers
+// carry it. Otherwise the key is the SHA-256 of the event type, the raw payload and the time slot
+// of events.dedupeWindow the event was received in, so that the same change made again later is
+// not taken for a retry
+func EventDedupeKey(e *Event) string {
+	if id := eventDeliveryId(e); id != "" {
+		return "id:" + id
+	}
+	return eventHashKey(e, e.CreatedAt/eventDedupeWindow())
+}
+
+// eventDeliveryId returns the delivery ID header of an event, empty when it has none
+func eventDeliveryId(e *Event) string {
+	name := econf.GetString("events.deliveryIdHeader")
+	if name == "" || e.Headers == "" {
+		return ""
+	}
+	headers := map[string]string{}
+	if err := json.Unmarshal([]byte(e.Headers), &headers); err != nil {
+		return ""
+	}
+	if id := headers[http.CanonicalHeaderKey(name)]; len(id) <= 64 {
+		return id
+	}
+	return ""
+}
+
+func eventHashKey(e *Event, slot int64) string {
+	sum := sha256.Sum256([]byte(e.Type + "\n" + e.RawData + "\n" + strconv.FormatInt(slot, 10)))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// eventDedupeWindow returns the dedupe window in seconds
+func eventDedupeWindow() int64 {
+	window := int64(econf.GetDuration("events.dedupeWindow") / time.Second)
+	if window <= 0 {
+		window = int64(defaultEventDedupeWindow / time.Second)
+	}
+	return window
+}
+
+// isEarlierEventReplay tells whether an event keyed by its payload repeats one received less than
+// a dedupe window before it, in the previous time slot. Replays within a slot share its key
+func isEarlierEventReplay(db *gorm.DB, e *Event) (bool, error) {
+	if eventDeliveryId(e) != "" {
+		return false, nil
+	}
+	window := eventDedupeWindow()
+	var count int64
+	err := db.Unscoped().Model(&Event{}).
+		Where("dedupe_key = ? AND created_at >= ?", eventHashKey(e, e.CreatedAt/window-1), e.CreatedAt-window).
+		Count(&count).Error
+	return count > 0, err
 }
 
 // SaveEvent stores an event
@@ -31,41 +103,228 @@ func SaveEvent(db *gorm.DB, e *Event) error {
 	return db.Create(&e).Error
 }
 
+// IngestEvent stores an incoming event together with the notifications of its recipients
+// and the webhook deliveries of the matching subscriptions
+// ok is false when the delivery has already been stored, nothing is written for a replay
+func IngestEvent(db *gorm.DB, e *Event) (notifications []Notification, ok bool, err error) {
+	if e.CreatedAt == 0 {
+		e.CreatedAt = time.Now().Unix()
+	}
+	if e.DedupeKey == nil {
+		key := EventDedupeKey(e)
+		e.DedupeKey = &key
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		replay, err := isEarlierEventReplay(tx, e)
+		if err != nil || replay {
+			return err
+		}
+		res := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "dedupe_key"}}, DoNothing: true}).Create(e)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		ok = true
+		n, err := NotificationsFromEvent(e)
+		if err != nil {
+			return err
+		}
+		notifications = n
+		if err = CreateNotifications(tx, notifications); err != nil {
+			return err
+		}
+		return WebhookDeliveriesFromEvent(tx, e, time.Now().Unix())
+	})
+	return
+}
+
+// DedupeEvents sets the dedupe key of the events stored before deduplication, oldest first
+// An event whose key is already taken is a replay: it is removed together with its
+// notifications and webhook deliveries
+func DedupeEvents(db *gorm.DB, batch int) (updated int64, removed int64, err error) {
+	var lastId int64
+	for {
+		var rows []Event
+		err = db.Unscoped().Where("dedupe_key IS NULL AND id > ?", lastId).Order("id").Limit(batch).Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return
+		}
+		for i := range rows {
+			e := &rows[i]
+			lastId = e.ID
+			key := EventDedupeKey(e)
+			err = db.Transaction(func(tx *gorm.DB) error {
+				var count int64
+				if err := tx.Unscoped().Model(&Event{}).Where("dedupe_key = ?", key).Count(&count).Error; err != nil {
+					return err
+				}
+				if count == 0 {
+					replay, err := isEarlierEventReplay(tx, e)
+					if err != nil {
+						return err
+					}
+					if replay {
+						count = 1
+					}
+				}
+				if count == 0 {
+					updated++
+					return tx.Unscoped().Model(&Event{}).Where("id = ?", e.ID).UpdateColumn("dedupe_key", key).Error
+				}
+				removed++
+				if err := tx.Unscoped().Where("event_id = ?", e.ID).Delete(&Notification{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Unscoped().Where("event_id = ?", e.ID).Delete(&WebhookDelivery{}).Error; err != nil {
+					return err
+				}
+				return tx.Unscoped().Where("id = ?", e.ID).Delete(&Event{}).Error
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// EventFilter selects the events returned by the event queries, empty fields are ignored
+type EventFilter struct {
+	// FileId is the GUID of the file the events happened on
+	FileId string
+	// UserId is the ID of the user who triggered the events
+	UserId string
+	// Types are the accepted event types
+	Types []string
+	// Actions are the accepted event actions
+	Actions []string
+	// From is the Unix timestamp the events are created at or after
+	From int64
+	// To is the Unix timestamp the events are created before
+	To int64
+	// Query is a text the raw event data must contain
+	Query string
+}
+
 // FindAllEvents queries events (defaults: page=1, limit=10, orderBy=created_at)
-func FindAllEvents(db *gorm.DB, e *Event, page int, limit int, orderBy string) (events []Event, err error) {
+func FindAllEvents(db *gorm.DB, f *EventFilter, page int, limit int, orderBy string) (events []Event, err error) {
 	if page <= 0 {
 		page = 1
 	}
 	if orderBy == "" {
 		orderBy = "created_at desc"
 	}
-	query := db.Table("events as e").Select("e.*")
-	if e.FileId != "" {
-		query = query.Where("e.file_id = ?", e.FileId)
+	err = eventQuery(db, f).Select("e.*").Order(orderBy).Offset((page - 1) * limit).Limit(limit).Find(&events).Error
+	return
+}
+
+// FindEventsBefore queries the events older than the cursor event ID, newest first
+// A cursor of 0 starts from the newest event; unlike FindAllEvents deep pages stay as fast as the first one
+func FindEventsBefore(db *gorm.DB, f *EventFilter, cursor int64, limit int) (events []Event, err error) {
+	query := eventQuery(db, f).Select("e.*")
+	if cursor > 0 {
+		query = query.Where("e.id < ?", cursor)
 	}
+	err = query.Order("e.id desc").Limit(limit).Find(&events).Error
+	return
+}
+
+// CountEvents returns the number of events matching the filter
+func CountEvents(db *gorm.DB, f *EventFilter) (count int64, err error) {
+	err = eventQuery(db, f).Count(&count).Error
+	return
+}
+
+const (
+	// EventGroupByType aggregates events per event type
+	EventGroupByType = "type"
+	// EventGroupByDay aggregates events per day
+	EventGroupByDay = "day"
+	// EventGroupByFile aggregates events per file
+	EventGroupByFile = "file"
+)
+
+// EventBucket is the number of events sharing a group key
+type EventBucket struct {
+	// Key is the event type, the file GUID, or the Unix timestamp of the start of the day
+	Key string `json:"key"`
+	// Count is the number of events in the bucket
+	Count int64 `json:"count"`
+}
 
-	if e.UserId != "" {
-		query = query.Where("e.user_id = ?", e.UserId)
+// AggregateEvents counts the events matching the filter per type, day or file
+// Types and files are ordered by count, days by date; utcOffset is the offset in seconds of
+// the time zone the days are cut in
+func AggregateEvents(db *gorm.DB, f *EventFilter, groupBy string, utcOffset int, limit int) (buckets []EventBucket, err error) {
+	query := eventQuery(db, f)
+	switch groupBy {
+	case EventGroupByType:
+		query = query.Select("e.type AS `key`, COUNT(*) AS count").Group("e.type").Order("count desc, `key`")
+	case EventGroupByFile:
+		query = query.Select("e.file_id AS `key`, COUNT(*) AS count").Where("e.file_id <> ''").Group("e.file_id").Order("count desc, `key`")
+	case EventGroupByDay:
+		// Modulo keeps the expression portable across MySQL and SQLite
+		day := fmt.Sprintf("e.created_at - (e.created_at + %d) %% 86400", utcOffset)
+		query = query.Select(day + " AS `key`, COUNT(*) AS count").Group(day).Order("`key`")
+	default:
+		return nil, fmt.Errorf("unknown group %q", groupBy)
 	}
-	err = query.Joins("left join users as u on u.id = e.user_id").Joins("left join files as f on f.id = e.file_id").Where("u.app_id = ?", econf.GetString("shimoSDK.appId")).Order(orderBy).Offset((page - 1) * limit).Limit(limit).Find(&events).Error
+	err = query.Limit(limit).Scan(&buckets).Error
 	return
 }
 
-// CountEvents returns the event count
-// Filters by FileId or UserId depending on which field is provided
-func CountEvents(db *gorm.DB, e *Event) (count int64, err error) {
-	query := db.Table("events as e")
-	if e.FileId != "" {
-		query = query.Where("e.file_id = ?", e.FileId)
+// FindEventTypes returns the distinct types of the stored events, soft-deleted ones included
+func FindEventTypes(db *gorm.DB) (types []string, err error) {
+	err = db.Unscoped().Model(&Event{}).Distinct("type").Pluck("type", &types).Error
+	return
+}
+
+// FindExpiredEvents fetches events of a type created before the given Unix timestamp, oldest first
+func FindExpiredEvents(db *gorm.DB, eventType string, before int64, limit int) (events []Event, err error) {
+	err = db.Unscoped().
+		Where("type = ? AND created_at < ?", eventType, before).
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	return
+}
+
+// PurgeEvents hard-deletes events by ID, notifications and webhook deliveries keep their own copy of the event
+func PurgeEvents(db *gorm.DB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
 	}
+	return db.Unscoped().Where("id IN ?", ids).Delete(&Event{}).Error
+}
 
-	if e.UserId != "" {
-		query = query.Where("e.user_id = ?", e.UserId)
+// eventQuery filters the events of the configured app
+func eventQuery(db *gorm.DB, f *EventFilter) *gorm.DB {
+	query := db.Table("events as e")
+	if f.FileId != "" {
+		query = query.Where("e.file_id = ?", f.FileId)
 	}
-	err = query.Joins("left join users as u on u.id = e.user_id").Joins("left join files as f on f.id = e.file_id").Where("u.app_id = ?", econf.GetString("shimoSDK.appId")).Count(&count).Error
-	return
+	if f.UserId != "" {
+		query = query.Where("e.user_id = ?", f.UserId)
+	}
+	if len(f.Types) > 0 {
+		query = query.Where("e.type IN ?", f.Types)
+	}
+	if len(f.Actions) > 0 {
+		query = query.Where("e.action IN ?", f.Actions)
+	}
+	if f.From > 0 {
+		query = query.Where("e.created_at >= ?", f.From)
+	}
+	if f.To > 0 {
+		query = query.Where("e.created_at < ?", f.To)
+	}
+	if f.Query != "" {
+		query = query.Where("e.raw_data LIKE ? ESCAPE '!'", "%"+likeEscaper.Replace(f.Query)+"%")
+	}
+	return query.Joins("join users as u on u.id = e.user_id").Where("u.app_id = ?", econf.GetString("shimoSDK.appId"))
 }
 
+// likeEscaper escapes the LIKE wildcards with the '!' escape character, which MySQL and SQLite both accept
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
 // EventWithDetails extends Event with related file and user information
 type EventWithDetails struct {
 	Event
@@ -95,33 +354,12 @@ func GetEventFileAndUserIds(events []Event) (es []*EventWithDetails, fileIds []s
 
 // GetFileAndUserIdsFromEvent extracts the file and user IDs for a single event
 func GetFileAndUserIdsFromEvent(e *Event) (event *EventWithDetails, fileIds []string, userIds []string, err error) {
-	var data map[string]interface{}
-	err = json.Unmarshal([]byte(e.RawData), &data)
+	p, err := events.Parse(e.Type, []byte(e.RawData))
 	if err != nil {
 		return
 	}
-
-	switch e.Type {
-	case "Comment":
-		fileIds, userIds = idsInCommit(data)
-	case "Discussion":
-		fileIds, userIds = idsInDiscussion(data)
-	case "MentionAt":
-		fileIds, userIds = idsInMentionAt(data)
-	case "DateMention":
-		fileIds, userIds = idsInDateMention(data)
-	case "FileContent":
-		fileIds, userIds = idsInFileContent(data)
-	case "Collaborator":
-		fileIds, userIds = idsInCollaborator(data)
-	default:
-		if userId, ok := data["userId"].(string); ok {
-			userIds = append(userIds, userId)
-		}
-		if fileId, ok := data["fileId"].(string); ok {
-			fileIds = append(fileIds, fileId)
-		}
-	}
+	fileIds = p.FileIds()
+	userIds = p.UserIds()
 
 	event = &EventWithDetails{
 		Event:   *e,
@@ -166,144 +404,3 @@ func BindUserAndFilesInfo(events []*EventWithDetails, userMap map[string]User, f
 	}
 	return
 }
-
-func idsInCommit(data map[string]interface{}) (fileIds []string, userIds []string) {
-	if fileId, ok := data["fileId"].(string); ok {
-		fileIds = append(fileIds, fileId)
-	}
-	if userId, ok := data["userId"].(string); ok {
-		userIds = append(userIds, userId)
-	}
-
-	action, ok := data["action"].(string)
-	if !ok {
-		return
-	}
-
-	switch action {
-	case "update":
-	case "create":
-		uids := getStringSlice(data, "comment", "userIds")
-		userIds = append(userIds, uids...)
-	}
-	return
-}
-
-func idsInDiscussion(data map[string]interface{}) (fileIds []string, userIds []string) {
-	if fileId, ok := data["fileId"].(string); ok {
-		fileIds = append(fileIds, fileId)
-	}
-	if userId, ok := data["userId"].(string); ok {
-		userIds = append(userIds, userId)
-	}
-	return
-}
-
-func idsInMentionAt(data map[string]interface{}) (fileIds []string, userIds []string) {
-	if fileId, ok := data["fileId"].(string); ok {
-		fileIds = append(fileIds, fileId)
-	}
-	if userId, ok := data["userId"].(string); ok {
-		userIds = append(userIds, userId)
-	}
-
-	typ, ok := data["type"].(string)
-	if !ok {
-		return
-	}
-
-	switch typ {
-	case "comment":
-		uids := getStringSlice(data, "comment", "userIds")
-		userIds = append(userIds, uids...)
-	case "discussion":
-		uids := getStringSlice(data, "discussion", "userIds")
-		userIds = append(userIds, uids...)
-	case "mention_at":
-		uids := getStringSlice(data, "mentionAt", "userId")
-		userIds = append(userIds, uids...)
-	}
-
-	return
-}
-
-func idsInDateMention(data map[string]interface{}) (fileIds []string, userIds []string) {
-	if fileId, ok := data["fileId"].(string); ok {
-		fileIds = append(fileIds, fileId)
-	}
-	if userId, ok := data["userId"].(string); ok {
-		userIds = append(userIds, userId)
-	}
-
-	action, ok := data["action"].(string)
-	if !ok {
-		return
-	}
-
-	switch action {
-	case "create":
-		fids := getStringSlice(data, "createData", "fileId")
-		fileIds = append(fileIds, fids...)
-
-		uids := getStringSlice(data, "createData", "authorId")
-		userIds = append(userIds, uids...)
-
-		uids = getStringSlice(data, "createData", "remindUserIds")
-		userIds = append(userIds, uids...)
-
-	case "update":
-		uids := getStringSlice(data, "updateData", "remindUserIds")
-		userIds = append(userIds, uids...)
-	}
-	return
-}
-
-func idsInFileContent(data map[string]interface{}) (fileIds []string, userIds []string) {
-	if fileId, ok := data["fileId"].(string); ok {
-		fileIds = append(fileIds, fileId)
-	}
-	if userId, ok := data["userId"].(string); ok {
-		userIds = append(userIds, userId)
-	}
-	if m, ok := data["autoMention"].(map[string]any); ok {
-		switch v := m["editorProviderId"].(type) {
-		case string:
-			userIds = append(userIds, v)
-		case float64:
-			userIds = append(userIds, strconv.FormatInt(int64(v), 10))
-		}
-	}
-	return
-}
-
-func idsInCollaborator(data map[string]interface{}) (fileIds []string, userIds []string) {
-	if fileId, ok := data["fileId"].(string); ok {
-		fileIds = append(fileIds, fileId)
-	}
-	if userId, ok := data["userId"].(string); ok {
-		userIds = append(userIds, userId)
-	}
-	return
-}
-
-func getStringSlice(data map[string]interface{}, t1, t2 string) []string {
-	var res []string
-	if comment, ok := data[t1].(map[string]interface{}); ok {
-		if t1 == "mentionAt" {
-			if str, ok := comment[t2].(string); ok {
-				res = append(res, str)
-			}
-		} else {
-			if str, ok := comment[t2].([]interface{}); ok {
-				strSlice := make([]string, len(str))
-				for i, v := range str {
-					if str, ok := v.(string); ok {
-						strSlice[i] = str
-					}
-				}
-				res = append(res, strSlice...)
-			}
-		}
-	}
-	return res
-}
@@ -4,11 +4,9 @@ import (
 	"errors"
 
 	"github.com/gotomicro/cetus/l"
-	"github.com/shimo-open/sdk-kit-go"
 
 	"sdk-demo-go/pkg/utils"
 
-	"github.com/gotomicro/ego/core/econf"
 	"github.com/gotomicro/ego/core/elog"
 	"gorm.io/gorm"
 )
@@ -30,19 +28,37 @@ type File struct {
 	IsShimoFile int `gorm:"comment:'Is Shimo file'" json:"isShimoFile"`
 	// ShimoType is the Shimo file type (document, spreadsheet, presentation, etc.)
 	ShimoType string `gorm:"comment:'Shimo file type'" json:"shimoType"`
+	// ParentGuid is the GUID of the folder containing this file (empty for the root)
+	ParentGuid string `gorm:"index:idx_file_parent_guid;size:64;comment:'Parent folder GUID'" json:"parentId"`
+	// IsFolder indicates whether this is a folder rather than a file
+	IsFolder bool `gorm:"comment:'Is folder'" json:"isFolder"`
 	// Permissions contains the file permissions (populated on demand, not stored in DB)
 	Permissions Permissions `gorm:"-" json:"permissions"`
 	// Role is the user's role for this file (populated on demand, not stored in DB)
 	Role string `gorm:"-" json:"role"`
+	// Starred indicates whether the user starred this file (populated on demand, not stored in DB)
+	Starred bool `gorm:"-" json:"starred"`
+	// Pinned indicates whether the user pinned this file (populated on demand, not stored in DB)
+	Pinned bool `gorm:"-" json:"pinned"`
+	// Tags are the tags of this file (populated on demand, not stored in this table)
+	Tags []string `gorm:"-" json:"tags,omitempty"`
+	// Metadata is the custom key/value metadata of this file (populated on demand, not stored in this table)
+	Metadata map[string]string `gorm:"-" json:"metadata,omitempty"`
 }
 
 func (f *File) TableName() string {
 	return "files"
 }
 
-// FindFileByUserId fetches up to 100 files for a user, ordered by creation time (desc)
-// Because a user ID is provided, permissions are loaded by default
+// FindFileByUserId fetches up to 100 files shared with a user, directly or through its teams and
+// departments, ordered by creation time (desc)
+// Because a user ID is provided, the effective permissions are loaded by default
 func FindFileByUserId(db *gorm.DB, userId int64, limit int, orderBy string) (files []File, err error) {
+	return FindFilteredFilesByUserId(db, userId, FileFilter{}, limit, orderBy)
+}
+
+// FindFilteredFilesByUserId is FindFileByUserId restricted to the files matching the filter
+func FindFilteredFilesByUserId(db *gorm.DB, userId int64, filter FileFilter, limit int, orderBy string) (files []File, err error) {
 	if orderBy == "" {
 		orderBy = "created_at"
 	}
@@ -50,30 +66,16 @@ func FindFileByUserId(db *gorm.DB, userId int64, limit int, orderBy string) (fil
 		limit = 100
 	}
 
-	var fps []FilePermissions
-	err = db.Model(&FilePermissions{}).Where("user_id = ?", userId).Order("id desc").Limit(limit).Find(&fps).Error
+	query, err := sharedWithUser(db, filter.apply(db, db.Model(&File{})), userId)
 	if err != nil {
 		return
 	}
-
-	fileIds := make([]int64, len(fps))
-	permissions := map[int64]map[string]bool{} // Holds permissions for each file
-	role := map[int64]string{}
-	for i := range fps {
-		fileIds[i] = fps[i].FileId
-		permissions[fps[i].FileId] = fps[i].Permissions
-		role[fps[i].FileId] = fps[i].Role
-	}
-
-	err = db.Where("id IN ?", fileIds).Order(orderBy + " DESC").Find(&files).Error
+	err = query.Order(orderBy + " DESC").Limit(limit).Find(&files).Error
 	if err != nil {
 		return
 	}
 
-	for i := range files {
-		files[i].Permissions = permissions[files[i].ID]
-		files[i].Role = role[files[i].ID]
-	}
+	_, err = ResolveFilePermissions(db, userId, files)
 	return
 }
 
@@ -137,16 +139,9 @@ func CreateFile(db *gorm.DB, file *File, userId int64, permissions ...map[string
 
 		fileId = file.ID
 
-		filePermissionsList := make(map[string]bool)
-
+		filePermissionsList := fullFilePermissions()
 		if len(permissions) > 0 {
 			filePermissionsList = permissions[0]
-		} else {
-			filePermissionsList = sdk.HandleBasicFilePermission(true)
-
-			if econf.GetBool("permissions.setNewFilePermission") {
-				filePermissionsList = sdk.HandleFilePermission(true)
-			}
 		}
 
 		fp := FilePermissions{
@@ -176,7 +171,11 @@ func RemoveFileByGuid(db *gorm.DB, fileGuid string) (err error) {
 	if err != nil {
 		return err
 	}
-	return db.Where("file_id = ?", f.ID).Delete(&FilePermissions{}).Error
+	err = db.Where("file_id = ?", f.ID).Delete(&FilePermissions{}).Error
+	if err != nil {
+		return err
+	}
+	return RemoveGroupPermissionsByFileIds(db, []int64{f.ID})
 }
 
 // RemoveFileByGuids deletes multiple files by GUID
@@ -184,7 +183,7 @@ func RemoveFileByGuids(db *gorm.DB, fileGuids []string) (err error) {
 	err = db.Transaction(func(tx *gorm.DB) error {
 		files := make([]File, 0)
 		err = db.Where("guid IN ?", fileGuids).Find(&files).Error
-		ids := make([]int64, len(files))
+		ids := make([]int64, 0, len(files))
 		for _, v := range files {
 			ids = append(ids, v.ID)
 		}
@@ -193,7 +192,10 @@ func RemoveFileByGuids(db *gorm.DB, fileGuids []string) (err error) {
 			return err
 		}
 		err = db.Where("file_id IN ?", ids).Delete(&FilePermissions{}).Error
-		return err
+		if err != nil {
+			return err
+		}
+		return RemoveGroupPermissionsByFileIds(db, ids)
 	})
 	return
 }
@@ -204,33 +206,48 @@ func RemoveFileById(db *gorm.DB, fileId int64) (err error) {
 	if err != nil {
 		return err
 	}
-	return db.Where("file_id = ?", fileId).Delete(&FilePermissions{}).Error
+	err = db.Where("file_id = ?", fileId).Delete(&FilePermissions{}).Error
+	if err != nil {
+		return err
+	}
+	return RemoveGroupPermissionsByFileIds(db, []int64{fileId})
 }
 
 // FindFileByGuidAndUserId fetches a file scoped to a user
-// Loads permissions because the user ID is known
+// Loads the effective permissions because the user ID is known
 func FindFileByGuidAndUserId(db *gorm.DB, userId int64, guid string) (file *File, err error) {
 	file, err = FindFileByGuid(db, guid)
 	if err != nil {
 		return
 	}
-	// Special handling for anonymous form access
-	if userId < 0 && file.IsShimoFile == 1 && file.ShimoType == "form" {
-		elog.Debug("file is form, anonymous user", elog.FieldComponent("db"))
-		return
+	files := []File{*file}
+	if _, err = ResolveFilePermissions(db, userId, files); err != nil {
+		return nil, err
 	}
+	return &files[0], nil
+}
 
-	fp := FilePermissions{}
-	err = db.Where("user_id = ? AND file_id = ?", userId, file.ID).First(&fp).Error
+// FindFileByGuidWithShareLink fetches a file scoped to a user who may hold a share link token
+// A link that is inactive or shares another file is ignored
+func FindFileByGuidWithShareLink(db *gorm.DB, userId int64, guid string, shareToken string) (file *File, err error) {
+	if shareToken == "" {
+		return FindFileByGuidAndUserId(db, userId, guid)
+	}
+	link, err := FindActiveShareLink(db, shareToken)
 	if err != nil {
-		// Ignore missing permission records for forms
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			file.Permissions = Permissions{}
-			return file, nil
+		if !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, ErrShareLinkInactive) {
+			return nil, err
 		}
-		return
+		link = nil
 	}
 
-	file.Permissions = fp.Permissions
-	return
+	file, err = FindFileByGuid(db, guid)
+	if err != nil {
+		return
+	}
+	files := []File{*file}
+	if _, err = ResolveFilePermissionsWithLink(db, userId, link, files); err != nil {
+		return nil, err
+	}
+	return &files[0], nil
 }
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// FileActivityCreate is recorded when a file is created, uploaded, imported or duplicated into
+	FileActivityCreate = "create"
+	// FileActivityRename is recorded when a file is renamed
+	FileActivityRename = "rename"
+	// FileActivityDuplicate is recorded on the source file when it is duplicated
+	FileActivityDuplicate = "duplicate"
+	// FileActivityMove is recorded when a file is moved to another folder
+	FileActivityMove = "move"
+	// FileActivityDelete is recorded when a file is moved to the recycle bin
+	FileActivityDelete = "delete"
+	// FileActivityRestore is recorded when a file is restored from the recycle bin
+	FileActivityRestore = "restore"
+	// FileActivityPurge is recorded when a file is permanently deleted from the recycle bin
+	FileActivityPurge = "purge"
+	// FileActivityPermission is recorded when the collaborators of a file are updated
+	FileActivityPermission = "permission"
+)
+
+// FileActivity represents an action taken on a file through the demo itself, as opposed to
+// the callback events reported by the Shimo SDK
+type FileActivity struct {
+	BaseModel
+	// FileGuid is the GUID of the file the action was taken on
+	FileGuid string `gorm:"index:idx_file_activity_file_guid;comment:'File GUID'" json:"fileId"`
+	// UserId is the ID of the user who took the action
+	UserId int64 `gorm:"comment:'Actor user ID'" json:"userId"`
+	// Action is the action taken (create/rename/duplicate/move/delete/permission)
+	Action string `gorm:"comment:'Action'" json:"action"`
+	// Detail holds the action specific data (e.g., the old and new name of a rename)
+	Detail ActivityDetail `gorm:"type:text;comment:'Action detail'" json:"detail"`
+}
+
+// ActivityDetail is a JSON object of action specific data
+type ActivityDetail map[string]interface{}
+
+// TableName returns the database table name for FileActivity
+func (a *FileActivity) TableName() string {
+	return "file_activities"
+}
+
+func (d ActivityDetail) Value() (driver.Value, error) {
+	v, err := json.Marshal(d)
+	return string(v), err
+}
+
+func (d *ActivityDetail) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = ActivityDetail{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	default:
+		return fmt.Errorf("failed to scan ActivityDetail, unexpected type: %T", v)
+	}
+}
+
+// CreateFileActivity records an action taken on a file
+func CreateFileActivity(db *gorm.DB, a *FileActivity) error {
+	return db.Create(a).Error
+}
+
+// FindFileActivities fetches the latest actions taken on a file, newest first
+func FindFileActivities(db *gorm.DB, fileGuid string, limit int) (activities []FileActivity, err error) {
+	err = db.Where("file_guid = ?", fileGuid).Order("created_at desc, id desc").Limit(limit).Find(&activities).Error
+	return
+}
+
+// CountFileActivities returns the number of actions taken on a file
+func CountFileActivities(db *gorm.DB, fileGuid string) (count int64, err error) {
+	err = db.Model(&FileActivity{}).Where("file_guid = ?", fileGuid).Count(&count).Error
+	return
+}
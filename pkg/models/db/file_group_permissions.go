@@ -0,0 +1,153 @@
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kinds of groups a file can be shared with
+const (
+	// GroupTeam shares a file with every member of a team
+	GroupTeam = "team"
+	// GroupDepartment shares a file with every member of a department and of its sub-departments
+	GroupDepartment = "department"
+)
+
+// FileGroupPermissions represents the permissions every member of a team or department has for a file
+// Members are resolved when the permissions are checked, so users joining the group later get them too
+type FileGroupPermissions struct {
+	BaseModel
+	// FileId is the file ID
+	FileId int64 `gorm:"uniqueIndex:uniq_file_group;comment:'File ID'" json:"fileId"`
+	// GroupType is the kind of group (team/department)
+	GroupType string `gorm:"uniqueIndex:uniq_file_group;index:idx_file_group_group;size:16;comment:'Group type (team/department)'" json:"groupType"`
+	// GroupId is the team or department ID
+	GroupId int64 `gorm:"uniqueIndex:uniq_file_group;index:idx_file_group_group;comment:'Team or department ID'" json:"groupId"`
+	// Permissions is a JSON object of permission flags (stored as TEXT in DB)
+	Permissions Permissions `gorm:"comment:'Permissions'" json:"permissions"`
+}
+
+func (gp *FileGroupPermissions) TableName() string {
+	return "file_group_permissions"
+}
+
+// Group returns the label of the group, e.g. "department:12"
+func (gp *FileGroupPermissions) Group() string {
+	return fmt.Sprintf("%s:%d", gp.GroupType, gp.GroupId)
+}
+
+// FindFileGroupPermissionsByFileId fetches every group grant of a file
+func FindFileGroupPermissionsByFileId(db *gorm.DB, fileId int64) (gps []FileGroupPermissions, err error) {
+	err = db.Where("file_id = ?", fileId).Order("id").Find(&gps).Error
+	return
+}
+
+// BatchSaveGroupPermissions upserts group grants in bulk
+// If a [fileId, groupType, groupId] conflict occurs, overwrite the existing record instead of adding a new one
+func BatchSaveGroupPermissions(db *gorm.DB, gps []FileGroupPermissions) (err error) {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_id"}, {Name: "group_type"}, {Name: "group_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"permissions"}),
+	}).Create(&gps).Error
+}
+
+// RemoveGroupPermissions deletes the grant of a group on a file
+func RemoveGroupPermissions(db *gorm.DB, fileId int64, groupType string, groupId int64) (err error) {
+	return db.Unscoped().
+		Where("file_id = ? AND group_type = ? AND group_id = ?", fileId, groupType, groupId).
+		Delete(&FileGroupPermissions{}).Error
+}
+
+// RemoveGroupPermissionsByFileIds deletes every group grant of the given files
+func RemoveGroupPermissionsByFileIds(db *gorm.DB, fileIds []int64) (err error) {
+	return db.Unscoped().Where("file_id IN ?", fileIds).Delete(&FileGroupPermissions{}).Error
+}
+
+// RemoveGroupPermissionsByGroup deletes every grant of a group, used when the group is deleted
+func RemoveGroupPermissionsByGroup(db *gorm.DB, groupType string, groupId int64) (err error) {
+	return db.Unscoped().
+		Where("group_type = ? AND group_id = ?", groupType, groupId).
+		Delete(&FileGroupPermissions{}).Error
+}
+
+// FindUserGroups returns the teams of a user and its departments, including the departments above them
+// since a department grant covers the sub-departments
+func FindUserGroups(db *gorm.DB, userId int64) (teamIds []int64, deptIds []int64, err error) {
+	err = db.Model(&TeamRole{}).Where("user_id = ?", userId).Pluck("team_id", &teamIds).Error
+	if err != nil {
+		return
+	}
+
+	var pending []int64
+	err = db.Model(&DeptMember{}).Where("user_id = ?", userId).Pluck("dept_id", &pending).Error
+	if err != nil {
+		return
+	}
+	seen := map[int64]bool{}
+	for len(pending) > 0 {
+		var next []int64
+		for _, id := range pending {
+			if id != 0 && !seen[id] {
+				seen[id] = true
+				deptIds = append(deptIds, id)
+				next = append(next, id)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		pending = nil
+		err = db.Model(&Department{}).Where("id IN ?", next).Pluck("parent_id", &pending).Error
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// FindGroupMemberIds returns the members of a team, or of a department and its sub-departments
+func FindGroupMemberIds(db *gorm.DB, groupType string, groupId int64) (userIds []int64, err error) {
+	if groupType == GroupTeam {
+		return FindTeamAllMembersByTeamId(db, groupId)
+	}
+
+	deptIds := []int64{groupId}
+	seen := map[int64]bool{groupId: true}
+	for parents := deptIds; len(parents) > 0; {
+		var children []int64
+		err = db.Model(&Department{}).Where("parent_id IN ?", parents).Pluck("id", &children).Error
+		if err != nil {
+			return
+		}
+		// A department listed as a descendant of itself must not loop forever
+		parents = nil
+		for _, id := range children {
+			if !seen[id] {
+				seen[id] = true
+				deptIds = append(deptIds, id)
+				parents = append(parents, id)
+			}
+		}
+	}
+	err = db.Model(&DeptMember{}).Where("dept_id IN ?", deptIds).Distinct().Pluck("user_id", &userIds).Error
+	return
+}
+
+// sharedWithUser restricts a File query to the files a user has a grant on, directly or through its groups
+func sharedWithUser(db *gorm.DB, query *gorm.DB, userId int64) (*gorm.DB, error) {
+	teamIds, deptIds, err := FindUserGroups(db, userId)
+	if err != nil {
+		return nil, err
+	}
+	return query.Where("(id IN (?) OR id IN (?))",
+		db.Model(&FilePermissions{}).Select("file_id").Where("user_id = ?", userId),
+		groupPermissionsQuery(db.Model(&FileGroupPermissions{}).Select("file_id"), teamIds, deptIds)), nil
+}
+
+// groupPermissionsQuery restricts a FileGroupPermissions query to the grants of the given groups
+func groupPermissionsQuery(db *gorm.DB, teamIds []int64, deptIds []int64) *gorm.DB {
+	return db.Where("((group_type = ? AND group_id IN ?) OR (group_type = ? AND group_id IN ?))",
+		GroupTeam, teamIds, GroupDepartment, deptIds)
+}
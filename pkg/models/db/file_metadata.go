@@ -0,0 +1,188 @@
+package db
+
+import (
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FileTag represents a user-defined tag on a file
+type FileTag struct {
+	BaseModel
+	// FileId is the file ID
+	FileId int64 `gorm:"uniqueIndex:uniq_file_tag;comment:'File ID'" json:"fileId"`
+	// Tag is the tag text
+	Tag string `gorm:"uniqueIndex:uniq_file_tag;index:idx_file_tag_tag;size:64;comment:'Tag'" json:"tag"`
+	// CreatorId is the ID of the user who added the tag
+	CreatorId int64 `gorm:"comment:'Creator ID'" json:"creatorId"`
+}
+
+func (t *FileTag) TableName() string {
+	return "file_tags"
+}
+
+// FileMetadata represents a custom key/value pair on a file
+type FileMetadata struct {
+	BaseModel
+	// FileId is the file ID
+	FileId int64 `gorm:"uniqueIndex:uniq_file_metadata;comment:'File ID'" json:"fileId"`
+	// Name is the metadata key
+	Name string `gorm:"uniqueIndex:uniq_file_metadata;index:idx_file_metadata_name_value;size:64;comment:'Metadata key'" json:"name"`
+	// Value is the metadata value
+	Value string `gorm:"index:idx_file_metadata_name_value;size:255;comment:'Metadata value'" json:"value"`
+}
+
+func (m *FileMetadata) TableName() string {
+	return "file_metadata"
+}
+
+// FileFilter restricts file lists to the files carrying every tag and every metadata pair
+type FileFilter struct {
+	// Tags are the tags the files must all have
+	Tags []string
+	// Metadata are the key/value pairs the files must all have
+	Metadata map[string]string
+}
+
+// apply adds the filter conditions to a File query
+func (f FileFilter) apply(db *gorm.DB, query *gorm.DB) *gorm.DB {
+	for _, tag := range f.Tags {
+		query = query.Where("id IN (?)", db.Model(&FileTag{}).Select("file_id").Where("tag = ?", tag))
+	}
+	for name, value := range f.Metadata {
+		query = query.Where("id IN (?)",
+			db.Model(&FileMetadata{}).Select("file_id").Where("name = ? AND value = ?", name, value))
+	}
+	return query
+}
+
+// FindFileTags fetches the tags of a file, sorted
+func FindFileTags(db *gorm.DB, fileId int64) (tags []string, err error) {
+	err = db.Model(&FileTag{}).Where("file_id = ?", fileId).Order("tag").Pluck("tag", &tags).Error
+	return
+}
+
+// AddFileTags adds tags to a file, the tags it already has are left untouched
+func AddFileTags(db *gorm.DB, fileId int64, creatorId int64, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	rows := make([]FileTag, len(tags))
+	for i, tag := range tags {
+		rows[i] = FileTag{FileId: fileId, Tag: tag, CreatorId: creatorId}
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// ReplaceFileTags replaces every tag of a file
+func ReplaceFileTags(db *gorm.DB, fileId int64, creatorId int64, tags []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("file_id = ?", fileId).Delete(&FileTag{}).Error; err != nil {
+			return err
+		}
+		return AddFileTags(tx, fileId, creatorId, tags)
+	})
+}
+
+// RemoveFileTag removes a tag from a file
+func RemoveFileTag(db *gorm.DB, fileId int64, tag string) error {
+	return db.Unscoped().Where("file_id = ? AND tag = ?", fileId, tag).Delete(&FileTag{}).Error
+}
+
+// FindFileMetadata fetches the metadata of a file, only the given keys when there are any
+func FindFileMetadata(db *gorm.DB, fileId int64, names ...string) (map[string]string, error) {
+	query := db.Where("file_id = ?", fileId)
+	if len(names) > 0 {
+		query = query.Where("name IN ?", names)
+	}
+	var rows []FileMetadata
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	res := make(map[string]string, len(rows))
+	for _, row := range rows {
+		res[row.Name] = row.Value
+	}
+	return res, nil
+}
+
+// UpdateFileMetadata sets the given metadata of a file, a nil value removes the key
+func UpdateFileMetadata(db *gorm.DB, fileId int64, values map[string]*string) error {
+	var rows []FileMetadata
+	var removed []string
+	for name, value := range values {
+		if value == nil {
+			removed = append(removed, name)
+			continue
+		}
+		rows = append(rows, FileMetadata{FileId: fileId, Name: name, Value: *value})
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if len(removed) > 0 {
+			err := tx.Unscoped().Where("file_id = ? AND name IN ?", fileId, removed).Delete(&FileMetadata{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "file_id"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).Create(&rows).Error
+	})
+}
+
+// LoadFileTagsAndMetadata sets the Tags and Metadata of files, only the given metadata keys when there are any
+func LoadFileTagsAndMetadata(db *gorm.DB, files []File, names ...string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(files))
+	for i := range files {
+		ids[i] = files[i].ID
+	}
+	var tags []FileTag
+	if err := db.Where("file_id IN ?", ids).Find(&tags).Error; err != nil {
+		return err
+	}
+	query := db.Where("file_id IN ?", ids)
+	if len(names) > 0 {
+		query = query.Where("name IN ?", names)
+	}
+	var metadata []FileMetadata
+	if err := query.Find(&metadata).Error; err != nil {
+		return err
+	}
+
+	tagsById := map[int64][]string{}
+	for _, t := range tags {
+		tagsById[t.FileId] = append(tagsById[t.FileId], t.Tag)
+	}
+	metadataById := map[int64]map[string]string{}
+	for _, m := range metadata {
+		if metadataById[m.FileId] == nil {
+			metadataById[m.FileId] = map[string]string{}
+		}
+		metadataById[m.FileId][m.Name] = m.Value
+	}
+	for i := range files {
+		files[i].Tags = tagsById[files[i].ID]
+		sort.Strings(files[i].Tags)
+		files[i].Metadata = metadataById[files[i].ID]
+		if files[i].Metadata == nil {
+			files[i].Metadata = map[string]string{}
+		}
+	}
+	return nil
+}
+
+// RemoveFileTagsAndMetadataByFileIds deletes the tags and metadata of the given files, used when they are purged
+func RemoveFileTagsAndMetadataByFileIds(db *gorm.DB, fileIds []int64) error {
+	if err := db.Unscoped().Where("file_id IN ?", fileIds).Delete(&FileTag{}).Error; err != nil {
+		return err
+	}
+	return db.Unscoped().Where("file_id IN ?", fileIds).Delete(&FileMetadata{}).Error
+}
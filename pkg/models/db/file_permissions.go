@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/shimo-open/sdk-kit-go"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+
+	"sdk-demo-go/pkg/access"
 )
 
 // FilePermissions represents the permissions a user has for a file
@@ -90,3 +94,143 @@ func RemovePermissionsByFileId(db *gorm.DB, fileId int64, userId int64) (err err
 		Where("file_id = ? AND user_id = ?", fileId, userId).
 		Delete(&FilePermissions{}).Error
 }
+
+// ResolveFilePermissions computes the effective permissions of a user on files from the creator, the
+// grants of the user and of its teams and departments on the files and on their parent folders, the
+// form rules and the app default (see access.Resolve).
+// It sets the Permissions and Role of the files and returns the explained results in the same order
+func ResolveFilePermissions(db *gorm.DB, userId int64, files []File) (results []*access.Result, err error) {
+	return ResolveFilePermissionsWithLink(db, userId, nil, files)
+}
+
+// ResolveFilePermissionsWithLink is ResolveFilePermissions for a user who opened the files with a share
+// link, the link adds its flags to the shared file and to everything below it
+func ResolveFilePermissionsWithLink(db *gorm.DB, userId int64, link *ShareLink, files []File) (results []*access.Result, err error) {
+	folders := map[string]*File{}
+	var pending []string
+	for i := range files {
+		if files[i].ParentGuid != "" {
+			pending = append(pending, files[i].ParentGuid)
+		}
+	}
+	for depth := 0; len(pending) > 0 && depth < maxFolderDepth; depth++ {
+		var parents []File
+		if parents, err = FindFilesByGuids(db, pending); err != nil {
+			return nil, err
+		}
+		pending = nil
+		for i := range parents {
+			folders[parents[i].Guid] = &parents[i]
+			if p := parents[i].ParentGuid; p != "" && folders[p] == nil {
+				pending = append(pending, p)
+			}
+		}
+	}
+
+	// Anonymous users have no grants, the anonymous rule decides for them
+	grants := map[int64]FilePermissions{}
+	groupGrants := map[int64][]access.Layer{}
+	if userId >= 0 {
+		fileIds := make([]int64, 0, len(files)+len(folders))
+		for i := range files {
+			fileIds = append(fileIds, files[i].ID)
+		}
+		for _, folder := range folders {
+			fileIds = append(fileIds, folder.ID)
+		}
+		var fps []FilePermissions
+		if err = db.Where("user_id = ? AND file_id IN ?", userId, fileIds).Find(&fps).Error; err != nil {
+			return nil, err
+		}
+		for _, fp := range fps {
+			grants[fp.FileId] = fp
+		}
+
+		var teamIds, deptIds []int64
+		if teamIds, deptIds, err = FindUserGroups(db, userId); err != nil {
+			return nil, err
+		}
+		if len(teamIds) > 0 || len(deptIds) > 0 {
+			var gps []FileGroupPermissions
+			err = groupPermissionsQuery(db.Where("file_id IN ?", fileIds), teamIds, deptIds).Order("id").Find(&gps).Error
+			if err != nil {
+				return nil, err
+			}
+			for _, gp := range gps {
+				groupGrants[gp.FileId] = append(groupGrants[gp.FileId], access.Layer{Group: gp.Group(), Permissions: gp.Permissions})
+			}
+		}
+	}
+
+	full := fullFilePermissions()
+	appDefault := appDefaultPermissions()
+	results = make([]*access.Result, len(files))
+	for i := range files {
+		in := &access.Input{
+			Full:       full,
+			Creator:    userId > 0 && files[i].CreatorId == userId,
+			Anonymous:  userId < 0,
+			Form:       isForm(&files[i]),
+			Direct:     grants[files[i].ID].Permissions,
+			Groups:     groupGrants[files[i].ID],
+			AppDefault: appDefault,
+		}
+		if link != nil && link.FileGuid == files[i].Guid {
+			in.Link = link.Permissions
+		}
+		parent := files[i].ParentGuid
+		for depth := 0; parent != "" && depth < maxFolderDepth; depth++ {
+			folder, ok := folders[parent]
+			if !ok {
+				break
+			}
+			if link != nil && link.FileGuid == folder.Guid {
+				in.Link = link.Permissions
+			}
+			if fp, ok := grants[folder.ID]; ok {
+				in.Inherited = append(in.Inherited, access.Layer{FolderId: folder.Guid, Permissions: fp.Permissions})
+			}
+			for _, layer := range groupGrants[folder.ID] {
+				layer.FolderId = folder.Guid
+				in.Inherited = append(in.Inherited, layer)
+			}
+			parent = folder.ParentGuid
+		}
+		results[i] = access.Resolve(in)
+		files[i].Permissions = results[i].Permissions
+		files[i].Role = grants[files[i].ID].Role
+	}
+	return results, nil
+}
+
+// AdminFilePermissions returns the permissions of the app itself on a file, used by the admin callbacks
+func AdminFilePermissions(file *File) *access.Result {
+	return access.Resolve(&access.Input{
+		Full:  fullFilePermissions(),
+		Admin: true,
+		Form:  isForm(file),
+	})
+}
+
+// fullFilePermissions is the permission set of a file creator
+func fullFilePermissions() map[string]bool {
+	if econf.GetBool("permissions.setNewFilePermission") {
+		return sdk.HandleFilePermission(true)
+	}
+	return sdk.HandleBasicFilePermission(true)
+}
+
+// appDefaultPermissions reads the flags granted to every signed-in user from permissions.default
+func appDefaultPermissions() map[string]bool {
+	p := map[string]bool{}
+	for k, v := range econf.GetStringMap("permissions.default") {
+		if b, ok := v.(bool); ok {
+			p[k] = b
+		}
+	}
+	return p
+}
+
+func isForm(file *File) bool {
+	return file.IsShimoFile == 1 && file.ShimoType == "form"
+}
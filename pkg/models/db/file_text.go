@@ -0,0 +1,43 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FileText holds the plain text of a collaborative file, the search index is rebuilt from it on startup
+type FileText struct {
+	BaseModel
+	// FileGuid is the GUID of the file
+	FileGuid string `gorm:"uniqueIndex:uniq_file_text_file_guid;size:64;comment:'File GUID'" json:"fileId"`
+	// Content is the plain text of the file
+	Content string `gorm:"type:longtext;comment:'Plain text'" json:"content"`
+	// IndexedAt is the Unix timestamp the text was fetched at
+	IndexedAt int64 `gorm:"comment:'Indexed timestamp'" json:"indexedAt"`
+}
+
+func (t *FileText) TableName() string {
+	return "file_texts"
+}
+
+// SaveFileText stores the plain text of a file, replacing the previous one
+func SaveFileText(db *gorm.DB, fileGuid string, content string) error {
+	row := FileText{FileGuid: fileGuid, Content: content, IndexedAt: time.Now().Unix()}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_guid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "indexed_at", "updated_at"}),
+	}).Create(&row).Error
+}
+
+// FindFileTexts fetches up to limit texts with an ID above afterId, in ID order, to page through all of them
+func FindFileTexts(db *gorm.DB, afterId int64, limit int) (texts []FileText, err error) {
+	err = db.Where("id > ?", afterId).Order("id").Limit(limit).Find(&texts).Error
+	return
+}
+
+// RemoveFileTextsByGuids deletes the texts of the given files
+func RemoveFileTextsByGuids(db *gorm.DB, fileGuids []string) error {
+	return db.Unscoped().Where("file_guid IN ?", fileGuids).Delete(&FileText{}).Error
+}
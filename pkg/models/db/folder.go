@@ -0,0 +1,100 @@
+package db
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// maxFolderDepth bounds the walk up the folder tree, so corrupted parent references cannot loop forever
+const maxFolderDepth = 64
+
+var (
+	// ErrNotFolder is returned when a parent reference does not point to a folder
+	ErrNotFolder = errors.New("parent is not a folder")
+	// ErrFolderCycle is returned when a folder would be moved into itself or one of its descendants
+	ErrFolderCycle = errors.New("cannot move a folder into itself or its descendants")
+)
+
+// FindFolderPath returns the folders from the root down to the given file or folder, the file included
+func FindFolderPath(db *gorm.DB, guid string) (path []File, err error) {
+	for depth := 0; guid != "" && depth < maxFolderDepth; depth++ {
+		var f *File
+		if f, err = FindFileByGuid(db, guid); err != nil {
+			return nil, err
+		}
+		path = append([]File{*f}, path...)
+		guid = f.ParentGuid
+	}
+	return path, nil
+}
+
+// FindFolderChildren fetches the files and folders directly inside a folder that the user can read and
+// that match the filter, folders first. In the root (empty parentGuid) only the files the user or its
+// groups have a grant on are listed, below it the grants on the parent folders are inherited
+func FindFolderChildren(db *gorm.DB, userId int64, parentGuid string, filter FileFilter) (files []File, err error) {
+	query := filter.apply(db, db.Where("parent_guid = ?", parentGuid))
+	if parentGuid == "" {
+		if query, err = sharedWithUser(db, query, userId); err != nil {
+			return
+		}
+	}
+	if err = query.Order("is_folder DESC, name").Find(&files).Error; err != nil || len(files) == 0 {
+		return
+	}
+	if _, err = ResolveFilePermissions(db, userId, files); err != nil {
+		return nil, err
+	}
+
+	readable := files[:0]
+	for _, f := range files {
+		if f.Permissions["readable"] {
+			readable = append(readable, f)
+		}
+	}
+	return readable, nil
+}
+
+// FindFolderDescendants fetches every file and folder below a folder, breadth first
+func FindFolderDescendants(db *gorm.DB, folderGuid string) (files []File, err error) {
+	parents := []string{folderGuid}
+	for depth := 0; len(parents) > 0 && depth < maxFolderDepth; depth++ {
+		var children []File
+		if err = db.Where("parent_guid IN ?", parents).Find(&children).Error; err != nil {
+			return nil, err
+		}
+		parents = parents[:0]
+		for _, c := range children {
+			if c.IsFolder {
+				parents = append(parents, c.Guid)
+			}
+		}
+		files = append(files, children...)
+	}
+	return files, nil
+}
+
+// CountFolderChildren returns the number of files and folders directly inside a folder
+func CountFolderChildren(db *gorm.DB, folderGuid string) (count int64, err error) {
+	err = db.Model(&File{}).Where("parent_guid = ?", folderGuid).Count(&count).Error
+	return
+}
+
+// MoveFile moves a file or folder into a folder, an empty parentGuid moves it to the root
+func MoveFile(db *gorm.DB, guid string, parentGuid string) error {
+	if parentGuid != "" {
+		path, err := FindFolderPath(db, parentGuid)
+		if err != nil {
+			return err
+		}
+		if !path[len(path)-1].IsFolder {
+			return ErrNotFolder
+		}
+		for _, f := range path {
+			if f.Guid == guid {
+				return ErrFolderCycle
+			}
+		}
+	}
+	return db.Model(&File{}).Where("guid = ?", guid).Update("parent_guid", parentGuid).Error
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// JobPending means the job waits for its next poll
+	JobPending = "pending"
+	// JobRunning means a worker is polling the job right now
+	JobRunning = "running"
+	// JobSucceeded means the task finished successfully
+	JobSucceeded = "succeeded"
+	// JobFailed means the task failed, timed out or kept erroring
+	JobFailed = "failed"
+)
+
+// Job represents a long-running SDK task (import, export, ...) polled in the background
+type Job struct {
+	BaseModel
+	// Guid is the unique job identifier used by clients
+	Guid string `gorm:"uniqueIndex:uniq_job_guid;comment:'Job GUID'" json:"id"`
+	// UserId is the ID of the user who started the job
+	UserId int64 `gorm:"index:idx_job_user_id;comment:'User ID'" json:"userId"`
+	// Type is the job type (import/export/kb_import)
+	Type string `gorm:"comment:'Job type'" json:"type"`
+	// Status is the job status (pending/running/succeeded/failed)
+	Status string `gorm:"index:idx_job_status_next_run,priority:1;comment:'Job status'" json:"status"`
+	// FileGuid is the GUID of the file the job works on
+	FileGuid string `gorm:"comment:'File GUID'" json:"fileGuid"`
+	// TaskId is the task ID returned by the Shimo SDK
+	TaskId string `gorm:"comment:'SDK task ID'" json:"taskId"`
+	// Payload holds the type specific input of the job
+	Payload JobData `gorm:"type:text;comment:'Job input'" json:"payload"`
+	// Result holds the type specific output of the job
+	Result JobData `gorm:"type:text;comment:'Job output'" json:"result"`
+	// Progress is the last reported progress (0-100)
+	Progress int `gorm:"comment:'Progress'" json:"progress"`
+	// Attempts is how many times the job has been polled
+	Attempts int `gorm:"comment:'Poll attempts'" json:"attempts"`
+	// Errors is the number of consecutive failed polls
+	Errors int `gorm:"comment:'Consecutive errors'" json:"-"`
+	// LastError is the error of the last failed poll
+	LastError string `gorm:"type:text;comment:'Last error'" json:"error"`
+	// NextRunAt is the Unix timestamp of the next poll
+	NextRunAt int64 `gorm:"index:idx_job_status_next_run,priority:2;comment:'Next poll timestamp'" json:"nextRunAt"`
+	// LockedUntil is the Unix timestamp until which a worker owns the running job
+	LockedUntil int64 `gorm:"comment:'Lease expiry timestamp'" json:"-"`
+	// DeadlineAt is the Unix timestamp after which the job is given up
+	DeadlineAt int64 `gorm:"comment:'Deadline timestamp'" json:"deadlineAt"`
+	// FinishedAt is the Unix timestamp when the job succeeded or failed (0 means unfinished)
+	FinishedAt int64 `gorm:"comment:'Finished timestamp'" json:"finishedAt"`
+}
+
+// JobData is a free-form JSON object (stored as TEXT in DB)
+type JobData map[string]interface{}
+
+// TableName returns the database table name for Job
+func (j *Job) TableName() string {
+	return "jobs"
+}
+
+// String returns the value of a string field, empty when missing
+func (d JobData) String(key string) string {
+	s, _ := d[key].(string)
+	return s
+}
+
+// Bool returns the value of a boolean field, false when missing
+func (d JobData) Bool(key string) bool {
+	b, _ := d[key].(bool)
+	return b
+}
+
+func (d JobData) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+	v, err := json.Marshal(d)
+	return string(v), err
+}
+
+func (d *JobData) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = JobData{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	default:
+		return fmt.Errorf("failed to scan JobData, unexpected type: %T", v)
+	}
+}
+
+// CreateJob inserts a job
+func CreateJob(db *gorm.DB, job *Job) error {
+	return db.Create(job).Error
+}
+
+// FindJobByGuid retrieves a user's job by GUID
+func FindJobByGuid(db *gorm.DB, userId int64, guid string) (job *Job, err error) {
+	err = db.Where("guid = ? AND user_id = ?", guid, userId).First(&job).Error
+	return
+}
+
+// FindDueJobs fetches pending jobs whose next poll is due, plus running jobs whose
+// worker lease expired (e.g. because the server restarted in the middle of a poll)
+func FindDueJobs(db *gorm.DB, now int64, limit int) (jobs []Job, err error) {
+	err = db.Where("((status = ? AND next_run_at <= ?) OR (status = ? AND locked_until < ?))",
+		JobPending, now, JobRunning, now).
+		Order("next_run_at").
+		Limit(limit).
+		Find(&jobs).Error
+	return
+}
+
+// ClaimJob marks a due job as running until lockedUntil
+// ok is false when another worker claimed it first
+func ClaimJob(db *gorm.DB, job *Job, now, lockedUntil int64) (ok bool, err error) {
+	res := db.Model(&Job{}).
+		Where("id = ? AND status = ? AND next_run_at = ? AND locked_until = ?",
+			job.ID, job.Status, job.NextRunAt, job.LockedUntil).
+		Where("((status = ? AND next_run_at <= ?) OR (status = ? AND locked_until < ?))",
+			JobPending, now, JobRunning, now).
+		Updates(map[string]interface{}{
+			"status":       JobRunning,
+			"locked_until": lockedUntil,
+		})
+	if res.Error != nil || res.RowsAffected != 1 {
+		return false, res.Error
+	}
+	job.Status = JobRunning
+	job.LockedUntil = lockedUntil
+	return true, nil
+}
+
+// SaveJob writes back the state of a job after it has been polled
+func SaveJob(db *gorm.DB, job *Job) error {
+	return db.Model(&Job{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"status":       job.Status,
+		"result":       job.Result,
+		"progress":     job.Progress,
+		"attempts":     job.Attempts,
+		"errors":       job.Errors,
+		"last_error":   job.LastError,
+		"next_run_at":  job.NextRunAt,
+		"locked_until": job.LockedUntil,
+		"finished_at":  job.FinishedAt,
+	}).Error
+}
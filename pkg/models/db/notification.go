@@ -0,0 +1,136 @@
+package db
+
+import (
+	"strconv"
+
+	"gorm.io/gorm"
+
+	"sdk-demo-go/pkg/events"
+)
+
+// notifiableEventTypes are the event types fanned out to notification rows
+var notifiableEventTypes = map[string]bool{
+	"Comment":      true,
+	"MentionAt":    true,
+	"DateMention":  true,
+	"Collaborator": true,
+}
+
+// Notification represents an event delivered to the inbox of one recipient
+type Notification struct {
+	BaseModel
+	// UserId is the ID of the recipient
+	UserId int64 `gorm:"index:idx_notification_user_read,priority:1;comment:'Recipient user ID'" json:"userId"`
+	// ReadAt is the Unix timestamp when the recipient read the notification (0 means unread)
+	ReadAt int64 `gorm:"index:idx_notification_user_read,priority:2;comment:'Read timestamp'" json:"readAt"`
+	// EventId is the ID of the event the notification was built from
+	EventId int64 `gorm:"index:idx_notification_event_id;comment:'Event ID'" json:"eventId"`
+	// Type is the event type (Comment, MentionAt, DateMention, Collaborator)
+	Type string `gorm:"comment:'Event type'" json:"type"`
+	// Action is the event action or sub type (e.g. create, comment, mention_at)
+	Action string `gorm:"comment:'Event action'" json:"action"`
+	// FileId is the GUID of the file the event happened on
+	FileId string `gorm:"comment:'File ID'" json:"fileId"`
+	// ActorId is the ID of the user who triggered the event
+	ActorId string `gorm:"comment:'Actor user ID'" json:"actorId"`
+}
+
+// TableName returns the database table name for Notification
+func (n *Notification) TableName() string {
+	return "notifications"
+}
+
+// NotificationsFromEvent builds one unread notification per recipient of a stored event
+// The actor is not notified about their own action, except for Collaborator events where
+// the event user is the one whose access changed
+func NotificationsFromEvent(e *Event) (notifications []Notification, err error) {
+	if !notifiableEventTypes[e.Type] {
+		return nil, nil
+	}
+
+	p, err := events.Parse(e.Type, []byte(e.RawData))
+	if err != nil {
+		return nil, err
+	}
+	action := p.Common().Action
+	if action == "" {
+		action = p.Common().Type
+	}
+
+	seen := make(map[int64]bool)
+	for _, id := range p.UserIds() {
+		if id == e.UserId && e.Type != "Collaborator" {
+			continue
+		}
+		userId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || seen[userId] {
+			continue
+		}
+		seen[userId] = true
+		notifications = append(notifications, Notification{
+			UserId:  userId,
+			EventId: e.ID,
+			Type:    e.Type,
+			Action:  action,
+			FileId:  e.FileId,
+			ActorId: e.UserId,
+		})
+	}
+	return notifications, nil
+}
+
+// CreateNotifications inserts notifications in a single batch
+func CreateNotifications(db *gorm.DB, notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return db.Create(&notifications).Error
+}
+
+// FindNotifications queries a user's notifications, newest first
+func FindNotifications(db *gorm.DB, userId int64, unreadOnly bool, page int, limit int) (notifications []Notification, err error) {
+	if page <= 0 {
+		page = 1
+	}
+	err = notificationQuery(db, userId, unreadOnly).
+		Order("id desc").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&notifications).Error
+	return
+}
+
+// CountNotifications returns the number of a user's notifications
+func CountNotifications(db *gorm.DB, userId int64, unreadOnly bool) (count int64, err error) {
+	err = notificationQuery(db, userId, unreadOnly).Count(&count).Error
+	return
+}
+
+// FindNotification retrieves a user's notification by ID
+func FindNotification(db *gorm.DB, userId int64, id int64) (notification *Notification, err error) {
+	err = db.Where("id = ? AND user_id = ?", id, userId).First(&notification).Error
+	return
+}
+
+// MarkNotificationRead marks a user's notification as read, already read notifications keep their read time
+func MarkNotificationRead(db *gorm.DB, userId int64, id int64, now int64) error {
+	return db.Model(&Notification{}).
+		Where("id = ? AND user_id = ? AND read_at = 0", id, userId).
+		Update("read_at", now).Error
+}
+
+// MarkAllNotificationsRead marks every unread notification of a user as read
+func MarkAllNotificationsRead(db *gorm.DB, userId int64, now int64) (count int64, err error) {
+	res := db.Model(&Notification{}).
+		Where("user_id = ? AND read_at = 0", userId).
+		Update("read_at", now)
+	return res.RowsAffected, res.Error
+}
+
+func notificationQuery(db *gorm.DB, userId int64, unreadOnly bool) *gorm.DB {
+	query := db.Model(&Notification{}).Where("user_id = ?", userId)
+	if unreadOnly {
+		query = query.Where("read_at = 0")
+	}
+	return query
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrShareLinkInactive is returned when a share link is revoked, expired or used up
+var ErrShareLinkInactive = errors.New("share link is revoked, expired or used up")
+
+// ShareLink represents a public link granting a permission set on a file (and, for folders, on
+// everything below it) to whoever opens it
+type ShareLink struct {
+	BaseModel
+	// Guid is the unique link identifier used to manage the link
+	Guid string `gorm:"uniqueIndex:uniq_share_link_guid;size:32;comment:'Share link GUID'" json:"id"`
+	// Token is the secret part of the link URL, exchanged for an access token
+	Token string `gorm:"uniqueIndex:uniq_share_link_token;size:64;comment:'Share link token'" json:"token"`
+	// FileGuid is the GUID of the shared file or folder
+	FileGuid string `gorm:"index:idx_share_link_file_guid;size:64;comment:'File GUID'" json:"fileId"`
+	// CreatorId is the ID of the user who created the link
+	CreatorId int64 `gorm:"comment:'Creator ID'" json:"creatorId"`
+	// Permissions are the flags granted by the link (stored as TEXT in DB)
+	Permissions Permissions `gorm:"comment:'Permissions'" json:"permissions"`
+	// ExpiresAt is the Unix timestamp the link stops working at (0 means never)
+	ExpiresAt int64 `gorm:"comment:'Expiry timestamp'" json:"expiresAt"`
+	// Password is the bcrypt hash of the optional link password
+	Password string `gorm:"comment:'Password hash'" json:"-"`
+	// MaxUses is how many times the link can be exchanged (0 means unlimited)
+	MaxUses int `gorm:"comment:'Max uses'" json:"maxUses"`
+	// Uses is how many times the link was exchanged
+	Uses int `gorm:"comment:'Uses'" json:"uses"`
+	// RevokedAt is the Unix timestamp the link was revoked at (0 means active)
+	RevokedAt int64 `gorm:"comment:'Revoked timestamp'" json:"revokedAt"`
+}
+
+func (s *ShareLink) TableName() string {
+	return "share_links"
+}
+
+// Expired reports whether the expiry of the link has passed
+func (s *ShareLink) Expired() bool {
+	return s.ExpiresAt != 0 && s.ExpiresAt <= time.Now().Unix()
+}
+
+// Active reports whether the link can still be exchanged
+func (s *ShareLink) Active() bool {
+	return s.RevokedAt == 0 && !s.Expired() && (s.MaxUses == 0 || s.Uses < s.MaxUses)
+}
+
+// CreateShareLink inserts a share link
+func CreateShareLink(db *gorm.DB, s *ShareLink) error {
+	return db.Create(s).Error
+}
+
+// FindShareLinksByFileGuid fetches the share links of a file, newest first
+func FindShareLinksByFileGuid(db *gorm.DB, fileGuid string) (links []ShareLink, err error) {
+	err = db.Where("file_guid = ?", fileGuid).Order("id desc").Find(&links).Error
+	return
+}
+
+// FindShareLinkByGuid fetches a share link of a file by its GUID
+func FindShareLinkByGuid(db *gorm.DB, fileGuid string, guid string) (link *ShareLink, err error) {
+	err = db.Where("file_guid = ? AND guid = ?", fileGuid, guid).First(&link).Error
+	return
+}
+
+// FindShareLinkByToken fetches a share link by its token
+func FindShareLinkByToken(db *gorm.DB, token string) (link *ShareLink, err error) {
+	err = db.Where("token = ?", token).First(&link).Error
+	return
+}
+
+// FindActiveShareLink fetches a share link by its token, ErrShareLinkInactive when it can no longer be used
+// Access tokens are checked against it on every request, the uses limit only applies to UseShareLink
+func FindActiveShareLink(db *gorm.DB, token string) (link *ShareLink, err error) {
+	link, err = FindShareLinkByToken(db, token)
+	if err != nil {
+		return nil, err
+	}
+	if link.RevokedAt != 0 || link.Expired() {
+		return nil, ErrShareLinkInactive
+	}
+	return link, nil
+}
+
+// UseShareLink counts one exchange of a link, ErrShareLinkInactive when it can no longer be used
+// The check and the increment are a single statement so concurrent exchanges cannot exceed MaxUses
+func UseShareLink(db *gorm.DB, link *ShareLink) error {
+	res := db.Model(&ShareLink{}).
+		Where("id = ? AND revoked_at = 0 AND (expires_at = 0 OR expires_at > ?)", link.ID, time.Now().Unix()).
+		Where("max_uses = 0 OR uses < max_uses").
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrShareLinkInactive
+	}
+	link.Uses++
+	return nil
+}
+
+// RevokeShareLink revokes a share link, the access tokens issued for it stop working too
+func RevokeShareLink(db *gorm.DB, link *ShareLink) error {
+	link.RevokedAt = time.Now().Unix()
+	return db.Model(link).Update("revoked_at", link.RevokedAt).Error
+}
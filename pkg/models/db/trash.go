@@ -0,0 +1,201 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TrashItem represents a file or folder moved to the recycle bin. The file, everything below a folder
+// and their grants are soft deleted with the same TrashedAt timestamp, so they can be restored together
+type TrashItem struct {
+	BaseModel
+	// FileGuid is the GUID of the deleted file or folder
+	FileGuid string `gorm:"index:idx_trash_item_file_guid;size:64;comment:'File GUID'" json:"fileId"`
+	// Name is the name of the file when it was deleted
+	Name string `gorm:"comment:'File name'" json:"name"`
+	// Type is the file type when it was deleted
+	Type string `gorm:"comment:'File type'" json:"type"`
+	// IsFolder indicates whether a folder (and everything below it) was deleted
+	IsFolder bool `gorm:"comment:'Is folder'" json:"isFolder"`
+	// ParentGuid is the folder the file was deleted from, it is restored there when the folder still exists
+	ParentGuid string `gorm:"size:64;comment:'Parent folder GUID'" json:"parentId"`
+	// OwnerId is the creator of the file, the item shows in its recycle bin
+	OwnerId int64 `gorm:"index:idx_trash_item_owner_id;comment:'Owner ID'" json:"ownerId"`
+	// DeleterId is the user who deleted the file, the item shows in its recycle bin too
+	DeleterId int64 `gorm:"index:idx_trash_item_deleter_id;comment:'Deleter ID'" json:"deleterId"`
+	// TrashedAt is the Unix timestamp the file was deleted at, also stored as deleted_at of the trashed rows
+	TrashedAt int64 `gorm:"index:idx_trash_item_trashed_at;comment:'Trashed timestamp'" json:"trashedAt"`
+}
+
+func (t *TrashItem) TableName() string {
+	return "trash_items"
+}
+
+// TrashFiles moves files and folders, with everything below the folders, to the recycle bin of their
+// creator and of the deleter. Their user and group grants are soft deleted with them
+func TrashFiles(db *gorm.DB, deleterId int64, files []File) error {
+	now := time.Now().Unix()
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := range files {
+			trashed := []File{files[i]}
+			if files[i].IsFolder {
+				descendants, err := FindFolderDescendants(tx, files[i].Guid)
+				if err != nil {
+					return err
+				}
+				trashed = append(trashed, descendants...)
+			}
+			if err := stampFiles(tx, trashed, 0, now); err != nil {
+				return err
+			}
+			item := TrashItem{
+				FileGuid:   files[i].Guid,
+				Name:       files[i].Name,
+				Type:       files[i].Type,
+				IsFolder:   files[i].IsFolder,
+				ParentGuid: files[i].ParentGuid,
+				OwnerId:    files[i].CreatorId,
+				DeleterId:  deleterId,
+				TrashedAt:  now,
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindTrashItemsByUserId fetches the recycle bin of a user, the files it created or deleted, newest first
+func FindTrashItemsByUserId(db *gorm.DB, userId int64) (items []TrashItem, err error) {
+	err = db.Where("owner_id = ? OR deleter_id = ?", userId, userId).Order("trashed_at DESC, id DESC").Find(&items).Error
+	return
+}
+
+// FindTrashItemByFileGuid fetches an item of the recycle bin of a user
+func FindTrashItemByFileGuid(db *gorm.DB, userId int64, fileGuid string) (item *TrashItem, err error) {
+	err = db.Where("file_guid = ? AND (owner_id = ? OR deleter_id = ?)", fileGuid, userId, userId).First(&item).Error
+	return
+}
+
+// FindExpiredTrashItems fetches up to limit items trashed before the cutoff, oldest first
+func FindExpiredTrashItems(db *gorm.DB, before int64, limit int) (items []TrashItem, err error) {
+	err = db.Where("trashed_at < ?", before).Order("trashed_at").Limit(limit).Find(&items).Error
+	return
+}
+
+// FindTrashedFiles fetches the file or folder of an item and, for a folder, everything trashed with it
+func FindTrashedFiles(db *gorm.DB, item *TrashItem) (files []File, err error) {
+	tx := db.Unscoped()
+	if err = tx.Where("guid = ? AND deleted_at = ?", item.FileGuid, item.TrashedAt).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	parents := []string{item.FileGuid}
+	for depth := 0; item.IsFolder && len(parents) > 0 && depth < maxFolderDepth; depth++ {
+		var children []File
+		err = tx.Where("parent_guid IN ? AND deleted_at = ?", parents, item.TrashedAt).Find(&children).Error
+		if err != nil {
+			return nil, err
+		}
+		parents = parents[:0]
+		for _, c := range children {
+			if c.IsFolder {
+				parents = append(parents, c.Guid)
+			}
+		}
+		files = append(files, children...)
+	}
+	return files, nil
+}
+
+// RestoreTrashItem restores the files of an item with their grants and removes the item. The file
+// goes back to its folder, or to the root when the folder was deleted meanwhile
+func RestoreTrashItem(db *gorm.DB, item *TrashItem) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		files, err := FindTrashedFiles(tx, item)
+		if err != nil {
+			return err
+		}
+		if err = stampFiles(tx, files, item.TrashedAt, 0); err != nil {
+			return err
+		}
+		if item.ParentGuid != "" {
+			var count int64
+			if err = tx.Model(&File{}).Where("guid = ?", item.ParentGuid).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				err = tx.Model(&File{}).Where("guid = ?", item.FileGuid).Update("parent_guid", "").Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Unscoped().Delete(item).Error
+	})
+}
+
+// PurgeTrashItem permanently deletes the files of an item, their grants, share links, stars, pins,
+// tags, metadata and plain text, and the item. It returns the purged files so the caller can remove their content
+func PurgeTrashItem(db *gorm.DB, item *TrashItem) (files []File, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if files, err = FindTrashedFiles(tx, item); err != nil {
+			return err
+		}
+		ids := make([]int64, len(files))
+		guids := make([]string, len(files))
+		for i := range files {
+			ids[i] = files[i].ID
+			guids[i] = files[i].Guid
+		}
+		if len(files) > 0 {
+			tx := tx.Unscoped()
+			if err = tx.Where("id IN ?", ids).Delete(&File{}).Error; err != nil {
+				return err
+			}
+			if err = tx.Where("file_id IN ?", ids).Delete(&FilePermissions{}).Error; err != nil {
+				return err
+			}
+			if err = tx.Where("file_id IN ?", ids).Delete(&FileGroupPermissions{}).Error; err != nil {
+				return err
+			}
+			if err = tx.Where("file_guid IN ?", guids).Delete(&ShareLink{}).Error; err != nil {
+				return err
+			}
+			if err = RemoveUserFilesByFileIds(tx, ids); err != nil {
+				return err
+			}
+			if err = RemoveFileTagsAndMetadataByFileIds(tx, ids); err != nil {
+				return err
+			}
+			if err = RemoveFileTextsByGuids(tx, guids); err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(item).Error
+	})
+	return
+}
+
+// stampFiles moves the deleted_at timestamp of files and of their grants from one value to another,
+// so only the rows trashed together are touched
+func stampFiles(db *gorm.DB, files []File, from int64, to int64) error {
+	if len(files) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(files))
+	for i := range files {
+		ids[i] = files[i].ID
+	}
+	tx := db.Unscoped()
+	err := tx.Model(&File{}).Where("id IN ? AND deleted_at = ?", ids, from).UpdateColumn("deleted_at", to).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&FilePermissions{}).Where("file_id IN ? AND deleted_at = ?", ids, from).UpdateColumn("deleted_at", to).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&FileGroupPermissions{}).Where("file_id IN ? AND deleted_at = ?", ids, from).UpdateColumn("deleted_at", to).Error
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// UploadSessionUploading means parts are still being accepted
+	UploadSessionUploading = "uploading"
+	// UploadSessionCompleted means the parts were assembled into a file
+	UploadSessionCompleted = "completed"
+	// UploadSessionAborted means the client cancelled the upload
+	UploadSessionAborted = "aborted"
+	// UploadSessionExpired means the upload was garbage-collected after going idle
+	UploadSessionExpired = "expired"
+)
+
+// UploadSession represents a resumable multipart upload of a single file
+type UploadSession struct {
+	BaseModel
+	// Guid is the unique session identifier used by clients
+	Guid string `gorm:"uniqueIndex:uniq_upload_session_guid;comment:'Upload session GUID'" json:"id"`
+	// UserId is the ID of the user who started the upload
+	UserId int64 `gorm:"index:idx_upload_session_user_id;comment:'User ID'" json:"userId"`
+	// FileGuid is the GUID of the file created on completion, also used as the storage key
+	FileGuid string `gorm:"comment:'File GUID'" json:"fileGuid"`
+	// FileName is the name of the uploaded file
+	FileName string `gorm:"comment:'File name'" json:"fileName"`
+	// FileType is the MIME type sniffed from the first part
+	FileType string `gorm:"comment:'File MIME type'" json:"fileType"`
+	// Size is the total size announced by the client (0 means unknown)
+	Size int64 `gorm:"comment:'Announced total size'" json:"size"`
+	// UploadId is the multipart upload ID of the storage backend
+	UploadId string `gorm:"comment:'Storage multipart upload ID'" json:"-"`
+	// Status is the session status (uploading/completed/aborted/expired)
+	Status string `gorm:"index:idx_upload_session_status;comment:'Upload status'" json:"status"`
+	// ExpiresAt is the Unix timestamp after which an idle session is garbage-collected
+	ExpiresAt int64 `gorm:"comment:'Expiry timestamp'" json:"expiresAt"`
+}
+
+// TableName returns the database table name for UploadSession
+func (s *UploadSession) TableName() string {
+	return "upload_sessions"
+}
+
+// UploadPart represents a part that has been stored for an upload session
+type UploadPart struct {
+	BaseModel
+	// SessionId is the ID of the upload session
+	SessionId int64 `gorm:"uniqueIndex:uniq_upload_part;comment:'Upload session ID'" json:"-"`
+	// PartNumber is the 1-based part number
+	PartNumber int64 `gorm:"uniqueIndex:uniq_upload_part;comment:'Part number'" json:"partNumber"`
+	// Size is the part size in bytes
+	Size int64 `gorm:"comment:'Part size'" json:"size"`
+	// ETag is the entity tag returned by the storage backend
+	ETag string `gorm:"column:etag;comment:'Part ETag'" json:"etag"`
+}
+
+// TableName returns the database table name for UploadPart
+func (p *UploadPart) TableName() string {
+	return "upload_parts"
+}
+
+// CreateUploadSession inserts an upload session
+func CreateUploadSession(db *gorm.DB, session *UploadSession) error {
+	return db.Create(session).Error
+}
+
+// FindUploadSession retrieves a user's upload session by GUID
+func FindUploadSession(db *gorm.DB, userId int64, guid string) (session *UploadSession, err error) {
+	err = db.Where("guid = ? AND user_id = ?", guid, userId).First(&session).Error
+	return
+}
+
+// FindExpiredUploadSessions fetches sessions still uploading whose expiry is before now
+func FindExpiredUploadSessions(db *gorm.DB, now int64, limit int) (sessions []UploadSession, err error) {
+	err = db.Where("status = ? AND expires_at < ?", UploadSessionUploading, now).
+		Order("id").
+		Limit(limit).
+		Find(&sessions).Error
+	return
+}
+
+// UpdateUploadSession updates the given columns of an upload session
+func UpdateUploadSession(db *gorm.DB, id int64, values map[string]interface{}) error {
+	return db.Model(&UploadSession{}).Where("id = ?", id).Updates(values).Error
+}
+
+// FinishUploadSession moves a session out of the uploading status
+// ok is false when another request already finished the session
+func FinishUploadSession(db *gorm.DB, id int64, status string) (ok bool, err error) {
+	res := db.Model(&UploadSession{}).
+		Where("id = ? AND status = ?", id, UploadSessionUploading).
+		Update("status", status)
+	return res.RowsAffected == 1, res.Error
+}
+
+// SaveUploadPart upserts a part, a re-uploaded part replaces the earlier one
+func SaveUploadPart(db *gorm.DB, part *UploadPart) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_id"}, {Name: "part_number"}},
+		DoUpdates: clause.AssignmentColumns([]string{"size", "etag", "updated_at"}),
+	}).Create(part).Error
+}
+
+// FindUploadParts fetches the parts of a session ordered by part number
+func FindUploadParts(db *gorm.DB, sessionId int64) (parts []UploadPart, err error) {
+	err = db.Where("session_id = ?", sessionId).Order("part_number").Find(&parts).Error
+	return
+}
+
+// RemoveUploadParts deletes every part record of a session
+func RemoveUploadParts(db *gorm.DB, sessionId int64) error {
+	return db.Unscoped().Where("session_id = ?", sessionId).Delete(&UploadPart{}).Error
+}
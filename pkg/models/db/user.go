@@ -93,9 +93,12 @@ func CheckUserExist(db *gorm.DB, user *User) (exist bool, err error) {
 	return
 }
 
-// CreateUser inserts a new user
+// CreateUser inserts a new user along with its search keys
 func CreateUser(db *gorm.DB, user *User) (err error) {
-	res := db.Create(&user)
-	err = res.Error
-	return
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return SaveUserSearchKeys(tx, user)
+	})
 }
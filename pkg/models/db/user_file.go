@@ -0,0 +1,144 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserFile holds what a user did with a file: when it last opened it, and whether it starred or pinned it
+type UserFile struct {
+	BaseModel
+	// UserId is the user ID
+	UserId int64 `gorm:"uniqueIndex:uniq_user_file;comment:'User ID'" json:"userId"`
+	// FileId is the file ID
+	FileId int64 `gorm:"uniqueIndex:uniq_user_file;index:idx_user_file_file_id;comment:'File ID'" json:"fileId"`
+	// LastOpenedAt is the Unix timestamp the user last opened the file at (0 means never)
+	LastOpenedAt int64 `gorm:"comment:'Last opened timestamp'" json:"lastOpenedAt"`
+	// StarredAt is the Unix timestamp the user starred the file at (0 means not starred)
+	StarredAt int64 `gorm:"comment:'Starred timestamp'" json:"starredAt"`
+	// PinnedAt is the Unix timestamp the user pinned the file at (0 means not pinned)
+	PinnedAt int64 `gorm:"comment:'Pinned timestamp'" json:"pinnedAt"`
+}
+
+func (u *UserFile) TableName() string {
+	return "user_files"
+}
+
+// RecordFileOpen records that a user opened a file now
+func RecordFileOpen(db *gorm.DB, userId int64, fileId int64) error {
+	return saveUserFile(db, UserFile{UserId: userId, FileId: fileId, LastOpenedAt: time.Now().Unix()}, "last_opened_at")
+}
+
+// SetFileStarred stars or unstars a file for a user
+func SetFileStarred(db *gorm.DB, userId int64, fileId int64, starred bool) error {
+	row := UserFile{UserId: userId, FileId: fileId}
+	if starred {
+		row.StarredAt = time.Now().Unix()
+	}
+	return saveUserFile(db, row, "starred_at")
+}
+
+// SetFilePinned pins or unpins a file for a user
+func SetFilePinned(db *gorm.DB, userId int64, fileId int64, pinned bool) error {
+	row := UserFile{UserId: userId, FileId: fileId}
+	if pinned {
+		row.PinnedAt = time.Now().Unix()
+	}
+	return saveUserFile(db, row, "pinned_at")
+}
+
+// FindRecentFiles fetches up to limit files the user opened, most recently opened first
+func FindRecentFiles(db *gorm.DB, userId int64, limit int) ([]File, error) {
+	return findUserFiles(db, userId, "last_opened_at", limit)
+}
+
+// FindStarredFiles fetches up to limit files the user starred, most recently starred first
+func FindStarredFiles(db *gorm.DB, userId int64, limit int) ([]File, error) {
+	return findUserFiles(db, userId, "starred_at", limit)
+}
+
+// FindPinnedFiles fetches up to limit files the user pinned, most recently pinned first
+func FindPinnedFiles(db *gorm.DB, userId int64, limit int) ([]File, error) {
+	return findUserFiles(db, userId, "pinned_at", limit)
+}
+
+// LoadUserFileStates sets the Starred and Pinned flags of files for a user
+func LoadUserFileStates(db *gorm.DB, userId int64, files []File) error {
+	if len(files) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(files))
+	for i := range files {
+		ids[i] = files[i].ID
+	}
+	var rows []UserFile
+	if err := db.Where("user_id = ? AND file_id IN ?", userId, ids).Find(&rows).Error; err != nil {
+		return err
+	}
+	states := make(map[int64]UserFile, len(rows))
+	for _, row := range rows {
+		states[row.FileId] = row
+	}
+	for i := range files {
+		files[i].Starred = states[files[i].ID].StarredAt != 0
+		files[i].Pinned = states[files[i].ID].PinnedAt != 0
+	}
+	return nil
+}
+
+// RemoveUserFilesByFileIds deletes what the users did with the given files, used when they are purged
+func RemoveUserFilesByFileIds(db *gorm.DB, fileIds []int64) error {
+	return db.Unscoped().Where("file_id IN ?", fileIds).Delete(&UserFile{}).Error
+}
+
+// saveUserFile inserts the row of a user and a file, or updates the given column of the existing one
+func saveUserFile(db *gorm.DB, row UserFile, column string) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "file_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{column, "updated_at"}),
+	}).Create(&row).Error
+}
+
+// findUserFiles fetches the files the user can still read whose column is set, ordered by it (desc).
+// Files deleted meanwhile are left out
+func findUserFiles(db *gorm.DB, userId int64, column string, limit int) (files []File, err error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var ids []int64
+	err = db.Model(&UserFile{}).
+		Where("user_id = ? AND "+column+" > 0", userId).
+		Order(column+" DESC").
+		Limit(limit).
+		Pluck("file_id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return
+	}
+	found, err := FindFilesByIds(db, ids)
+	if err != nil {
+		return
+	}
+	byId := make(map[int64]File, len(found))
+	for _, f := range found {
+		byId[f.ID] = f
+	}
+	files = make([]File, 0, len(found))
+	for _, id := range ids {
+		if f, ok := byId[id]; ok {
+			files = append(files, f)
+		}
+	}
+	if _, err = ResolveFilePermissions(db, userId, files); err != nil {
+		return nil, err
+	}
+
+	readable := files[:0]
+	for _, f := range files {
+		if f.Permissions["readable"] {
+			readable = append(readable, f)
+		}
+	}
+	return readable, LoadUserFileStates(db, userId, readable)
+}
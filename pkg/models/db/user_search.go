@@ -0,0 +1,269 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gotomicro/ego/core/econf"
+	"gorm.io/gorm"
+
+	"sdk-demo-go/pkg/pinyin"
+)
+
+const (
+	// maxUserSearchPageSize is the largest page of a user search
+	maxUserSearchPageSize = 100
+	// recentCollaboratorLimit is how many of the most recent collaborators rank first
+	recentCollaboratorLimit = 200
+	// maxUserSearchTermLength is the size of the term column, in bytes
+	maxUserSearchTermLength = 128
+)
+
+// UserSearchKey is a term a user is found by: the name and its words, the pinyin of Chinese names and the email
+type UserSearchKey struct {
+	BaseModel
+	// UserId is the user ID
+	UserId int64 `gorm:"uniqueIndex:uniq_user_search_key;comment:'User ID'" json:"userId"`
+	// Term is the lower-cased term, matched by prefix
+	Term string `gorm:"uniqueIndex:uniq_user_search_key;index:idx_user_search_key_term;size:128;comment:'Search term'" json:"term"`
+}
+
+func (k *UserSearchKey) TableName() string {
+	return "user_search_keys"
+}
+
+// UserQuery describes a user search
+type UserQuery struct {
+	// UserId is the searching user, left out of the results; their collaborators and colleagues rank first
+	UserId int64
+	// Keyword is matched by prefix against the search keys, every user matches when empty
+	Keyword string
+	// UserIds restricts the search to these users when not nil
+	UserIds []int64
+	// Page is the page number, starting at 1
+	Page int
+	// PageSize is the number of users per page, at most 100
+	PageSize int
+}
+
+// userRank is how close a user is to the searching user
+type userRank struct {
+	// collaboratedAt is when they last shared a file, 0 when they never did
+	collaboratedAt int64
+	// sameDepartment is whether they are members of a common department
+	sameDepartment bool
+	// sameTeam is whether they are members of a common team
+	sameTeam bool
+}
+
+// less tells whether r ranks before o
+func (r userRank) less(o userRank) bool {
+	if r.collaboratedAt != o.collaboratedAt {
+		return r.collaboratedAt > o.collaboratedAt
+	}
+	if r.sameDepartment != o.sameDepartment {
+		return r.sameDepartment
+	}
+	return r.sameTeam && !o.sameTeam
+}
+
+// UserSearchTerms returns the distinct search terms of a user name and email
+func UserSearchTerms(name string, email string) []string {
+	terms := pinyin.NameKeys(name)
+	if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		terms = append(terms, email)
+		// Also find "san.zhang@example.com" by "zhang"
+		local, _, _ := strings.Cut(email, "@")
+		terms = append(terms, strings.FieldsFunc(local, func(r rune) bool {
+			return strings.ContainsRune(".-_+", r)
+		})...)
+	}
+
+	seen := map[string]bool{}
+	res := make([]string, 0, len(terms))
+	for _, term := range terms {
+		// The shorter terms still find the user when a long name or email does not fit
+		if len(term) <= maxUserSearchTermLength && !seen[term] {
+			seen[term] = true
+			res = append(res, term)
+		}
+	}
+	return res
+}
+
+// SaveUserSearchKeys replaces the search keys of a user, from its name and email
+func SaveUserSearchKeys(db *gorm.DB, user *User) error {
+	terms := UserSearchTerms(user.Name, user.Email)
+	rows := make([]UserSearchKey, len(terms))
+	for i, term := range terms {
+		rows[i] = UserSearchKey{UserId: user.ID, Term: term}
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&UserSearchKey{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// SearchUsers returns a page of the users matching a query and how many match in all.
+// Users rank by the last file they shared with the searching user, then members of the same
+// departments, then of the same teams; the other users follow by ID
+func SearchUsers(db *gorm.DB, q UserQuery) (users []User, total int, err error) {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 {
+		q.PageSize = 20
+	}
+	q.PageSize = min(q.PageSize, maxUserSearchPageSize)
+	if q.UserIds != nil && len(q.UserIds) == 0 {
+		return
+	}
+
+	ranks, err := findUserRanks(db, q.UserId)
+	if err != nil {
+		return
+	}
+	rankedIds := make([]int64, 0, len(ranks))
+	for id := range ranks {
+		rankedIds = append(rankedIds, id)
+	}
+
+	// The related users are few, they are all fetched and ranked here
+	var ranked []int64
+	if len(rankedIds) > 0 {
+		err = userSearchQuery(db, q).Where("u.id IN ?", rankedIds).Pluck("u.id", &ranked).Error
+		if err != nil {
+			return
+		}
+		sort.Slice(ranked, func(i, j int) bool {
+			ri, rj := ranks[ranked[i]], ranks[ranked[j]]
+			if ri != rj {
+				return ri.less(rj)
+			}
+			return ranked[i] < ranked[j]
+		})
+	}
+	others := func() *gorm.DB {
+		query := userSearchQuery(db, q)
+		if len(rankedIds) > 0 {
+			query = query.Where("u.id NOT IN ?", rankedIds)
+		}
+		return query
+	}
+	var count int64
+	if err = others().Count(&count).Error; err != nil {
+		return
+	}
+	total = len(ranked) + int(count)
+
+	start := (q.Page - 1) * q.PageSize
+	end := start + q.PageSize
+	ids := ranked[min(start, len(ranked)):min(end, len(ranked))]
+	if end > len(ranked) && start < total {
+		var rest []int64
+		err = others().Order("u.id").
+			Offset(max(start-len(ranked), 0)).
+			Limit(end-max(start, len(ranked))).
+			Pluck("u.id", &rest).Error
+		if err != nil {
+			return
+		}
+		ids = append(ids, rest...)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	found, err := FindUsersByIds(db, ids)
+	if err != nil {
+		return
+	}
+	byId := make(map[int64]User, len(found))
+	for _, u := range found {
+		byId[u.ID] = u
+	}
+	users = make([]User, 0, len(found))
+	for _, id := range ids {
+		if u, ok := byId[id]; ok {
+			users = append(users, u)
+		}
+	}
+	return
+}
+
+// userSearchQuery selects the users of the app matching a query, without the searching user
+func userSearchQuery(db *gorm.DB, q UserQuery) *gorm.DB {
+	query := db.Table("users u").
+		Where("u.app_id = ? AND u.id <> ? AND u.deleted_at = 0", econf.GetString("shimoSDK.appId"), q.UserId)
+	if q.UserIds != nil {
+		query = query.Where("u.id IN ?", q.UserIds)
+	}
+	if keyword := strings.ToLower(strings.TrimSpace(q.Keyword)); keyword != "" {
+		query = query.Where("u.id IN (?)", db.Model(&UserSearchKey{}).Select("user_id").
+			Where("term LIKE ? ESCAPE '!'", likeEscaper.Replace(keyword)+"%"))
+	}
+	return query
+}
+
+// findUserRanks returns how close the recent collaborators and the members of the same departments and teams are to a user
+func findUserRanks(db *gorm.DB, userId int64) (map[int64]userRank, error) {
+	ranks := map[int64]userRank{}
+	if userId == 0 {
+		return ranks, nil
+	}
+
+	var collaborators []struct {
+		UserId int64
+		LastAt int64
+	}
+	err := db.Model(&FilePermissions{}).
+		Select("user_id, MAX(updated_at) AS last_at").
+		Where("file_id IN (?) AND user_id <> ?",
+			db.Model(&FilePermissions{}).Select("file_id").Where("user_id = ?", userId), userId).
+		Group("user_id").
+		Order("last_at DESC").
+		Limit(recentCollaboratorLimit).
+		Scan(&collaborators).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range collaborators {
+		ranks[c.UserId] = userRank{collaboratedAt: c.LastAt}
+	}
+
+	var deptPeers []int64
+	err = db.Model(&DeptMember{}).
+		Where("dept_id IN (?) AND user_id <> ?",
+			db.Model(&DeptMember{}).Select("dept_id").Where("user_id = ?", userId), userId).
+		Distinct().
+		Pluck("user_id", &deptPeers).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range deptPeers {
+		r := ranks[id]
+		r.sameDepartment = true
+		ranks[id] = r
+	}
+
+	var teamPeers []int64
+	err = db.Model(&TeamRole{}).
+		Where("team_id IN (?) AND user_id <> ?",
+			db.Model(&TeamRole{}).Select("team_id").Where("user_id = ?", userId), userId).
+		Distinct().
+		Pluck("user_id", &teamPeers).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range teamPeers {
+		r := ranks[id]
+		r.sameTeam = true
+		ranks[id] = r
+	}
+	return ranks, nil
+}
@@ -0,0 +1,306 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"sdk-demo-go/pkg/events"
+)
+
+const (
+	// WebhookDeliveryPending means the delivery waits for its next attempt
+	WebhookDeliveryPending = "pending"
+	// WebhookDeliveryRunning means a worker is sending the delivery right now
+	WebhookDeliveryRunning = "running"
+	// WebhookDeliverySucceeded means the endpoint answered with a 2xx status
+	WebhookDeliverySucceeded = "succeeded"
+	// WebhookDeliveryDead means every attempt failed, the delivery sits in the dead-letter list
+	WebhookDeliveryDead = "dead"
+)
+
+// WebhookSubscription represents an outbound webhook that callback events are forwarded to
+type WebhookSubscription struct {
+	BaseModel
+	// Guid is the unique subscription identifier used by clients
+	Guid string `gorm:"uniqueIndex:uniq_webhook_subscription_guid;comment:'Webhook GUID'" json:"id"`
+	// UserId is the ID of the user who owns the subscription
+	UserId int64 `gorm:"index:idx_webhook_subscription_user_id;comment:'Owner user ID'" json:"userId"`
+	// URL is the endpoint the events are posted to
+	URL string `gorm:"column:url;comment:'Endpoint URL'" json:"url"`
+	// EventTypes is the comma separated list of forwarded event types (empty means all)
+	EventTypes string `gorm:"comment:'Event type filter'" json:"eventTypes"`
+	// Secret is the HMAC key used to sign the deliveries
+	Secret string `gorm:"comment:'Signing secret'" json:"-"`
+	// Active tells whether new events are forwarded
+	Active bool `gorm:"comment:'Active'" json:"active"`
+}
+
+// TableName returns the database table name for WebhookSubscription
+func (s *WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// Matches reports whether events of the given type are forwarded by the subscription
+func (s *WebhookSubscription) Matches(eventType string) bool {
+	if s.EventTypes == "" {
+		return true
+	}
+	for _, t := range strings.Split(s.EventTypes, ",") {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery represents one event queued for one subscription
+type WebhookDelivery struct {
+	BaseModel
+	// SubscriptionId is the ID of the webhook subscription
+	SubscriptionId int64 `gorm:"index:idx_webhook_delivery_subscription;comment:'Webhook subscription ID'" json:"-"`
+	// EventId is the ID of the forwarded event
+	EventId int64 `gorm:"comment:'Event ID'" json:"eventId"`
+	// EventType is the type of the forwarded event
+	EventType string `gorm:"comment:'Event type'" json:"eventType"`
+	// Payload is the raw event body that is posted
+	Payload string `gorm:"type:text;comment:'Event payload'" json:"payload"`
+	// Status is the delivery status (pending/running/succeeded/dead)
+	Status string `gorm:"index:idx_webhook_delivery_status_next_run,priority:1;comment:'Delivery status'" json:"status"`
+	// Attempts is how many times the delivery has been sent
+	Attempts int `gorm:"comment:'Delivery attempts'" json:"attempts"`
+	// ResponseCode is the HTTP status of the last attempt (0 when the request failed)
+	ResponseCode int `gorm:"comment:'Last response status'" json:"responseCode"`
+	// LastError is the error of the last failed attempt
+	LastError string `gorm:"type:text;comment:'Last error'" json:"error"`
+	// NextRunAt is the Unix timestamp of the next attempt
+	NextRunAt int64 `gorm:"index:idx_webhook_delivery_status_next_run,priority:2;comment:'Next attempt timestamp'" json:"nextRunAt"`
+	// LockedUntil is the Unix timestamp until which a worker owns the running delivery
+	LockedUntil int64 `gorm:"comment:'Lease expiry timestamp'" json:"-"`
+	// DeliveredAt is the Unix timestamp of the successful attempt (0 means undelivered)
+	DeliveredAt int64 `gorm:"comment:'Delivered timestamp'" json:"deliveredAt"`
+}
+
+// TableName returns the database table name for WebhookDelivery
+func (d *WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// CreateWebhookSubscription inserts a webhook subscription
+func CreateWebhookSubscription(db *gorm.DB, s *WebhookSubscription) error {
+	return db.Create(s).Error
+}
+
+// FindWebhookSubscriptions fetches the subscriptions of a user
+func FindWebhookSubscriptions(db *gorm.DB, userId int64) (subscriptions []WebhookSubscription, err error) {
+	err = db.Where("user_id = ?", userId).Order("id").Find(&subscriptions).Error
+	return
+}
+
+// CountWebhookSubscriptions returns the number of subscriptions of a user
+func CountWebhookSubscriptions(db *gorm.DB, userId int64) (count int64, err error) {
+	err = db.Model(&WebhookSubscription{}).Where("user_id = ?", userId).Count(&count).Error
+	return
+}
+
+// FindWebhookSubscription retrieves a user's subscription by GUID
+func FindWebhookSubscription(db *gorm.DB, userId int64, guid string) (subscription *WebhookSubscription, err error) {
+	err = db.Where("guid = ? AND user_id = ?", guid, userId).First(&subscription).Error
+	return
+}
+
+// FindActiveWebhookSubscriptions fetches every subscription events are forwarded to
+func FindActiveWebhookSubscriptions(db *gorm.DB) (subscriptions []WebhookSubscription, err error) {
+	err = db.Where("active = ?", true).Find(&subscriptions).Error
+	return
+}
+
+// UpdateWebhookSubscription updates the given columns of a subscription
+func UpdateWebhookSubscription(db *gorm.DB, id int64, values map[string]interface{}) error {
+	return db.Model(&WebhookSubscription{}).Where("id = ?", id).Updates(values).Error
+}
+
+// RemoveWebhookSubscription deletes a subscription and drops its undelivered events
+func RemoveWebhookSubscription(db *gorm.DB, id int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("subscription_id = ? AND status IN ?", id,
+			[]string{WebhookDeliveryPending, WebhookDeliveryRunning}).
+			Delete(&WebhookDelivery{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&WebhookSubscription{}).Error
+	})
+}
+
+// WebhookDeliveriesFromEvent queues the event for every active subscription that matches its type
+// and whose owner may see it, see webhookEventReaders
+func WebhookDeliveriesFromEvent(db *gorm.DB, e *Event, now int64) error {
+	subscriptions, err := FindActiveWebhookSubscriptions(db)
+	if err != nil {
+		return err
+	}
+	var owners []int64
+	for _, s := range subscriptions {
+		if s.Matches(e.Type) {
+			owners = append(owners, s.UserId)
+		}
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+	readers, err := webhookEventReaders(db, e, owners)
+	if err != nil {
+		return err
+	}
+
+	var deliveries []WebhookDelivery
+	for _, s := range subscriptions {
+		if !s.Matches(e.Type) || !readers[s.UserId] {
+			continue
+		}
+		deliveries = append(deliveries, WebhookDelivery{
+			SubscriptionId: s.ID,
+			EventId:        e.ID,
+			EventType:      e.Type,
+			Payload:        e.RawData,
+			Status:         WebhookDeliveryPending,
+			NextRunAt:      now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return db.Create(&deliveries).Error
+}
+
+// webhookEventReaders returns which of the users may see an event: those who can read its file,
+// or the users the event involves when it has no file known to the demo
+func webhookEventReaders(db *gorm.DB, e *Event, userIds []int64) (map[int64]bool, error) {
+	readers := map[int64]bool{}
+	if e.FileId != "" {
+		files, err := FindFilesByGuids(db, []string{e.FileId})
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 {
+			for _, userId := range userIds {
+				if _, ok := readers[userId]; ok {
+					continue
+				}
+				file := []File{files[0]}
+				if _, err = ResolveFilePermissions(db, userId, file); err != nil {
+					return nil, err
+				}
+				readers[userId] = file[0].Permissions["readable"]
+			}
+			return readers, nil
+		}
+	}
+
+	// A payload that cannot be parsed involves nobody
+	p, err := events.Parse(e.Type, []byte(e.RawData))
+	if err != nil {
+		return readers, nil
+	}
+	for _, id := range p.UserIds() {
+		if userId, err := strconv.ParseInt(id, 10, 64); err == nil {
+			readers[userId] = true
+		}
+	}
+	return readers, nil
+}
+
+// FindDueWebhookDeliveries fetches pending deliveries whose next attempt is due, plus running
+// deliveries whose worker lease expired
+func FindDueWebhookDeliveries(db *gorm.DB, now int64, limit int) (deliveries []WebhookDelivery, err error) {
+	err = db.Where("((status = ? AND next_run_at <= ?) OR (status = ? AND locked_until < ?))",
+		WebhookDeliveryPending, now, WebhookDeliveryRunning, now).
+		Order("next_run_at").
+		Limit(limit).
+		Find(&deliveries).Error
+	return
+}
+
+// ClaimWebhookDelivery marks a due delivery as running until lockedUntil
+// ok is false when another worker claimed it first
+func ClaimWebhookDelivery(db *gorm.DB, d *WebhookDelivery, now, lockedUntil int64) (ok bool, err error) {
+	res := db.Model(&WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_run_at = ? AND locked_until = ?",
+			d.ID, d.Status, d.NextRunAt, d.LockedUntil).
+		Where("((status = ? AND next_run_at <= ?) OR (status = ? AND locked_until < ?))",
+			WebhookDeliveryPending, now, WebhookDeliveryRunning, now).
+		Updates(map[string]interface{}{
+			"status":       WebhookDeliveryRunning,
+			"locked_until": lockedUntil,
+		})
+	if res.Error != nil || res.RowsAffected != 1 {
+		return false, res.Error
+	}
+	d.Status = WebhookDeliveryRunning
+	d.LockedUntil = lockedUntil
+	return true, nil
+}
+
+// SaveWebhookDelivery writes back the state of a delivery after an attempt
+func SaveWebhookDelivery(db *gorm.DB, d *WebhookDelivery) error {
+	return db.Model(&WebhookDelivery{}).Where("id = ?", d.ID).Updates(map[string]interface{}{
+		"status":        d.Status,
+		"attempts":      d.Attempts,
+		"response_code": d.ResponseCode,
+		"last_error":    d.LastError,
+		"next_run_at":   d.NextRunAt,
+		"locked_until":  d.LockedUntil,
+		"delivered_at":  d.DeliveredAt,
+	}).Error
+}
+
+// FindWebhookDeliveries queries the deliveries of a subscription, newest first, optionally by status
+func FindWebhookDeliveries(db *gorm.DB, subscriptionId int64, status string, page int, limit int) (deliveries []WebhookDelivery, err error) {
+	if page <= 0 {
+		page = 1
+	}
+	err = webhookDeliveryQuery(db, subscriptionId, status).
+		Order("id desc").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&deliveries).Error
+	return
+}
+
+// CountWebhookDeliveries returns the number of deliveries of a subscription, optionally by status
+func CountWebhookDeliveries(db *gorm.DB, subscriptionId int64, status string) (count int64, err error) {
+	err = webhookDeliveryQuery(db, subscriptionId, status).Count(&count).Error
+	return
+}
+
+// FindWebhookDelivery retrieves a delivery of a subscription by ID
+func FindWebhookDelivery(db *gorm.DB, subscriptionId int64, id int64) (delivery *WebhookDelivery, err error) {
+	err = db.Where("id = ? AND subscription_id = ?", id, subscriptionId).First(&delivery).Error
+	return
+}
+
+// RedeliverWebhookDelivery queues a finished delivery again with a fresh attempt budget
+// ok is false when the delivery is still pending or running
+func RedeliverWebhookDelivery(db *gorm.DB, id int64, now int64) (ok bool, err error) {
+	res := db.Model(&WebhookDelivery{}).
+		Where("id = ? AND status IN ?", id, []string{WebhookDeliverySucceeded, WebhookDeliveryDead}).
+		Updates(map[string]interface{}{
+			"status":       WebhookDeliveryPending,
+			"attempts":     0,
+			"last_error":   "",
+			"next_run_at":  now,
+			"locked_until": 0,
+			"delivered_at": 0,
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+func webhookDeliveryQuery(db *gorm.DB, subscriptionId int64, status string) *gorm.DB {
+	query := db.Model(&WebhookDelivery{}).Where("subscription_id = ?", subscriptionId)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	return query
+}
@@ -0,0 +1,85 @@
+// Package pinyin romanizes Chinese names so that users can be found by typing the full
+// pinyin (zhangsan) or its initials (zs). Characters outside GB 2312 have no reading.
+package pinyin
+
+import "strings"
+
+// surnames holds the reading of the characters that are read differently as a surname
+var surnames = map[rune]string{
+	'单': "shan", '曾': "zeng", '解': "xie", '区': "ou", '仇': "qiu", '朴': "piao", '查': "zha",
+	'乐': "yue", '覃': "qin", '缪': "miao", '翟': "zhai", '种': "chong", '秘': "bi", '盖': "ge",
+	'员': "yun", '句': "gou", '郇': "xun", '重': "chong", '薄': "bo", '长': "chang",
+}
+
+// byRune maps every character of readings to its pinyin
+var byRune = map[rune]string{}
+
+func init() {
+	for syllable, chars := range readings {
+		for _, r := range chars {
+			byRune[r] = syllable
+		}
+	}
+}
+
+// Spell returns the pinyin of the characters of a name that have one, in order.
+// The first character is read as a surname
+func Spell(name string) []string {
+	var res []string
+	for i, r := range []rune(name) {
+		if s, ok := surnames[r]; ok && i == 0 {
+			res = append(res, s)
+			continue
+		}
+		if s, ok := byRune[r]; ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// NameKeys returns the lower-cased terms a name is found by with a prefix match: the name and each of its words,
+// and for Chinese names the full pinyin and the initials, and the same for the given name without the surname
+func NameKeys(name string) []string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil
+	}
+	var keys []string
+	seen := map[string]bool{}
+	add := func(key string) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	add(name)
+	for _, word := range strings.Fields(name) {
+		add(word)
+	}
+	syllables := Spell(name)
+	if len(syllables) == 0 {
+		return keys
+	}
+	add(strings.Join(syllables, ""))
+	add(initials(syllables))
+	// Colleagues are often called by their given name, only for names written without spaces or latin letters
+	if runes := []rune(name); len(syllables) > 1 && len(syllables) == len(runes) {
+		add(string(runes[1:]))
+		add(strings.Join(syllables[1:], ""))
+		if len(syllables) > 2 {
+			add(initials(syllables[1:]))
+		}
+	}
+	return keys
+}
+
+// initials returns the first letter of every syllable
+func initials(syllables []string) string {
+	var b strings.Builder
+	for _, s := range syllables {
+		b.WriteByte(s[0])
+	}
+	return b.String()
+}
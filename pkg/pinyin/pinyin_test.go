@@ -0,0 +1,49 @@
+package pinyin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpell(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "name", text: "张三丰", want: []string{"zhang", "san", "feng"}},
+		{name: "surname reading", text: "曾单", want: []string{"zeng", "dan"}},
+		{name: "u without umlaut", text: "吕", want: []string{"lu"}},
+		{name: "latin dropped", text: "Tom李", want: []string{"li"}},
+		{name: "no reading", text: "Alice", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Spell(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Spell(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "latin", text: " Alice Smith ", want: []string{"alice smith", "alice", "smith"}},
+		{name: "chinese", text: "张三丰", want: []string{"张三丰", "zhangsanfeng", "zsf", "三丰", "sanfeng", "sf"}},
+		{name: "two characters", text: "李四", want: []string{"李四", "lisi", "ls", "四", "si"}},
+		{name: "single character", text: "王", want: []string{"王", "wang", "w"}},
+		{name: "mixed", text: "Tom 李", want: []string{"tom 李", "tom", "李", "li", "l"}},
+		{name: "empty", text: " ", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NameKeys(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NameKeys(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/events"
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+)
+
+const (
+	// ActivitySourceEvent marks a timeline entry built from a callback event
+	ActivitySourceEvent = "event"
+	// ActivitySourceLocal marks a timeline entry built from an action taken through the demo
+	ActivitySourceLocal = "local"
+	// maxActivityRows is how deep the history of a file can be paged
+	maxActivityRows = 10000
+)
+
+// ActivityEntry is one entry of a file activity timeline
+type ActivityEntry struct {
+	// Source tells whether the entry is a callback event or a local action
+	Source string `json:"source"`
+	// Id is the ID of the event or of the local action
+	Id int64 `json:"id"`
+	// Type is the event type, or "File" for local actions
+	Type string `json:"type"`
+	// Action is the event action or the local action (create/rename/duplicate/move/delete/permission)
+	Action string `json:"action"`
+	// CreatedAt is the Unix timestamp of the entry
+	CreatedAt int64 `json:"createdAt"`
+	// ActorId is the ID of the user who triggered the entry
+	ActorId string `json:"actorId"`
+	// Actor is the user who triggered the entry, nil when unknown
+	Actor *UserSummary `json:"actor"`
+	// Detail is the raw event payload or the local action detail
+	Detail interface{} `json:"detail"`
+	// Users are the other users the entry involves, keyed by ID
+	Users map[string]*UserSummary `json:"users,omitempty"`
+	// Files are the files the entry involves, keyed by GUID
+	Files map[string]*db.File `json:"files,omitempty"`
+
+	userIds []string
+	fileIds []string
+}
+
+// GetFileActivity returns the history of a file, merging its callback events with the actions
+// taken on it through the demo, newest first
+func GetFileActivity(c *gin.Context) {
+	file, ok := findFileWithPermission(c, "readable")
+	if !ok {
+		return
+	}
+	fileGuid := file.Guid
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page <= 0 {
+		page = 1
+	}
+	size, _ := strconv.Atoi(c.Query("size"))
+	if size <= 0 {
+		size = 50
+	}
+	size = min(size, 100)
+	// Every page reads the rows of the pages before it from both sources
+	if page*size > maxActivityRows {
+		c.JSON(400, gin.H{"message": "page is too far, at most " + strconv.Itoa(maxActivityRows) + " entries can be listed"})
+		return
+	}
+
+	// Both sources are sorted, so the first page*size rows of each cover the requested page
+	filter := &db.EventFilter{FileId: fileGuid}
+	fileEvents, err := db.FindAllEvents(invoker.DB, filter, 1, page*size, "e.created_at desc, e.id desc")
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	activities, err := db.FindFileActivities(invoker.DB, fileGuid, page*size)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	eventCount, err := db.CountEvents(invoker.DB, filter)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	activityCount, err := db.CountFileActivities(invoker.DB, fileGuid)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	list := make([]*ActivityEntry, 0, len(fileEvents)+len(activities))
+	for i := range fileEvents {
+		list = append(list, activityFromEvent(&fileEvents[i]))
+	}
+	for i := range activities {
+		list = append(list, activityFromLocal(&activities[i]))
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreatedAt > list[j].CreatedAt
+	})
+	if start := (page - 1) * size; start < len(list) {
+		list = list[start:min(start+size, len(list))]
+	} else {
+		list = list[:0]
+	}
+
+	if err = resolveActivities(list); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{
+		"list":  list,
+		"count": eventCount + activityCount,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+func activityFromEvent(e *db.Event) *ActivityEntry {
+	entry := &ActivityEntry{
+		Source:    ActivitySourceEvent,
+		Id:        e.ID,
+		Type:      e.Type,
+		Action:    e.Action,
+		CreatedAt: e.CreatedAt,
+		ActorId:   e.UserId,
+		Detail:    json.RawMessage(e.RawData),
+	}
+	if !json.Valid([]byte(e.RawData)) {
+		entry.Detail = e.RawData
+	}
+	if p, err := events.Parse(e.Type, []byte(e.RawData)); err == nil {
+		entry.userIds = p.UserIds()
+		entry.fileIds = p.FileIds()
+	}
+	return entry
+}
+
+func activityFromLocal(a *db.FileActivity) *ActivityEntry {
+	entry := &ActivityEntry{
+		Source:    ActivitySourceLocal,
+		Id:        a.ID,
+		Type:      "File",
+		Action:    a.Action,
+		CreatedAt: a.CreatedAt,
+		ActorId:   strconv.FormatInt(a.UserId, 10),
+		Detail:    a.Detail,
+		fileIds:   []string{a.FileGuid},
+	}
+	for _, key := range []string{"sourceFileId", "targetFileId", "fromFolderId", "toFolderId"} {
+		if id, ok := a.Detail[key].(string); ok && id != "" {
+			entry.fileIds = append(entry.fileIds, id)
+		}
+	}
+	if users, ok := a.Detail["users"].(map[string]interface{}); ok {
+		for id := range users {
+			entry.userIds = append(entry.userIds, id)
+		}
+		sort.Strings(entry.userIds)
+	}
+	return entry
+}
+
+// resolveActivities loads the actors, users and files of the entries in two queries
+func resolveActivities(list []*ActivityEntry) error {
+	var userIds []int64
+	var fileIds []string
+	for _, entry := range list {
+		for _, id := range append([]string{entry.ActorId}, entry.userIds...) {
+			if uid, err := strconv.ParseInt(id, 10, 64); err == nil {
+				userIds = append(userIds, uid)
+			}
+		}
+		fileIds = append(fileIds, entry.fileIds...)
+	}
+
+	users, err := db.FindUsersByIds(invoker.DB, userIds)
+	if err != nil {
+		return err
+	}
+	files, err := db.FindFilesByGuids(invoker.DB, fileIds)
+	if err != nil {
+		return err
+	}
+	userMap := make(map[string]*UserSummary, len(users))
+	for i := range users {
+		userMap[strconv.FormatInt(users[i].ID, 10)] = newUserSummary(&users[i])
+	}
+	fileMap := make(map[string]*db.File, len(files))
+	for i := range files {
+		fileMap[files[i].Guid] = &files[i]
+	}
+
+	for _, entry := range list {
+		entry.Actor = userMap[entry.ActorId]
+		for _, id := range entry.userIds {
+			if u, ok := userMap[id]; ok && id != entry.ActorId {
+				if entry.Users == nil {
+					entry.Users = map[string]*UserSummary{}
+				}
+				entry.Users[id] = u
+			}
+		}
+		for _, id := range entry.fileIds {
+			if f, ok := fileMap[id]; ok {
+				if entry.Files == nil {
+					entry.Files = map[string]*db.File{}
+				}
+				entry.Files[id] = f
+			}
+		}
+	}
+	return nil
+}
+
+// recordFileActivity records an action taken on a file, failures are logged and do not fail the request
+func recordFileActivity(fileGuid string, userId int64, action string, detail db.ActivityDetail) {
+	err := db.CreateFileActivity(invoker.DB, &db.FileActivity{
+		FileGuid: fileGuid,
+		UserId:   userId,
+		Action:   action,
+		Detail:   detail,
+	})
+	if err != nil {
+		elog.Warn("record file activity failed", l.S("fileGuid", fileGuid), l.S("action", action), l.E(err))
+	}
+}
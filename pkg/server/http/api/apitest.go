@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gotomicro/cetus/l"
@@ -14,6 +15,7 @@ import (
 	"sdk-demo-go/pkg/consts"
 	"sdk-demo-go/pkg/invoker"
 	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/stream"
 )
 
 var createErr error
@@ -31,19 +33,47 @@ func GetAllApiTest(c *gin.Context) {
 		TaskId: taskId,
 	}
 	c.JSON(200, resp)
+	userId := getUserIdFromToken(c)
 	go func() {
 		createErr = nil
+		done := make(chan struct{})
+		go publishTestProgress(userId, taskId, done)
 		res := sdkctl.TestAll(context.Background(), testType)
+		close(done)
 		// Insert data after the tests finish
 		createErr = createTestApi(res, taskId)
 		if createErr != nil {
 			elog.Error("Create test result", l.S("error: ", createErr.Error()))
-			handleDBError(c, createErr)
+			stream.Publish(userId, stream.TypeApiTest, gin.H{"taskId": taskId, "status": consts.TestError})
 			return
 		}
+		stream.Publish(userId, stream.TypeApiTest, gin.H{"taskId": taskId, "status": consts.TestFinish})
 	}()
 }
 
+// publishTestProgress pushes the test progress to the user's stream every second until done is closed
+func publishTestProgress(userId int64, taskId string, done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	last := -1.0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if progress := sdkctl.TestProgress; progress != last {
+				last = progress
+				stream.Publish(userId, stream.TypeApiTest, gin.H{
+					"taskId":   taskId,
+					"status":   consts.TestProcessing,
+					"progress": progress,
+				})
+			}
+		}
+	}
+}
+
 func CheckETestProgress(c *gin.Context) {
 	taskId, ok := c.GetQuery("taskId")
 	if !ok {
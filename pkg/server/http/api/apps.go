@@ -6,6 +6,7 @@ import (
 	sdkapi "github.com/shimo-open/sdk-kit-go/api"
 
 	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/server/http/middlewares"
 	"sdk-demo-go/pkg/utils"
 )
 
@@ -45,6 +46,8 @@ func PutEndpointUrl(c *gin.Context) {
 		handleSdkMgrError(c, resp.Response().Body(), resp.Response().StatusCode())
 		return
 	}
+	middlewares.AuditTarget(c, "appId", appId)
+	middlewares.AuditAfter(c, gin.H{"endpointUrl": url})
 
 	c.JSON(204, nil)
 }
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+)
+
+// auditExportBatch is how many audit logs are read per query while exporting
+const auditExportBatch = 500
+
+// auditCSVHeader is the header row of the CSV export
+var auditCSVHeader = []string{"id", "createdAt", "actorId", "action", "path", "targetType", "targetId",
+	"status", "requestId", "ip", "userAgent", "before", "after", "diff"}
+
+// ListAuditLogs returns the audit logs of the app, newest first
+// Only the users listed in audit.admins see every log, the others see their own requests
+func ListAuditLogs(c *gin.Context) {
+	filter, ok := auditLogFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page <= 0 {
+		page = 1
+	}
+	size, _ := strconv.Atoi(c.Query("size"))
+	if size <= 0 {
+		size = 50
+	}
+	size = min(size, 100)
+
+	logs, err := db.FindAuditLogs(invoker.DB, filter, page, size)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	count, err := db.CountAuditLogs(invoker.DB, filter)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{
+		"list":  logs,
+		"count": count,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// ExportAuditLogs streams the audit logs matching the ListAuditLogs filters, oldest first,
+// as CSV (format=csv, the default) or JSON Lines (format=jsonl)
+func ExportAuditLogs(c *gin.Context) {
+	filter, ok := auditLogFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "jsonl":
+		contentType = "application/x-ndjson"
+	default:
+		c.JSON(400, gin.H{"message": "format must be csv or jsonl"})
+		return
+	}
+
+	// The first batch is read before the headers are sent so a failing query still gets an error response
+	logs, err := db.FindAuditLogsAfter(invoker.DB, filter, 0, auditExportBatch)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	filename := fmt.Sprintf("audit-logs-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(200)
+
+	csvWriter := csv.NewWriter(c.Writer)
+	jsonEncoder := json.NewEncoder(c.Writer)
+	if format == "csv" {
+		_ = csvWriter.Write(auditCSVHeader)
+	}
+	for len(logs) > 0 {
+		for i := range logs {
+			if format == "csv" {
+				err = csvWriter.Write(auditCSVRow(&logs[i]))
+			} else {
+				err = jsonEncoder.Encode(&logs[i])
+			}
+			if err != nil {
+				// The client went away, the response is already partly written
+				return
+			}
+		}
+		csvWriter.Flush()
+		c.Writer.Flush()
+		if len(logs) < auditExportBatch {
+			return
+		}
+		logs, err = db.FindAuditLogsAfter(invoker.DB, filter, logs[len(logs)-1].ID, auditExportBatch)
+		if err != nil {
+			elog.Error("export audit logs failed", l.E(err))
+			return
+		}
+	}
+}
+
+func auditCSVRow(a *db.AuditLog) []string {
+	return []string{
+		strconv.FormatInt(a.ID, 10),
+		time.Unix(a.CreatedAt, 0).Format(time.RFC3339),
+		strconv.FormatInt(a.ActorId, 10),
+		a.Action,
+		a.Path,
+		a.TargetType,
+		a.TargetId,
+		strconv.Itoa(a.Status),
+		a.RequestId,
+		a.IP,
+		a.UserAgent,
+		string(a.Before),
+		string(a.After),
+		string(a.Diff),
+	}
+}
+
+// auditLogFilterFromQuery reads actorId, action, targetType, targetId, from and to (Unix seconds)
+// The filter of a user who is not an audit admin is restricted to its own requests; on failure the response
+// has been written
+func auditLogFilterFromQuery(c *gin.Context) (*db.AuditLogFilter, bool) {
+	f, err := parseAuditLogFilter(c)
+	if err != nil {
+		c.JSON(400, gin.H{"message": err.Error()})
+		return nil, false
+	}
+	userId := getUserIdFromToken(c)
+	if isAuditAdmin(userId) {
+		return f, true
+	}
+	if f.ActorId != 0 && f.ActorId != userId {
+		c.JSON(403, gin.H{"message": "requires audit admin"})
+		return nil, false
+	}
+	f.ActorId = userId
+	return f, true
+}
+
+func parseAuditLogFilter(c *gin.Context) (*db.AuditLogFilter, error) {
+	f := &db.AuditLogFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("targetType"),
+		TargetId:   c.Query("targetId"),
+	}
+	var err error
+	if v := c.Query("actorId"); v != "" {
+		if f.ActorId, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.New("invalid actorId")
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		if f.From, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.New("invalid from")
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if f.To, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.New("invalid to")
+		}
+	}
+	return f, nil
+}
+
+// isAuditAdmin tells whether a user is listed in audit.admins
+func isAuditAdmin(userId int64) bool {
+	id := strconv.FormatInt(userId, 10)
+	for _, admin := range econf.GetStringSlice("audit.admins") {
+		if admin == id {
+			return true
+		}
+	}
+	return false
+}
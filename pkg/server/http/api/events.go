@@ -1,8 +1,11 @@
 package api
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gotomicro/ego/core/elog"
@@ -16,11 +19,11 @@ type EventParam struct {
 	UserId string `json:"userId"`
 }
 
+// GetEvents lists events by page, or by cursor when the cursor query parameter is present
+// In cursor mode an empty cursor starts from the newest event and nextCursor is empty on the last page
 func GetEvents(c *gin.Context) {
 	_page, _ := c.GetQuery("page")
 	_size, _ := c.GetQuery("size")
-	fileId, _ := c.GetQuery("fileId")
-	userId, _ := c.GetQuery("userId")
 
 	var page int
 	if _page == "" || _page == "0" {
@@ -35,10 +38,24 @@ func GetEvents(c *gin.Context) {
 	} else {
 		size, _ = strconv.Atoi(_size)
 	}
+	if size <= 0 {
+		size = 50
+	}
+	size = min(size, 100)
 
-	event := &db.Event{
-		FileId: fileId,
-		UserId: userId,
+	filter, err := eventFilterFromQuery(c)
+	if err != nil {
+		c.JSON(400, gin.H{"message": err.Error()})
+		return
+	}
+
+	_cursor, keyset := c.GetQuery("cursor")
+	var cursor int64
+	if _cursor != "" {
+		if cursor, err = strconv.ParseInt(_cursor, 10, 64); err != nil || cursor < 0 {
+			c.JSON(400, gin.H{"message": "invalid cursor"})
+			return
+		}
 	}
 
 	var wg sync.WaitGroup
@@ -47,11 +64,15 @@ func GetEvents(c *gin.Context) {
 
 	wg.Add(2)
 	go func() {
-		events, _ = db.FindAllEvents(invoker.DB, event, page, size, "")
+		if keyset {
+			events, _ = db.FindEventsBefore(invoker.DB, filter, cursor, size)
+		} else {
+			events, _ = db.FindAllEvents(invoker.DB, filter, page, size, "")
+		}
 		wg.Done()
 	}()
 	go func() {
-		count, _ = db.CountEvents(invoker.DB, event)
+		count, _ = db.CountEvents(invoker.DB, filter)
 		wg.Done()
 	}()
 	wg.Wait()
@@ -92,6 +113,21 @@ func GetEvents(c *gin.Context) {
 
 	eventList := db.BindUserAndFilesInfo(es, userMap, fileMap)
 
+	if keyset {
+		// The cursor follows the fetched rows, events dropped by BindUserAndFilesInfo still advance it
+		nextCursor := ""
+		if len(events) == size {
+			nextCursor = strconv.FormatInt(events[len(events)-1].ID, 10)
+		}
+		c.JSON(200, gin.H{
+			"list":       eventList,
+			"count":      count,
+			"size":       size,
+			"nextCursor": nextCursor,
+		})
+		return
+	}
+
 	c.JSON(200, gin.H{
 		"list":  eventList,
 		"count": count,
@@ -100,6 +136,118 @@ func GetEvents(c *gin.Context) {
 	})
 }
 
+// EventBucketInfo is an aggregate bucket, with the file when events are grouped by file
+type EventBucketInfo struct {
+	db.EventBucket
+	// File is the file of the bucket, nil when it no longer exists
+	File *db.File `json:"file,omitempty"`
+}
+
+// GetEventStats counts the events matching the GetEvents filters per type, day or file
+// Days are cut in the time zone given by utcOffset (minutes east of UTC, defaults to the server's)
+func GetEventStats(c *gin.Context) {
+	filter, err := eventFilterFromQuery(c)
+	if err != nil {
+		c.JSON(400, gin.H{"message": err.Error()})
+		return
+	}
+	groupBy := c.DefaultQuery("groupBy", db.EventGroupByType)
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = 10
+		if groupBy == db.EventGroupByDay {
+			limit = 366
+		}
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	_, utcOffset := time.Now().Zone()
+	if v := c.Query("utcOffset"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes < -14*60 || minutes > 14*60 {
+			c.JSON(400, gin.H{"message": "invalid utcOffset"})
+			return
+		}
+		utcOffset = minutes * 60
+	}
+
+	switch groupBy {
+	case db.EventGroupByType, db.EventGroupByDay, db.EventGroupByFile:
+	default:
+		c.JSON(400, gin.H{"message": "groupBy must be type, day or file"})
+		return
+	}
+	buckets, err := db.AggregateEvents(invoker.DB, filter, groupBy, utcOffset, limit)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	list := make([]EventBucketInfo, len(buckets))
+	fileIds := make([]string, 0, len(buckets))
+	for i, b := range buckets {
+		list[i].EventBucket = b
+		fileIds = append(fileIds, b.Key)
+	}
+	if groupBy == db.EventGroupByFile && len(fileIds) > 0 {
+		files, err := db.FindFilesByGuids(invoker.DB, fileIds)
+		if err != nil {
+			handleDBError(c, err)
+			return
+		}
+		fileMap := make(map[string]*db.File, len(files))
+		for i := range files {
+			fileMap[files[i].Guid] = &files[i]
+		}
+		for i := range list {
+			list[i].File = fileMap[list[i].Key]
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"groupBy": groupBy,
+		"list":    list,
+	})
+}
+
+// eventFilterFromQuery reads the event filters shared by GetEvents and GetEventStats:
+// fileId, userId, type and action (comma separated), from and to (Unix seconds) and q (text in the raw data)
+func eventFilterFromQuery(c *gin.Context) (*db.EventFilter, error) {
+	f := &db.EventFilter{
+		FileId:  c.Query("fileId"),
+		UserId:  c.Query("userId"),
+		Types:   splitQuery(c.Query("type")),
+		Actions: splitQuery(c.Query("action")),
+		Query:   c.Query("q"),
+	}
+	var err error
+	if v := c.Query("from"); v != "" {
+		if f.From, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.New("invalid from")
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if f.To, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.New("invalid to")
+		}
+	}
+	return f, nil
+}
+
+// splitQuery splits a comma separated query value, dropping empty entries
+func splitQuery(v string) []string {
+	var values []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
 func GetSystemMessages(c *gin.Context) {
 	from, _ := c.GetQuery("from")
 	to, _ := c.GetQuery("to")
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/server/http/middlewares"
+)
+
+const (
+	// maxTagLength is the longest tag accepted, in bytes
+	maxTagLength = 64
+	// maxMetadataNameLength is the longest metadata key accepted, in bytes
+	maxMetadataNameLength = 64
+	// maxMetadataValueLength is the longest metadata value accepted, in bytes
+	maxMetadataValueLength = 255
+)
+
+// GetFileTags lists the tags of a file
+func GetFileTags(c *gin.Context) {
+	file, ok := findFileWithPermission(c, "readable")
+	if !ok {
+		return
+	}
+	tags, err := db.FindFileTags(invoker.DB, file.ID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, append([]string{}, tags...))
+}
+
+// AddFileTags adds tags to a file, requires the editable permission
+func AddFileTags(c *gin.Context) {
+	updateFileTags(c, db.AddFileTags)
+}
+
+// ReplaceFileTags replaces every tag of a file, requires the editable permission
+func ReplaceFileTags(c *gin.Context) {
+	updateFileTags(c, db.ReplaceFileTags)
+}
+
+// RemoveFileTag removes a tag from a file, requires the editable permission
+func RemoveFileTag(c *gin.Context) {
+	file, ok := findFileWithPermission(c, "editable")
+	if !ok {
+		return
+	}
+	before, err := db.FindFileTags(invoker.DB, file.ID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if err = db.RemoveFileTag(invoker.DB, file.ID, c.Param("tag")); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	middlewares.AuditBefore(c, before)
+	c.JSON(204, nil)
+}
+
+// GetFileMetadata returns the custom metadata of a file
+func GetFileMetadata(c *gin.Context) {
+	file, ok := findFileWithPermission(c, "readable")
+	if !ok {
+		return
+	}
+	metadata, err := db.FindFileMetadata(invoker.DB, file.ID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, metadata)
+}
+
+// UpdateFileMetadata sets custom metadata of a file, a null value removes the key, requires the editable permission
+func UpdateFileMetadata(c *gin.Context) {
+	file, ok := findFileWithPermission(c, "editable")
+	if !ok {
+		return
+	}
+	var body map[string]*string
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+	for name, value := range body {
+		if name == "" || len(name) > maxMetadataNameLength {
+			c.JSON(400, gin.H{"message": "metadata keys must be 1 to 64 bytes long"})
+			return
+		}
+		if value != nil && len(*value) > maxMetadataValueLength {
+			c.JSON(400, gin.H{"message": "metadata values must be at most 255 bytes long"})
+			return
+		}
+	}
+
+	before, err := db.FindFileMetadata(invoker.DB, file.ID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if err = db.UpdateFileMetadata(invoker.DB, file.ID, body); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	middlewares.AuditBefore(c, before)
+	middlewares.AuditAfter(c, body)
+
+	c.JSON(204, nil)
+}
+
+// RemoveFileMetadata removes a custom metadata key from a file, requires the editable permission
+func RemoveFileMetadata(c *gin.Context) {
+	file, ok := findFileWithPermission(c, "editable")
+	if !ok {
+		return
+	}
+	key := c.Param("key")
+	before, err := db.FindFileMetadata(invoker.DB, file.ID, key)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if err = db.UpdateFileMetadata(invoker.DB, file.ID, map[string]*string{key: nil}); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	middlewares.AuditBefore(c, before)
+
+	c.JSON(204, nil)
+}
+
+func updateFileTags(c *gin.Context, update func(*gorm.DB, int64, int64, []string) error) {
+	file, ok := findFileWithPermission(c, "editable")
+	if !ok {
+		return
+	}
+	var body []string
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+	tags, ok := normalizeTags(body)
+	if !ok {
+		c.JSON(400, gin.H{"message": "tags must be 1 to 64 bytes long"})
+		return
+	}
+
+	before, err := db.FindFileTags(invoker.DB, file.ID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if err = update(invoker.DB, file.ID, getUserIdFromToken(c), tags); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	after, err := db.FindFileTags(invoker.DB, file.ID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	middlewares.AuditBefore(c, before)
+	middlewares.AuditAfter(c, after)
+
+	c.JSON(200, append([]string{}, after...))
+}
+
+// normalizeTags trims and dedupes tags, false when one of them is empty or too long
+func normalizeTags(tags []string) ([]string, bool) {
+	seen := make(map[string]bool, len(tags))
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len(tag) > maxTagLength {
+			return nil, false
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			res = append(res, tag)
+		}
+	}
+	sort.Strings(res)
+	return res, true
+}
+
+// findFileWithPermission fetches the file of the request and checks the current user has a permission on it,
+// the error response is sent when it returns false
+func findFileWithPermission(c *gin.Context, permission string) (*db.File, bool) {
+	file, err := db.FindFileByGuidAndUserId(invoker.DB, getUserIdFromToken(c), c.Param("fileGuid"))
+	if err != nil {
+		handleDBError(c, err)
+		return nil, false
+	}
+	if !file.Permissions[permission] {
+		c.JSON(403, gin.H{"message": "requires " + permission + " permission"})
+		return nil, false
+	}
+	return file, true
+}
+
+// loadUserFileDetails sets what the user did with the files (starred, pinned) and their tags and metadata
+func loadUserFileDetails(userId int64, files []db.File) error {
+	if err := db.LoadUserFileStates(invoker.DB, userId, files); err != nil {
+		return err
+	}
+	return db.LoadFileTagsAndMetadata(invoker.DB, files)
+}
@@ -1,11 +1,12 @@
 package api
 
 import (
-	"bytes"
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
@@ -27,15 +28,39 @@ import (
 	"github.com/spf13/cast"
 	"gorm.io/gorm"
 
+	"sdk-demo-go/pkg/access"
 	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/jobs"
 	"sdk-demo-go/pkg/models/db"
 	"sdk-demo-go/pkg/server/http/middlewares"
 	"sdk-demo-go/pkg/utils"
 )
 
+// GetUserFiles lists the files of the current user
+// With the parentId query parameter only the children of that folder are listed, an empty parentId lists the root
+// The tag (repeatable) and metadata[key]=value query parameters only list the files carrying all of them
 func GetUserFiles(c *gin.Context) {
 	userId := getUserIdFromToken(c)
-	files, err := db.FindFileByUserId(invoker.DB, userId, 0, "")
+	filter := db.FileFilter{Tags: c.QueryArray("tag"), Metadata: c.QueryMap("metadata")}
+	if parentGuid, ok := c.GetQuery("parentId"); ok {
+		if !checkParentFolder(c, userId, parentGuid, "readable") {
+			return
+		}
+		files, err := db.FindFolderChildren(invoker.DB, userId, parentGuid, filter)
+		if err == nil {
+			err = loadUserFileDetails(userId, files)
+		}
+		if err != nil {
+			handleDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, files)
+		return
+	}
+	files, err := db.FindFilteredFilesByUserId(invoker.DB, userId, filter, 0, "")
+	if err == nil {
+		err = loadUserFileDetails(userId, files)
+	}
 	if err != nil {
 		handleDBError(c, err)
 		return
@@ -63,6 +88,11 @@ func OpenFile(c *gin.Context) {
 	appId := econf.GetString("shimoSDK.appId")
 	secret := econf.GetString("shimoSDK.appSecret")
 	userId := c.GetInt64("userId")
+	if userId > 0 {
+		if err = db.RecordFileOpen(invoker.DB, userId, file.ID); err != nil {
+			elog.Warn("record file open failed", l.S("fileGuid", fileGuid), l.E(err))
+		}
+	}
 
 	c.HTML(200, "shimo-file", gin.H{
 		"rootCSSClasses": "editor-page",
@@ -288,32 +318,40 @@ func CreateFile(c *gin.Context) {
 		handleSdkMgrError(c, res.Response().Body(), res.Response().StatusCode())
 		return
 	}
+	recordFileActivity(file.Guid, userId, db.FileActivityCreate, db.ActivityDetail{"name": file.Name})
 
 	c.JSON(200, file)
 }
 
+// UploadFile streams the "file" part of a multipart upload straight into storage
+// Only the first bytes are buffered to detect the MIME type
 func UploadFile(c *gin.Context) {
-	_file, err := c.FormFile("file")
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
 		c.JSON(400, gin.H{"message": "file update failed"})
 		return
 	}
 
-	file, err := _file.Open()
-	if err != nil {
-		c.JSON(400, gin.H{"message": "file open failed"})
-		return
-
+	var part *multipart.Part
+	for {
+		part, err = reader.NextPart()
+		if err != nil {
+			c.JSON(400, gin.H{"message": "file update failed"})
+			return
+		}
+		if part.FormName() == "file" && part.FileName() != "" {
+			break
+		}
+		part.Close()
 	}
-	defer file.Close()
+	defer part.Close()
 
-	content, err := io.ReadAll(file)
+	fileName := part.FileName()
+	mimeType, content, err := SniffMimeType(fileName, part)
 	if err != nil {
 		c.JSON(400, gin.H{"message": "file read failed"})
+		return
 	}
-
-	mimeType := DetectMimeType(_file.Filename, content)
-	fileName := _file.Filename
 	userId := getUserIdFromToken(c)
 
 	// TODO determine the default MIME type
@@ -328,20 +366,14 @@ func UploadFile(c *gin.Context) {
 		handleDBError(c, err)
 		return
 	}
-	// Generate a temporary download link
-	uploadUrl, err := invoker.Services.AwosService.GetUploadURL(f.Guid, 1800)
-	if err != nil {
-		c.AbortWithStatusJSON(500, gin.H{
-			"message": "GetUploadURL failed",
-			"error":   err,
-		})
-		return
-	}
-
-	// Use the download link to store the file in object storage
-	req, _ := http.NewRequest("PUT", uploadUrl, bytes.NewReader(content))
-	_, err = http.DefaultClient.Do(req)
+	// Stream the file into object storage
+	err = invoker.Services.Storage.Upload(f.Guid, content)
 	if err != nil {
+		// Roll back the created file
+		rmErr := db.RemoveFileById(invoker.DB, f.ID)
+		if rmErr != nil {
+			elog.Warn("rollback file failed", l.E(rmErr))
+		}
 		c.AbortWithStatusJSON(500, gin.H{
 			"message": "file save failed",
 			"error":   err,
@@ -349,10 +381,25 @@ func UploadFile(c *gin.Context) {
 		elog.Error("file save failed", l.E(err))
 		return
 	}
+	recordFileActivity(f.Guid, userId, db.FileActivityCreate, db.ActivityDetail{"name": f.Name, "source": "upload"})
 
 	c.JSON(200, f)
 }
 
+// sniffLen is how many leading bytes are inspected to detect the MIME type
+const sniffLen = 3072
+
+// SniffMimeType detects the MIME type from the filename and the first bytes of r
+// The returned reader still yields the full content, including the inspected bytes
+func SniffMimeType(filename string, r io.Reader) (string, io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return "", nil, err
+	}
+	return DetectMimeType(filename, head), br, nil
+}
+
 func DetectMimeType(filename string, content []byte) string {
 	ext := strings.ToLower(filepath.Ext(filename))
 	// Prefer determining the file type by extension
@@ -418,7 +465,7 @@ func GetFileInfo(c *gin.Context) {
 		}
 	}
 
-	file, err := db.FindFileByGuidAndUserId(invoker.DB, userId, fileGuid)
+	file, err := db.FindFileByGuidWithShareLink(invoker.DB, userId, fileGuid, c.GetString("shareToken"))
 	if err != nil {
 		handleDBError(c, err)
 		return
@@ -494,6 +541,10 @@ func GetFileInfo(c *gin.Context) {
 	if userId < 0 {
 		configToken = sdkapi.AnonymousToken
 	}
+	// Share link holders keep the link in the editor token so the callbacks resolve the same permissions
+	if shareToken := c.GetString("shareToken"); shareToken != "" {
+		configToken = utils.SignShareJWT(userId, shareToken, 24*time.Hour)
+	}
 
 	if returnConnectConfig {
 		resp := struct {
@@ -572,13 +623,28 @@ func ImportFile(c *gin.Context) {
 		c.JSON(500, "taskId not found")
 		return
 	}
+	// Poll the import in the background, the file is rolled back if it fails
+	job := db.Job{
+		UserId:   file.CreatorId,
+		Type:     jobs.TypeImport,
+		FileGuid: file.Guid,
+		TaskId:   taskId,
+		Payload:  db.JobData{"rollback": true},
+	}
+	if err = jobs.Enqueue(&job); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	recordFileActivity(file.Guid, file.CreatorId, db.FileActivityCreate, db.ActivityDetail{"name": file.Name, "source": "import"})
 	// Return the result
 	resp := struct {
 		db.File
 		TaskId string `json:"taskId"`
+		JobId  string `json:"jobId"`
 	}{
 		File:   file,
 		TaskId: taskId,
+		JobId:  job.Guid,
 	}
 	c.JSON(200, resp)
 }
@@ -631,13 +697,31 @@ func ImportFileByUrl(c *gin.Context) {
 		c.JSON(500, "taskId not found")
 		return
 	}
+	// Poll the import in the background, the file is rolled back if it fails
+	job := db.Job{
+		UserId:   file.CreatorId,
+		Type:     jobs.TypeImport,
+		FileGuid: file.Guid,
+		TaskId:   taskId,
+		Payload: db.JobData{
+			"version":  econf.GetString("shimoSDK.importByUrlVersion"),
+			"rollback": true,
+		},
+	}
+	if err = jobs.Enqueue(&job); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	recordFileActivity(file.Guid, file.CreatorId, db.FileActivityCreate, db.ActivityDetail{"name": file.Name, "source": "import"})
 	// Return the file information
 	resp := struct {
 		db.File
 		TaskId string `json:"taskId"`
+		JobId  string `json:"jobId"`
 	}{
 		File:   file,
 		TaskId: taskId,
+		JobId:  job.Guid,
 	}
 	c.JSON(200, resp)
 }
@@ -730,7 +814,21 @@ func ExportFile(c *gin.Context) {
 		handleSdkMgrError(c, res.Response().Body(), res.Response().StatusCode())
 		return
 	}
-	c.JSON(200, res)
+	// The job result holds the download URL once the export is done
+	job := db.Job{
+		UserId:   getUserIdFromToken(c),
+		Type:     jobs.TypeExport,
+		FileGuid: fileGuid,
+		TaskId:   res.Data.TaskID,
+	}
+	if err = jobs.Enqueue(&job); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{
+		"jobId": job.Guid,
+		"data":  res.Data,
+	})
 }
 
 func DuplicateFile(c *gin.Context) {
@@ -770,6 +868,8 @@ func DuplicateFile(c *gin.Context) {
 		handleSdkMgrError(c, res.Response().Body(), res.Response().StatusCode())
 		return
 	}
+	recordFileActivity(newFile.Guid, userId, db.FileActivityCreate, db.ActivityDetail{"name": newFile.Name, "sourceFileId": fileGuid})
+	recordFileActivity(fileGuid, userId, db.FileActivityDuplicate, db.ActivityDetail{"targetFileId": newFile.Guid})
 	c.JSON(200, newFile)
 }
 
@@ -799,27 +899,17 @@ func DeleteFile(c *gin.Context) {
 		handleDBError(c, err)
 		return
 	}
-	if file.IsShimoFile == 1 {
-		auth := utils.GetAuth(getUserIdFromToken(c))
-		params := sdkapi.DeleteFileReq{
-			Metadata: auth,
-			FileID:   fileGuid,
-		}
-		_, rErr := invoker.SdkMgr.DeleteFile(c.Request.Context(), params)
-		if rErr != nil {
-			elog.Warn("file remove failed", l.E(rErr))
-		}
-	} else {
-		rErr := invoker.Services.AwosService.Remove(file.Guid)
-		if rErr != nil {
-			elog.Warn("file remove failed", l.E(rErr))
-		}
+	if file.IsFolder {
+		c.JSON(400, gin.H{"message": "use DELETE /api/folders/:folderGuid to delete a folder"})
+		return
 	}
-	err = db.RemoveFileByGuid(invoker.DB, fileGuid)
+	middlewares.AuditBefore(c, file)
+	err = db.TrashFiles(invoker.DB, getUserIdFromToken(c), []db.File{*file})
 	if err != nil {
 		handleDBError(c, err)
 		return
 	}
+	recordFileActivity(fileGuid, getUserIdFromToken(c), db.FileActivityDelete, db.ActivityDetail{"name": file.Name})
 
 	c.JSON(204, nil)
 }
@@ -832,12 +922,43 @@ func BatchDeleteFile(c *gin.Context) {
 			return
 		}
 	}
-	err := db.RemoveFileByGuids(invoker.DB, fileGuids)
+	files, err := db.FindFilesByGuids(invoker.DB, fileGuids)
 	if err != nil {
 		handleDBError(c, err)
 		return
 	}
-	// TODO batch deletion does not call the SDK delete API yet
+	// Folders follow DELETE /api/folders/:folderGuid
+	if _, err = db.ResolveFilePermissions(invoker.DB, getUserIdFromToken(c), files); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	for i := range files {
+		if !files[i].IsFolder {
+			continue
+		}
+		if !canManageFile(c, &files[i]) {
+			return
+		}
+		descendants, err := db.FindFolderDescendants(invoker.DB, files[i].Guid)
+		if err != nil {
+			handleDBError(c, err)
+			return
+		}
+		if len(descendants) > 0 && c.Query("recursive") != "true" {
+			c.JSON(409, gin.H{"message": "folder " + files[i].Name + " is not empty"})
+			return
+		}
+	}
+	middlewares.AuditTarget(c, "fileGuid", strings.Join(fileGuids, ","))
+	middlewares.AuditBefore(c, files)
+	err = db.TrashFiles(invoker.DB, getUserIdFromToken(c), files)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	for _, file := range files {
+		recordFileActivity(file.Guid, getUserIdFromToken(c), db.FileActivityDelete, db.ActivityDetail{"name": file.Name})
+	}
 	c.JSON(204, nil)
 }
 
@@ -850,11 +971,19 @@ func RenameFile(c *gin.Context) {
 		return
 	}
 
-	err := db.RenameFile(invoker.DB, fileGuid, body.Name)
+	file, err := db.FindFileByGuid(invoker.DB, fileGuid)
 	if err != nil {
 		handleDBError(c, err)
 		return
 	}
+	err = db.RenameFile(invoker.DB, fileGuid, body.Name)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	middlewares.AuditBefore(c, gin.H{"name": file.Name})
+	middlewares.AuditAfter(c, gin.H{"name": body.Name})
+	recordFileActivity(fileGuid, getUserIdFromToken(c), db.FileActivityRename, db.ActivityDetail{"from": file.Name, "to": body.Name})
 
 	c.JSON(204, nil)
 }
@@ -863,6 +992,7 @@ type Collaborators struct {
 	Id          string            `json:"id"`
 	Name        string            `json:"name"`
 	Permissions []map[string]bool `json:"permissions"`
+	Role        string            `json:"role,omitempty"`
 	IsCreator   bool              `json:"isCreator"`
 }
 
@@ -899,6 +1029,7 @@ func GetCollaborators(c *gin.Context) {
 
 	returnAll, _ := c.GetQuery("all")
 	res := make([]Collaborators, 0, len(users))
+	roles := rolePermissions(appIdFromContext(c))
 
 	for _, user := range users {
 		if user.ID == me.ID {
@@ -914,6 +1045,7 @@ func GetCollaborators(c *gin.Context) {
 				Id:          strconv.FormatInt(user.ID, 10),
 				Name:        user.Name,
 				Permissions: permissions,
+				Role:        access.MatchRole(roles, permissions[0]),
 				IsCreator:   isCreator,
 			})
 		} else if returnAll == "true" || returnAll == "1" {
@@ -950,8 +1082,8 @@ func UpdateCollaborators(c *gin.Context) {
 		wg.Done()
 	}()
 
-	var body map[int64]map[string]bool
-	if err := c.BindJSON(&body); err != nil {
+	var grants map[int64]PermissionGrant
+	if err := c.BindJSON(&grants); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
 		return
 	}
@@ -961,7 +1093,25 @@ func UpdateCollaborators(c *gin.Context) {
 		return
 	}
 
+	roles := rolePermissions(appIdFromContext(c))
+	body := make(map[int64]map[string]bool, len(grants))
+	for userId, g := range grants {
+		p, ok := g.Flags(roles)
+		if !ok {
+			c.JSON(400, gin.H{"message": "unknown role " + g.Role})
+			return
+		}
+		body[userId] = p
+	}
+
 	wg.Wait()
+	before := make(map[int64]map[string]bool, len(mp))
+	for userId, p := range mp {
+		before[userId] = make(map[string]bool, len(p))
+		for k, v := range p {
+			before[userId][k] = v
+		}
+	}
 	for userId, p := range body {
 		checkPermission(p)
 
@@ -1015,6 +1165,13 @@ func UpdateCollaborators(c *gin.Context) {
 		handleDBError(c, err)
 		return
 	}
+	changes := make(map[string]interface{}, len(body))
+	for userId, p := range body {
+		changes[strconv.FormatInt(userId, 10)] = p
+	}
+	recordFileActivity(file.Guid, myId, db.FileActivityPermission, db.ActivityDetail{"users": changes})
+	middlewares.AuditBefore(c, before)
+	middlewares.AuditAfter(c, mp)
 
 	c.JSON(204, nil)
 }
@@ -1059,6 +1216,8 @@ func mergePermission(base, src map[string]bool) {
 			delete(base, string(newK))
 		}
 	}
+	// formFillable is checked by the demo itself, keep it even when the SDK does not list it
+	access.MergeLocalFlags(base, src)
 }
 
 func checkPermission(p map[string]bool) {
@@ -1162,46 +1321,16 @@ func GetImportUrl0(c *gin.Context) {
 		return
 	}
 
-	// Fetch the upload progress
-	var progressResp sdkapi.GetImportProgRes
-	success := false
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-	timeout := time.After(20 * time.Second)
-	for {
-		select {
-		case <-ticker.C:
-			progressParams := sdkapi.GetImportProgReq{
-				Metadata: auth,
-				TaskId:   ImportResp.Data.TaskID,
-			}
-			// Fetch the upload progress
-			if progressResp, _ = invoker.SdkMgr.GetImportProgress(c.Request.Context(), progressParams); progressResp.Status == 0 {
-				success = true
-				break
-			}
-		case <-timeout:
-			handleDBError(c, errors.New("import file timeout"))
-			return
-		}
-		if success {
-			break
-		}
-	}
-
-	// After a successful upload, create a preview and fetch its URL
-	createParams := sdkapi.CreatePreviewReq{
-		Metadata: auth,
-		FileID:   file.Guid,
+	// Poll the import in the background, the file is rolled back if it fails
+	job := db.Job{
+		UserId:   userId,
+		Type:     jobs.TypeImport,
+		FileGuid: file.Guid,
+		TaskId:   ImportResp.Data.TaskID,
+		Payload:  db.JobData{"rollback": true, "createPreview": true},
 	}
-	// Create the preview
-	previewResp, err := invoker.SdkMgr.CreatePreview(c.Request.Context(), createParams)
-	if err != nil || previewResp.Code != "" {
-		rmErr := db.RemoveFileByGuid(invoker.DB, file.Guid)
-		if rmErr != nil {
-			elog.Warn("rollback file failed", l.E(rmErr))
-		}
-		handleSdkMgrError(c, previewResp.Response().Body(), previewResp.Response().StatusCode())
+	if err = jobs.Enqueue(&job); err != nil {
+		handleDBError(c, err)
 		return
 	}
 
@@ -1227,7 +1356,8 @@ func GetImportUrl0(c *gin.Context) {
 	redirectURLWithParams := redirectURL + "?" + queryParams.Encode()
 	// Respond with the constructed address
 	c.JSON(http.StatusOK, gin.H{
-		"url": redirectURLWithParams,
+		"url":   redirectURLWithParams,
+		"jobId": job.Guid,
 	})
 }
 
@@ -1291,31 +1421,17 @@ func GetImportUrl(c *gin.Context) {
 		return
 	}
 
-	// Fetch the upload progress
-	var progressResp sdkapi.GetImportProgRes
-	success := false
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-	timeout := time.After(20 * time.Second)
-	for {
-		select {
-		case <-ticker.C:
-			progressParams := sdkapi.GetImportProgReq{
-				Metadata: auth,
-				TaskId:   ImportResp.Data.TaskID,
-			}
-			// Fetch the upload progress
-			if progressResp, _ = invoker.SdkMgr.GetImportProgress(c.Request.Context(), progressParams); progressResp.Status == 0 {
-				success = true
-				break
-			}
-		case <-timeout:
-			handleDBError(c, errors.New("import file timeout"))
-			return
-		}
-		if success {
-			break
-		}
+	// Poll the import in the background, the file is rolled back if it fails
+	job := db.Job{
+		UserId:   userId,
+		Type:     jobs.TypeImport,
+		FileGuid: file.Guid,
+		TaskId:   ImportResp.Data.TaskID,
+		Payload:  db.JobData{"rollback": true},
+	}
+	if err = jobs.Enqueue(&job); err != nil {
+		handleDBError(c, err)
+		return
 	}
 
 	confEnv := os.Getenv("EGO_CONFIG_PATH")
@@ -1340,7 +1456,8 @@ func GetImportUrl(c *gin.Context) {
 	redirectURLWithParams := redirectURL + "?" + queryParams.Encode()
 	// Respond with the constructed address
 	c.JSON(http.StatusOK, gin.H{
-		"url": redirectURLWithParams,
+		"url":   redirectURLWithParams,
+		"jobId": job.Guid,
 	})
 }
 
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/server/http/middlewares"
+)
+
+// FolderBody is the body used to create a folder or move a file
+type FolderBody struct {
+	// Name is the folder name
+	Name string `json:"name"`
+	// ParentId is the GUID of the destination folder, empty for the root
+	ParentId string `json:"parentId"`
+}
+
+// CreateFolder creates a folder owned by the current user
+func CreateFolder(c *gin.Context) {
+	body := FolderBody{}
+	if err := c.BindJSON(&body); err != nil {
+		return
+	}
+	if body.Name == "" {
+		c.JSON(400, gin.H{"message": "name is required"})
+		return
+	}
+	userId := getUserIdFromToken(c)
+	if !checkParentFolder(c, userId, body.ParentId, "manageable") {
+		return
+	}
+
+	folder := db.File{
+		Name:       body.Name,
+		Type:       "folder",
+		CreatorId:  userId,
+		ParentGuid: body.ParentId,
+		IsFolder:   true,
+	}
+	err, _ := db.CreateFile(invoker.DB, &folder, userId)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	recordFileActivity(folder.Guid, userId, db.FileActivityCreate, db.ActivityDetail{"name": folder.Name})
+	middlewares.AuditTarget(c, "folderGuid", folder.Guid)
+	middlewares.AuditAfter(c, folder)
+
+	c.JSON(200, folder)
+}
+
+// DeleteFolder moves a folder to the recycle bin, a folder that is not empty is only deleted with
+// recursive=true together with everything below it
+func DeleteFolder(c *gin.Context) {
+	folder, err := db.FindFileByGuidAndUserId(invoker.DB, getUserIdFromToken(c), c.Param("folderGuid"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if !canManageFile(c, folder) {
+		return
+	}
+	if !folder.IsFolder {
+		c.JSON(400, gin.H{"message": "not a folder"})
+		return
+	}
+	descendants, err := db.FindFolderDescendants(invoker.DB, folder.Guid)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if len(descendants) > 0 && c.Query("recursive") != "true" {
+		c.JSON(409, gin.H{"message": "folder is not empty"})
+		return
+	}
+
+	if err = db.TrashFiles(invoker.DB, getUserIdFromToken(c), []db.File{*folder}); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	recordFileActivity(folder.Guid, getUserIdFromToken(c), db.FileActivityDelete, db.ActivityDetail{"name": folder.Name})
+	middlewares.AuditBefore(c, gin.H{"folder": folder, "descendants": descendants})
+
+	c.JSON(204, nil)
+}
+
+// MoveFile moves a file or folder into another folder, an empty parentId moves it to the root
+func MoveFile(c *gin.Context) {
+	body := FolderBody{}
+	if err := c.BindJSON(&body); err != nil {
+		return
+	}
+	userId := getUserIdFromToken(c)
+	file, err := db.FindFileByGuidAndUserId(invoker.DB, userId, c.Param("fileGuid"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if !canManageFile(c, file) || !checkParentFolder(c, userId, body.ParentId, "manageable") {
+		return
+	}
+
+	err = db.MoveFile(invoker.DB, file.Guid, body.ParentId)
+	if errors.Is(err, db.ErrFolderCycle) || errors.Is(err, db.ErrNotFolder) {
+		c.JSON(400, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	recordFileActivity(file.Guid, userId, db.FileActivityMove, db.ActivityDetail{"fromFolderId": file.ParentGuid, "toFolderId": body.ParentId})
+	middlewares.AuditBefore(c, gin.H{"parentId": file.ParentGuid})
+	middlewares.AuditAfter(c, gin.H{"parentId": body.ParentId})
+
+	c.JSON(204, nil)
+}
+
+// GetFilePath returns the breadcrumb of a file or folder, from the top folder down to the file itself
+func GetFilePath(c *gin.Context) {
+	file, ok := findFileWithPermission(c, "readable")
+	if !ok {
+		return
+	}
+	path, err := db.FindFolderPath(invoker.DB, file.Guid)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, path)
+}
+
+// checkParentFolder verifies that the folder exists, is a folder and that the user has the permission on it,
+// manageable is also granted to the creator. An empty parentGuid stands for the root; on failure the response
+// has been written
+func checkParentFolder(c *gin.Context, userId int64, parentGuid string, permission string) bool {
+	if parentGuid == "" {
+		return true
+	}
+	parent, err := db.FindFileByGuidAndUserId(invoker.DB, userId, parentGuid)
+	if err != nil {
+		handleDBError(c, err)
+		return false
+	}
+	if permission == "manageable" {
+		if !canManageFile(c, parent) {
+			return false
+		}
+	} else if !parent.Permissions[permission] {
+		c.JSON(403, gin.H{"message": "requires " + permission + " permission"})
+		return false
+	}
+	if !parent.IsFolder {
+		c.JSON(400, gin.H{"message": db.ErrNotFolder.Error()})
+		return false
+	}
+	return true
+}
+
+// canManageFile checks that the current user created the file or has the manageable permission on it
+// On failure the response has been written
+func canManageFile(c *gin.Context, file *db.File) bool {
+	if file.CreatorId != getUserIdFromToken(c) && !file.Permissions["manageable"] {
+		c.JSON(403, gin.H{"message": "requires manageable permission"})
+		return false
+	}
+	return true
+}
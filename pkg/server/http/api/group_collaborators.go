@@ -0,0 +1,170 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"sdk-demo-go/pkg/access"
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/server/http/middlewares"
+)
+
+// GroupCollaborator is a team or department a file is shared with
+type GroupCollaborator struct {
+	// Type is the kind of group (team/department)
+	Type string `json:"type"`
+	// Id is the team or department ID
+	Id int64 `json:"id"`
+	// Name is the team or department name, empty in requests
+	Name string `json:"name,omitempty"`
+	// Role is the role template matching the permissions, or the role to grant in requests
+	Role string `json:"role,omitempty"`
+	// Permissions are the flags granted to every member of the group, ignored in requests giving a role
+	Permissions map[string]bool `json:"permissions"`
+}
+
+// GetGroupCollaborators lists the teams and departments a file is shared with, requires the readable permission
+func GetGroupCollaborators(c *gin.Context) {
+	file, ok := findFileWithPermission(c, "readable")
+	if !ok {
+		return
+	}
+	gps, err := db.FindFileGroupPermissionsByFileId(invoker.DB, file.ID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	roles := rolePermissions(appIdFromContext(c))
+	res := make([]GroupCollaborator, 0, len(gps))
+	for _, gp := range gps {
+		name, err := groupName(gp.GroupType, gp.GroupId)
+		if err != nil {
+			handleDBError(c, err)
+			return
+		}
+		res = append(res, GroupCollaborator{
+			Type:        gp.GroupType,
+			Id:          gp.GroupId,
+			Name:        name,
+			Role:        access.MatchRole(roles, gp.Permissions),
+			Permissions: gp.Permissions,
+		})
+	}
+	c.JSON(200, res)
+}
+
+// UpdateGroupCollaborators shares a file with teams and departments, a grant whose flags are all
+// false removes the group. Members are resolved when permissions are checked, so users joining
+// the group later get access too
+func UpdateGroupCollaborators(c *gin.Context) {
+	myId := getUserIdFromToken(c)
+	file, err := db.FindFileByGuidAndUserId(invoker.DB, myId, c.Param("fileGuid"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	var body []GroupCollaborator
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+	if !file.Permissions["manageable"] {
+		c.JSON(403, gin.H{"message": "requires manageable permission"})
+		return
+	}
+
+	gps, err := db.FindFileGroupPermissionsByFileId(invoker.DB, file.ID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	before := make(map[string]db.Permissions, len(gps))
+	after := make(map[string]db.Permissions, len(gps))
+	for i := range gps {
+		before[gps[i].Group()] = gps[i].Permissions
+		after[gps[i].Group()] = gps[i].Permissions
+	}
+
+	roles := rolePermissions(appIdFromContext(c))
+	var rows []db.FileGroupPermissions
+	changes := make(map[string]interface{}, len(body))
+	for _, g := range body {
+		if g.Type != db.GroupTeam && g.Type != db.GroupDepartment {
+			c.JSON(400, gin.H{"message": "type must be team or department"})
+			return
+		}
+		if _, err = groupName(g.Type, g.Id); err != nil {
+			handleDBError(c, err)
+			return
+		}
+
+		if g.Role != "" {
+			rp, ok := roles[g.Role]
+			if !ok {
+				c.JSON(400, gin.H{"message": "unknown role " + g.Role})
+				return
+			}
+			g.Permissions = make(map[string]bool, len(rp))
+			for k, v := range rp {
+				g.Permissions[k] = v
+			}
+		}
+		checkPermission(g.Permissions)
+		p := map[string]bool{}
+		mergePermission(p, g.Permissions)
+		row := db.FileGroupPermissions{
+			FileId:      file.ID,
+			GroupType:   g.Type,
+			GroupId:     g.Id,
+			Permissions: p,
+		}
+		changes[row.Group()] = g.Permissions
+
+		granted := false
+		for _, v := range p {
+			granted = granted || v
+		}
+		if !granted {
+			if err = db.RemoveGroupPermissions(invoker.DB, file.ID, g.Type, g.Id); err != nil {
+				handleDBError(c, err)
+				return
+			}
+			delete(after, row.Group())
+			continue
+		}
+		rows = append(rows, row)
+		after[row.Group()] = p
+	}
+
+	if len(rows) > 0 {
+		if err = db.BatchSaveGroupPermissions(invoker.DB, rows); err != nil {
+			handleDBError(c, err)
+			return
+		}
+	}
+	recordFileActivity(file.Guid, myId, db.FileActivityPermission, db.ActivityDetail{"groups": changes})
+	middlewares.AuditBefore(c, before)
+	middlewares.AuditAfter(c, after)
+
+	c.JSON(204, nil)
+}
+
+// groupName returns the name of a team or department, gorm.ErrRecordNotFound when it does not exist
+func groupName(groupType string, groupId int64) (string, error) {
+	if groupType == db.GroupTeam {
+		team, err := db.FindTeamById(invoker.DB, groupId)
+		if err != nil {
+			return "", err
+		}
+		return team.Name, nil
+	}
+	dept, err := db.FindDepartmentById(invoker.DB, groupId)
+	if err != nil {
+		return "", err
+	}
+	return dept.Name, nil
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+)
+
+// GetJob returns the status of a background job started by the current user
+func GetJob(c *gin.Context) {
+	job, err := db.FindJobByGuid(invoker.DB, getUserIdFromToken(c), c.Param("jobId"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, job)
+}
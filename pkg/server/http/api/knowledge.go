@@ -14,6 +14,7 @@ import (
 	sdkapi "github.com/shimo-open/sdk-kit-go/api"
 
 	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/jobs"
 	"sdk-demo-go/pkg/models/db"
 	"sdk-demo-go/pkg/utils"
 )
@@ -498,8 +499,22 @@ func ImportFileToKnowledgeBaseV2(c *gin.Context) {
 		return
 	}
 
-	// Return the task ID so the front end can poll progress
+	// The job adds the file to the local knowledge base once the import completes
+	job := db.Job{
+		UserId:   userId,
+		Type:     jobs.TypeKnowledgeBaseImport,
+		FileGuid: fileGuid,
+		TaskId:   res.TaskID,
+		Payload:  db.JobData{"knowledgeBaseGuid": params.KnowledgeBaseGuid},
+	}
+	if err = jobs.Enqueue(&job); err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	// Return the job ID so the front end can poll /api/jobs/:jobId
 	c.JSON(200, gin.H{
+		"jobId":             job.Guid,
 		"taskId":            res.TaskID,
 		"fileGuid":          fileGuid,
 		"knowledgeBaseGuid": params.KnowledgeBaseGuid,
@@ -534,23 +549,6 @@ func ImportFileToKnowledgeBaseV2Progress(c *gin.Context) {
 		return
 	}
 
-	// If the task completes, add the file to the local knowledge base
-	if res.Status == "completed" && res.Progress == 100 {
-		// Fetch the file information as needed and persist it locally
-		// The v2 API response might lack file details, so other approaches may be required
-		// For now, return the progress; adjust the save logic based on the actual API payload
-		// Insert a row into the local knowledge base
-		kb := &db.KnowledgeBase{
-			Guid:     req.KnowledgeBaseGuid,
-			FileGuid: req.FileGuid,
-			CreateBy: userId,
-		}
-		if dbErr := db.CreateKnowledgeBase(invoker.DB, kb); dbErr != nil {
-			c.JSON(500, gin.H{"message": "Failed to save to database", "error": dbErr.Error()})
-			return
-		}
-	}
-
 	c.JSON(200, gin.H{
 		"taskId":   res.TaskId,
 		"status":   res.Status,
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+)
+
+// NotificationInfo is a notification with the file and actor it refers to
+type NotificationInfo struct {
+	db.Notification
+	// File is the file the event happened on, nil when it no longer exists
+	File *db.File `json:"file"`
+	// Actor is the user who triggered the event, nil when unknown
+	Actor *UserSummary `json:"actor"`
+}
+
+// ListNotifications returns the current user's notifications, newest first
+// Pass unread=true to only list unread notifications
+func ListNotifications(c *gin.Context) {
+	userId := getUserIdFromToken(c)
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page <= 0 {
+		page = 1
+	}
+	size, _ := strconv.Atoi(c.Query("size"))
+	if size <= 0 {
+		size = 50
+	}
+	size = min(size, 100)
+	unreadOnly := c.Query("unread") == "true"
+
+	notifications, err := db.FindNotifications(invoker.DB, userId, unreadOnly, page, size)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	count, err := db.CountNotifications(invoker.DB, userId, unreadOnly)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	fileIds := make([]string, 0, len(notifications))
+	actorIds := make([]int64, 0, len(notifications))
+	for _, n := range notifications {
+		fileIds = append(fileIds, n.FileId)
+		if id, err := strconv.ParseInt(n.ActorId, 10, 64); err == nil {
+			actorIds = append(actorIds, id)
+		}
+	}
+	files, err := db.FindFilesByGuids(invoker.DB, fileIds)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	users, err := db.FindUsersByIds(invoker.DB, actorIds)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	fileMap := make(map[string]*db.File, len(files))
+	for i := range files {
+		fileMap[files[i].Guid] = &files[i]
+	}
+	userMap := make(map[string]*UserSummary, len(users))
+	for i := range users {
+		userMap[strconv.FormatInt(users[i].ID, 10)] = newUserSummary(&users[i])
+	}
+
+	list := make([]NotificationInfo, len(notifications))
+	for i, n := range notifications {
+		list[i] = NotificationInfo{
+			Notification: n,
+			File:         fileMap[n.FileId],
+			Actor:        userMap[n.ActorId],
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"list":  list,
+		"count": count,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// CountUnreadNotifications returns the number of unread notifications of the current user
+func CountUnreadNotifications(c *gin.Context) {
+	count, err := db.CountNotifications(invoker.DB, getUserIdFromToken(c), true)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"count": count})
+}
+
+// ReadNotification marks one notification of the current user as read
+func ReadNotification(c *gin.Context) {
+	userId := getUserIdFromToken(c)
+	id, err := strconv.ParseInt(c.Param("notificationId"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"message": "invalid notification id"})
+		return
+	}
+
+	_, err = db.FindNotification(invoker.DB, userId, id)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	err = db.MarkNotificationRead(invoker.DB, userId, id, time.Now().Unix())
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(204, nil)
+}
+
+// ReadAllNotifications marks every unread notification of the current user as read
+func ReadAllNotifications(c *gin.Context) {
+	count, err := db.MarkAllNotificationsRead(invoker.DB, getUserIdFromToken(c), time.Now().Unix())
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"count": count})
+}
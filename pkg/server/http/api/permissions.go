@@ -0,0 +1,49 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+)
+
+// ExplainFilePermissions returns the effective permissions of a user (the caller when userId is
+// omitted) on a file, and for every flag the layer that decided it. Explaining the permissions of
+// another user requires the manageable permission
+func ExplainFilePermissions(c *gin.Context) {
+	callerId := getUserIdFromToken(c)
+	userId := callerId
+	if v := c.Query("userId"); v != "" {
+		var err error
+		if userId, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.JSON(400, gin.H{"message": "invalid userId"})
+			return
+		}
+	}
+
+	file, err := db.FindFileByGuidAndUserId(invoker.DB, callerId, c.Param("fileGuid"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if userId != callerId && !file.Permissions["manageable"] {
+		c.JSON(403, gin.H{"message": "requires manageable permission"})
+		return
+	}
+
+	files := []db.File{*file}
+	results, err := db.ResolveFilePermissions(invoker.DB, userId, files)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{
+		"fileId":      file.Guid,
+		"userId":      userId,
+		"role":        files[0].Role,
+		"permissions": results[0].Permissions,
+		"explain":     results[0].Explain,
+	})
+}
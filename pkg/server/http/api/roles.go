@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotomicro/ego/core/econf"
+
+	"sdk-demo-go/pkg/access"
+	"sdk-demo-go/pkg/models/db"
+)
+
+// Role is a role template offered by the role picker
+type Role struct {
+	// Name is the role name, e.g. viewer or editor
+	Name string `json:"name"`
+	// Permissions are the flags stored for a collaborator given the role
+	Permissions map[string]bool `json:"permissions"`
+}
+
+// PermissionGrant is the new permissions of a collaborator, either a role name ("editor"),
+// a role object ({"role": "editor"}) or the raw permission flags ({"editable": true})
+type PermissionGrant struct {
+	// Role is the role template name, empty when raw flags are given
+	Role string
+	// Permissions are the raw permission flags
+	Permissions map[string]bool
+}
+
+func (g *PermissionGrant) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &g.Role); err == nil {
+		return nil
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	g.Permissions = make(map[string]bool, len(v))
+	for k, x := range v {
+		switch x := x.(type) {
+		case bool:
+			g.Permissions[k] = x
+		case string:
+			if k == "role" {
+				g.Role = x
+			}
+		}
+	}
+	return nil
+}
+
+// Flags returns the raw flags of the grant, expanding its role with the templates of the app,
+// false when the role does not exist
+func (g *PermissionGrant) Flags(roles map[string]map[string]bool) (map[string]bool, bool) {
+	if g.Role == "" {
+		return g.Permissions, true
+	}
+	p, ok := roles[g.Role]
+	if !ok {
+		return nil, false
+	}
+	flags := make(map[string]bool, len(p))
+	for k, v := range p {
+		flags[k] = v
+	}
+	return flags, true
+}
+
+// GetRoles lists the role templates of the app of the current user
+func GetRoles(c *gin.Context) {
+	roles := rolePermissions(appIdFromContext(c))
+	res := make([]Role, 0, len(roles))
+	for _, name := range access.RoleNames(roles) {
+		res = append(res, Role{Name: name, Permissions: roles[name]})
+	}
+	c.JSON(200, res)
+}
+
+// rolePermissions expands the role templates of an app to the flags stored for a collaborator,
+// the built-in templates are overridden by permissions.roles and then by permissions.appRoles.<appId>
+func rolePermissions(appId string) map[string]map[string]bool {
+	templates := access.DefaultRoles()
+	for name := range econf.GetStringMap("permissions.roles") {
+		templates[name] = econf.GetStringSlice("permissions.roles." + name)
+	}
+	if appId != "" {
+		for name := range econf.GetStringMap("permissions.appRoles." + appId) {
+			templates[name] = econf.GetStringSlice("permissions.appRoles." + appId + "." + name)
+		}
+	}
+
+	roles := make(map[string]map[string]bool, len(templates))
+	for name, flags := range templates {
+		src := access.RoleFlags(flags)
+		checkPermission(src)
+		p := map[string]bool{}
+		mergePermission(p, src)
+		roles[name] = p
+	}
+	return roles
+}
+
+// appIdFromContext returns the app of the request, the app client is stored by value in single client mode
+// and by pointer in multiple client mode
+func appIdFromContext(c *gin.Context) string {
+	client, _ := c.Get("appClient")
+	switch ac := client.(type) {
+	case db.AppClient:
+		return ac.AppID
+	case *db.AppClient:
+		if ac != nil {
+			return ac.AppID
+		}
+	}
+	return ""
+}
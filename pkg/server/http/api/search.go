@@ -0,0 +1,83 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+)
+
+const (
+	// searchBatch is how many hits have their permissions resolved at a time
+	searchBatch = 100
+	// snippetWidth is the length of the snippets in runes
+	snippetWidth = 120
+)
+
+// SearchResult is a file matching a full-text search
+type SearchResult struct {
+	// File is the matching file with the permissions of the caller
+	File db.File `json:"file"`
+	// Score is the relevance of the file, higher is better
+	Score float64 `json:"score"`
+	// Snippet is an HTML excerpt of the plain text with the matched terms wrapped in <em></em>
+	Snippet string `json:"snippet"`
+}
+
+// Search searches the plain text of the collaborative files, it returns the best limit (default 20, at most 100)
+// files the caller can read, best first
+func Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(400, gin.H{"message": "missing q"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+	limit = min(limit, 100)
+
+	userId := getUserIdFromToken(c)
+	hits := invoker.Services.Search.Search(q)
+	res := make([]SearchResult, 0, min(limit, len(hits)))
+	for start := 0; start < len(hits) && len(res) < limit; start += searchBatch {
+		batch := hits[start:min(start+searchBatch, len(hits))]
+		guids := make([]string, len(batch))
+		for i, h := range batch {
+			guids[i] = h.Id
+		}
+		files, err := db.FindFilesByGuids(invoker.DB, guids)
+		if err != nil {
+			handleDBError(c, err)
+			return
+		}
+		if _, err = db.ResolveFilePermissions(invoker.DB, userId, files); err != nil {
+			handleDBError(c, err)
+			return
+		}
+
+		byGuid := make(map[string]db.File, len(files))
+		for _, f := range files {
+			byGuid[f.Guid] = f
+		}
+		for _, h := range batch {
+			f, ok := byGuid[h.Id]
+			if !ok || !f.Permissions["readable"] {
+				continue
+			}
+			res = append(res, SearchResult{
+				File:    f,
+				Score:   h.Score,
+				Snippet: invoker.Services.Search.Snippet(h.Id, q, snippetWidth),
+			})
+			if len(res) == limit {
+				break
+			}
+		}
+	}
+	c.JSON(200, res)
+}
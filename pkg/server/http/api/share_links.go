@@ -0,0 +1,211 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotomicro/ego/core/econf"
+	sdkapi "github.com/shimo-open/sdk-kit-go/api"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/server/http/middlewares"
+	"sdk-demo-go/pkg/utils"
+)
+
+// defaultShareTokenTTL is how long the token issued for a share link is valid when shareLinks.tokenTTL is not set
+const defaultShareTokenTTL = 2 * time.Hour
+
+// ShareLinkReq is the body of CreateShareLink
+type ShareLinkReq struct {
+	// Permissions are the flags granted by the link, readable only when empty; manageable is never granted
+	Permissions map[string]bool `json:"permissions"`
+	// ExpiresIn is how many seconds the link works (0 means forever)
+	ExpiresIn int64 `json:"expiresIn"`
+	// Password is the optional password asked when the link is opened
+	Password string `json:"password"`
+	// MaxUses is how many times the link can be opened (0 means unlimited)
+	MaxUses int `json:"maxUses"`
+}
+
+// ShareLinkInfo is a share link as returned to the file managers
+type ShareLinkInfo struct {
+	db.ShareLink
+	// HasPassword tells whether the link asks for a password
+	HasPassword bool `json:"hasPassword"`
+	// Active tells whether the link can still be opened
+	Active bool `json:"active"`
+}
+
+// CreateShareLink creates a public link to a file, requires the manageable permission
+func CreateShareLink(c *gin.Context) {
+	myId := getUserIdFromToken(c)
+	file, err := db.FindFileByGuidAndUserId(invoker.DB, myId, c.Param("fileGuid"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	req := ShareLinkReq{}
+	if err = c.BindJSON(&req); err != nil {
+		return
+	}
+	if !file.Permissions["manageable"] {
+		c.JSON(403, gin.H{"message": "requires manageable permission"})
+		return
+	}
+	if req.ExpiresIn < 0 || req.MaxUses < 0 {
+		c.JSON(400, gin.H{"message": "expiresIn and maxUses must not be negative"})
+		return
+	}
+
+	if len(req.Permissions) == 0 {
+		req.Permissions = map[string]bool{"readable": true}
+	}
+	delete(req.Permissions, "manageable")
+	checkPermission(req.Permissions)
+	p := map[string]bool{}
+	mergePermission(p, req.Permissions)
+
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	link := db.ShareLink{
+		Guid:        utils.GenFileGuid(),
+		Token:       hex.EncodeToString(buf),
+		FileGuid:    file.Guid,
+		CreatorId:   myId,
+		Permissions: p,
+		MaxUses:     req.MaxUses,
+	}
+	if req.ExpiresIn > 0 {
+		link.ExpiresAt = time.Now().Unix() + req.ExpiresIn
+	}
+	if req.Password != "" {
+		link.Password = utils.HashPassword(req.Password)
+	}
+	if err = db.CreateShareLink(invoker.DB, &link); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	middlewares.AuditTarget(c, "shareLinkId", link.Guid)
+
+	c.JSON(200, shareLinkInfo(&link))
+}
+
+// ListShareLinks lists the share links of a file, requires the manageable permission
+func ListShareLinks(c *gin.Context) {
+	file, err := db.FindFileByGuidAndUserId(invoker.DB, getUserIdFromToken(c), c.Param("fileGuid"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if !file.Permissions["manageable"] {
+		c.JSON(403, gin.H{"message": "requires manageable permission"})
+		return
+	}
+	links, err := db.FindShareLinksByFileGuid(invoker.DB, file.Guid)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	res := make([]ShareLinkInfo, len(links))
+	for i := range links {
+		res[i] = shareLinkInfo(&links[i])
+	}
+	c.JSON(200, res)
+}
+
+// RevokeShareLink revokes a share link, the tokens already issued for it stop working too
+func RevokeShareLink(c *gin.Context) {
+	file, err := db.FindFileByGuidAndUserId(invoker.DB, getUserIdFromToken(c), c.Param("fileGuid"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if !file.Permissions["manageable"] {
+		c.JSON(403, gin.H{"message": "requires manageable permission"})
+		return
+	}
+	link, err := db.FindShareLinkByGuid(invoker.DB, file.Guid, c.Param("linkId"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if link.RevokedAt == 0 {
+		if err = db.RevokeShareLink(invoker.DB, link); err != nil {
+			handleDBError(c, err)
+			return
+		}
+	}
+	c.JSON(204, nil)
+}
+
+// ExchangeShareLink exchanges a share link (and its password) for an access token scoped to the link
+// A signed-in caller keeps its identity and gets the link flags on top of its own permissions,
+// anyone else gets a guest token that can only read the shared file or the files below the shared folder
+func ExchangeShareLink(c *gin.Context) {
+	// Never record the secret link token as the audit target
+	middlewares.AuditTarget(c, "shareLinkId", "")
+	body := struct {
+		Password string `json:"password"`
+	}{}
+	_ = c.ShouldBindJSON(&body)
+
+	link, err := db.FindShareLinkByToken(invoker.DB, c.Param("shareToken"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	middlewares.AuditTarget(c, "shareLinkId", link.Guid)
+	if !link.Active() {
+		c.JSON(http.StatusGone, gin.H{"message": db.ErrShareLinkInactive.Error()})
+		return
+	}
+	if link.Password != "" && !checkPassword(link.Password, body.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid password"})
+		return
+	}
+
+	userId := int64(sdkapi.Anonymous)
+	if token := middlewares.FindAccessToken(c); token != "" {
+		if claim, _ := middlewares.ParseUserToken(token); claim != nil && claim.ShareToken == "" && claim.UserId > 0 {
+			userId = claim.UserId
+		}
+	}
+
+	if err = db.UseShareLink(invoker.DB, link); err != nil {
+		if errors.Is(err, db.ErrShareLinkInactive) {
+			c.JSON(http.StatusGone, gin.H{"message": err.Error()})
+			return
+		}
+		handleDBError(c, err)
+		return
+	}
+
+	ttl := econf.GetDuration("shareLinks.tokenTTL")
+	if ttl <= 0 {
+		ttl = defaultShareTokenTTL
+	}
+	if link.ExpiresAt != 0 {
+		ttl = min(ttl, time.Until(time.Unix(link.ExpiresAt, 0)))
+	}
+	c.JSON(200, gin.H{
+		"token":       utils.SignShareJWT(userId, link.Token, ttl),
+		"fileId":      link.FileGuid,
+		"permissions": link.Permissions,
+		"expiresAt":   time.Now().Add(ttl).Unix(),
+		"guest":       userId < 0,
+	})
+}
+
+func shareLinkInfo(link *db.ShareLink) ShareLinkInfo {
+	return ShareLinkInfo{
+		ShareLink:   *link,
+		HasPassword: link.Password != "",
+		Active:      link.Active(),
+	}
+}
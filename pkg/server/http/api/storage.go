@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/services/localfs"
+	"sdk-demo-go/pkg/services/storage"
+)
+
+// DownloadStorageObject serves an object from the local storage backend through a signed URL
+func DownloadStorageObject(c *gin.Context) {
+	local, key, ok := verifyStorageRequest(c)
+	if !ok {
+		return
+	}
+
+	ServeStorageObject(c, local, key, c.Query("filename"), "")
+}
+
+// UploadStorageObject stores the request body into the local storage backend through a signed URL
+func UploadStorageObject(c *gin.Context) {
+	local, key, ok := verifyStorageRequest(c)
+	if !ok {
+		return
+	}
+
+	err := local.Upload(key, c.Request.Body)
+	if err != nil {
+		elog.Error("save object failed", l.S("key", key), l.E(err))
+		c.JSON(500, gin.H{"message": "save object failed"})
+		return
+	}
+
+	c.JSON(200, nil)
+}
+
+// verifyStorageRequest checks that local storage is enabled and the URL signature is valid
+func verifyStorageRequest(c *gin.Context) (*localfs.LocalService, string, bool) {
+	local, ok := invoker.Services.Storage.(*localfs.LocalService)
+	if !ok {
+		c.JSON(404, gin.H{"message": "local storage is not enabled"})
+		return nil, "", false
+	}
+
+	method := c.Request.Method
+	if method == http.MethodHead {
+		// A download URL is valid for probing the object as well
+		method = http.MethodGet
+	}
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	err := local.Verify(method, key, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(403, gin.H{"message": err.Error()})
+		return nil, "", false
+	}
+	return local, key, true
+}
+
+// ServeStorageObject streams an object out of storage without buffering it
+// Range, If-None-Match and If-Modified-Since requests are answered by http.ServeContent
+func ServeStorageObject(c *gin.Context, s storage.Storage, key, filename, contentType string) {
+	info, err := s.Stat(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(404, gin.H{"message": "object not found"})
+			return
+		}
+		elog.Error("stat object failed", l.S("key", key), l.E(err))
+		c.JSON(500, gin.H{"message": "storage get file error"})
+		return
+	}
+
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	if filename != "" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", url.QueryEscape(filename)))
+	}
+	if info.ETag != "" {
+		c.Header("ETag", info.ETag)
+	}
+
+	content := storage.NewObjectReader(s, key, info.Size)
+	defer content.Close()
+	http.ServeContent(c.Writer, c.Request, filename, info.ModTime, content)
+}
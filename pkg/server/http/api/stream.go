@@ -0,0 +1,47 @@
+package api
+
+import (
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"sdk-demo-go/pkg/stream"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment to keep proxies from closing it
+const streamHeartbeat = 25 * time.Second
+
+// Stream pushes the current user's job progress, API test progress and new events as Server-Sent Events
+// EventSource cannot set headers, so the token is usually passed as the accessToken query parameter
+func Stream(c *gin.Context) {
+	sub := stream.Subscribe(getUserIdFromToken(c))
+	defer sub.Close()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Disable response buffering in nginx
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(200)
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case msg, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			c.SSEvent(msg.Type, msg.Data)
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		}
+	})
+}
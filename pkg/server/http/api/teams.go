@@ -7,6 +7,7 @@ import (
 
 	"sdk-demo-go/pkg/invoker"
 	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/server/http/middlewares"
 )
 
 // GetTeams retrieves all teams in the system
@@ -89,6 +90,8 @@ func TransferCreator(c *gin.Context) {
 		handleDBError(c, err)
 		return
 	}
+	middlewares.AuditBefore(c, gin.H{"creatorId": oldCreatorId})
+	middlewares.AuditAfter(c, gin.H{"creatorId": body.NewCreatorId})
 
 	c.JSON(204, nil)
 }
@@ -281,6 +284,9 @@ func DeleteMemberFromDept(c *gin.Context) {
 
 func DeleteDept(c *gin.Context) {
 	deptId := getInt64FromParam(c, "deptId")
+	if dept, err := db.FindDepartmentById(invoker.DB, deptId); err == nil {
+		middlewares.AuditBefore(c, dept)
+	}
 
 	// TODO this ideally should be wrapped in a transaction
 	err := db.RemoveDepartmentWithMembers(invoker.DB, deptId)
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotomicro/ego/core/econf"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/server/http/middlewares"
+	"sdk-demo-go/pkg/trash"
+)
+
+// TrashItemInfo is an item of the recycle bin as returned to its users
+type TrashItemInfo struct {
+	db.TrashItem
+	// ExpiresAt is the Unix timestamp the item is purged at (0 means never)
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// GetTrash lists the recycle bin of the current user: the files it created or deleted, newest first
+func GetTrash(c *gin.Context) {
+	items, err := db.FindTrashItemsByUserId(invoker.DB, getUserIdFromToken(c))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	days := econf.GetInt("trash.retentionDays")
+	res := make([]TrashItemInfo, len(items))
+	for i := range items {
+		res[i] = TrashItemInfo{TrashItem: items[i]}
+		if days > 0 {
+			res[i].ExpiresAt = time.Unix(items[i].TrashedAt, 0).AddDate(0, 0, days).Unix()
+		}
+	}
+	c.JSON(200, res)
+}
+
+// RestoreTrashItem restores a file, or a folder with everything deleted with it, and their collaborators
+func RestoreTrashItem(c *gin.Context) {
+	userId := getUserIdFromToken(c)
+	item, err := db.FindTrashItemByFileGuid(invoker.DB, userId, c.Param("fileGuid"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if err = db.RestoreTrashItem(invoker.DB, item); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	recordFileActivity(item.FileGuid, userId, db.FileActivityRestore, db.ActivityDetail{"name": item.Name})
+	middlewares.AuditBefore(c, item)
+
+	c.JSON(204, nil)
+}
+
+// PurgeTrashItem permanently deletes a file, or a folder with everything deleted with it, and removes
+// their content from the SDK or the object storage
+func PurgeTrashItem(c *gin.Context) {
+	userId := getUserIdFromToken(c)
+	item, err := db.FindTrashItemByFileGuid(invoker.DB, userId, c.Param("fileGuid"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	files, err := trash.Purge(c.Request.Context(), userId, item)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	recordFileActivity(item.FileGuid, userId, db.FileActivityPurge, db.ActivityDetail{"name": item.Name})
+	middlewares.AuditBefore(c, gin.H{"item": item, "files": files})
+
+	c.JSON(204, nil)
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/services/storage"
+	"sdk-demo-go/pkg/utils"
+)
+
+const (
+	// maxUploadParts is the largest part number accepted, matching the S3 limit
+	maxUploadParts = 10000
+	// minUploadPartSize is the smallest size of every part but the last, matching the S3 limit
+	minUploadPartSize = 5 << 20
+	// defaultUploadPartSize is the part size suggested to clients when uploads.partSize is not set
+	defaultUploadPartSize = 8 << 20
+	// defaultUploadSessionTTL is how long an idle session is kept when uploads.sessionTTL is not set
+	defaultUploadSessionTTL = 24 * time.Hour
+)
+
+// UploadSessionInfo is the upload session returned to clients, with the parts stored so far
+type UploadSessionInfo struct {
+	db.UploadSession
+	// PartSize is the suggested size of every part but the last
+	PartSize int64 `json:"partSize"`
+	// UploadedBytes is the total size of the stored parts
+	UploadedBytes int64 `json:"uploadedBytes"`
+	// Parts are the stored parts ordered by part number
+	Parts []db.UploadPart `json:"parts"`
+}
+
+// CreateUploadSession starts a resumable upload
+func CreateUploadSession(c *gin.Context) {
+	body := struct {
+		FileName string `json:"fileName"`
+		Size     int64  `json:"size"`
+	}{}
+	err := c.BindJSON(&body)
+	if err != nil {
+		return
+	}
+	if body.FileName == "" {
+		c.JSON(400, gin.H{"message": "missing file name"})
+		return
+	}
+	if body.Size < 0 || body.Size > uploadPartSize()*maxUploadParts {
+		c.JSON(400, gin.H{"message": "invalid file size"})
+		return
+	}
+
+	fileGuid := utils.GenFileGuid()
+	uploadId, err := invoker.Services.Storage.CreateMultipart(fileGuid)
+	if err != nil {
+		elog.Error("create multipart upload failed", l.E(err))
+		c.JSON(500, gin.H{"message": "create upload failed"})
+		return
+	}
+
+	session := db.UploadSession{
+		Guid:      utils.GenFileGuid(),
+		UserId:    getUserIdFromToken(c),
+		FileGuid:  fileGuid,
+		FileName:  body.FileName,
+		Size:      body.Size,
+		UploadId:  uploadId,
+		Status:    db.UploadSessionUploading,
+		ExpiresAt: uploadSessionExpiresAt(),
+	}
+	err = db.CreateUploadSession(invoker.DB, &session)
+	if err != nil {
+		_ = invoker.Services.Storage.AbortMultipart(fileGuid, uploadId)
+		handleDBError(c, err)
+		return
+	}
+
+	c.JSON(200, UploadSessionInfo{
+		UploadSession: session,
+		PartSize:      uploadPartSize(),
+		Parts:         []db.UploadPart{},
+	})
+}
+
+// GetUploadSession returns the session with its stored parts so a client can resume
+func GetUploadSession(c *gin.Context) {
+	session, err := db.FindUploadSession(invoker.DB, getUserIdFromToken(c), c.Param("uploadId"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	parts, err := db.FindUploadParts(invoker.DB, session.ID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	info := UploadSessionInfo{
+		UploadSession: *session,
+		PartSize:      uploadPartSize(),
+		Parts:         parts,
+	}
+	for _, part := range parts {
+		info.UploadedBytes += part.Size
+	}
+	c.JSON(200, info)
+}
+
+// UploadSessionPart streams one numbered part from the request body into storage
+// Uploading a part again replaces it, so failed parts can simply be retried
+func UploadSessionPart(c *gin.Context) {
+	session, ok := findActiveUploadSession(c)
+	if !ok {
+		return
+	}
+
+	partNumber, err := strconv.ParseInt(c.Param("partNumber"), 10, 64)
+	if err != nil || partNumber < 1 || partNumber > maxUploadParts {
+		c.JSON(400, gin.H{"message": "invalid part number"})
+		return
+	}
+	size := c.Request.ContentLength
+	if size <= 0 {
+		c.JSON(411, gin.H{"message": "missing content length"})
+		return
+	}
+
+	var content io.Reader = c.Request.Body
+	if partNumber == 1 {
+		// The first part carries the leading bytes of the file
+		mimeType, body, err := SniffMimeType(session.FileName, c.Request.Body)
+		if err != nil {
+			c.JSON(400, gin.H{"message": "part read failed"})
+			return
+		}
+		session.FileType = mimeType
+		content = body
+	}
+
+	etag, err := invoker.Services.Storage.UploadPart(session.FileGuid, session.UploadId, partNumber, content, size)
+	if err != nil {
+		if errors.Is(err, storage.ErrPartSizeMismatch) {
+			c.JSON(400, gin.H{"message": "incomplete part, please retry"})
+			return
+		}
+		elog.Error("upload part failed", l.S("uploadId", session.Guid), l.I64("partNumber", partNumber), l.E(err))
+		c.JSON(500, gin.H{"message": "upload part failed"})
+		return
+	}
+
+	part := db.UploadPart{
+		SessionId:  session.ID,
+		PartNumber: partNumber,
+		Size:       size,
+		ETag:       etag,
+	}
+	err = db.SaveUploadPart(invoker.DB, &part)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	// Every stored part keeps the session alive
+	values := map[string]interface{}{"expires_at": uploadSessionExpiresAt()}
+	if partNumber == 1 {
+		values["file_type"] = session.FileType
+	}
+	err = db.UpdateUploadSession(invoker.DB, session.ID, values)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	c.JSON(200, part)
+}
+
+// CompleteUploadSession assembles the stored parts into a new file
+func CompleteUploadSession(c *gin.Context) {
+	session, ok := findActiveUploadSession(c)
+	if !ok {
+		return
+	}
+
+	parts, err := db.FindUploadParts(invoker.DB, session.ID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if len(parts) == 0 {
+		c.JSON(400, gin.H{"message": "no parts uploaded"})
+		return
+	}
+
+	var total int64
+	storageParts := make([]storage.Part, len(parts))
+	for i, part := range parts {
+		if part.PartNumber != int64(i+1) {
+			c.JSON(400, gin.H{"message": "missing part " + strconv.Itoa(i+1)})
+			return
+		}
+		if i < len(parts)-1 && part.Size < minUploadPartSize {
+			c.JSON(400, gin.H{"message": "part " + strconv.Itoa(i+1) + " is smaller than 5 MB"})
+			return
+		}
+		total += part.Size
+		storageParts[i] = storage.Part{Number: part.PartNumber, ETag: part.ETag}
+	}
+	if session.Size > 0 && total != session.Size {
+		c.JSON(400, gin.H{"message": "uploaded size does not match the announced size"})
+		return
+	}
+
+	err = invoker.Services.Storage.CompleteMultipart(session.FileGuid, session.UploadId, storageParts)
+	if err != nil {
+		elog.Error("complete multipart upload failed", l.S("uploadId", session.Guid), l.E(err))
+		c.JSON(500, gin.H{"message": "complete upload failed"})
+		return
+	}
+
+	finished, err := db.FinishUploadSession(invoker.DB, session.ID, db.UploadSessionCompleted)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if !finished {
+		// The session was aborted or expired meanwhile, nothing will reference the assembled object.
+		// When another request completed it, that request owns the object
+		current, err := db.FindUploadSession(invoker.DB, session.UserId, session.Guid)
+		if err == nil && current.Status != db.UploadSessionCompleted {
+			if rmErr := invoker.Services.Storage.Remove(session.FileGuid); rmErr != nil {
+				elog.Warn("remove uploaded object failed", l.E(rmErr))
+			}
+		}
+		c.JSON(409, gin.H{"message": "upload is already finished"})
+		return
+	}
+
+	userId := getUserIdFromToken(c)
+	f := db.File{
+		Guid:      session.FileGuid,
+		Name:      session.FileName,
+		Type:      session.FileType,
+		CreatorId: userId,
+	}
+	err, _ = db.CreateFile(invoker.DB, &f, userId)
+	if err != nil {
+		if rmErr := invoker.Services.Storage.Remove(session.FileGuid); rmErr != nil {
+			elog.Warn("remove uploaded object failed", l.E(rmErr))
+		}
+		handleDBError(c, err)
+		return
+	}
+	if err = db.RemoveUploadParts(invoker.DB, session.ID); err != nil {
+		elog.Warn("remove upload parts failed", l.E(err))
+	}
+
+	c.JSON(200, f)
+}
+
+// AbortUploadSession cancels an upload and discards its parts
+func AbortUploadSession(c *gin.Context) {
+	session, ok := findActiveUploadSession(c)
+	if !ok {
+		return
+	}
+
+	err := invoker.Services.Storage.AbortMultipart(session.FileGuid, session.UploadId)
+	if err != nil {
+		elog.Error("abort multipart upload failed", l.S("uploadId", session.Guid), l.E(err))
+		c.JSON(500, gin.H{"message": "abort upload failed"})
+		return
+	}
+
+	_, err = db.FinishUploadSession(invoker.DB, session.ID, db.UploadSessionAborted)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if err = db.RemoveUploadParts(invoker.DB, session.ID); err != nil {
+		elog.Warn("remove upload parts failed", l.E(err))
+	}
+
+	c.JSON(204, nil)
+}
+
+// findActiveUploadSession loads the current user's session and checks it still accepts parts
+func findActiveUploadSession(c *gin.Context) (*db.UploadSession, bool) {
+	session, err := db.FindUploadSession(invoker.DB, getUserIdFromToken(c), c.Param("uploadId"))
+	if err != nil {
+		handleDBError(c, err)
+		return nil, false
+	}
+	if session.Status != db.UploadSessionUploading {
+		c.JSON(409, gin.H{"message": "upload is " + session.Status})
+		return nil, false
+	}
+	return session, true
+}
+
+func uploadPartSize() int64 {
+	if size := econf.GetInt64("uploads.partSize"); size > 0 {
+		return size
+	}
+	return defaultUploadPartSize
+}
+
+func uploadSessionExpiresAt() int64 {
+	ttl := econf.GetDuration("uploads.sessionTTL")
+	if ttl <= 0 {
+		ttl = defaultUploadSessionTTL
+	}
+	return time.Now().Add(ttl).Unix()
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+)
+
+// GetRecentFiles lists the files the current user opened, most recently opened first
+func GetRecentFiles(c *gin.Context) {
+	listUserFiles(c, db.FindRecentFiles)
+}
+
+// GetStarredFiles lists the files the current user starred, most recently starred first
+func GetStarredFiles(c *gin.Context) {
+	listUserFiles(c, db.FindStarredFiles)
+}
+
+// GetPinnedFiles lists the files the current user pinned, most recently pinned first
+func GetPinnedFiles(c *gin.Context) {
+	listUserFiles(c, db.FindPinnedFiles)
+}
+
+// StarFile stars a file for the current user
+func StarFile(c *gin.Context) {
+	setUserFileState(c, db.SetFileStarred, true)
+}
+
+// UnstarFile unstars a file for the current user
+func UnstarFile(c *gin.Context) {
+	setUserFileState(c, db.SetFileStarred, false)
+}
+
+// PinFile pins a file for the current user
+func PinFile(c *gin.Context) {
+	setUserFileState(c, db.SetFilePinned, true)
+}
+
+// UnpinFile unpins a file for the current user
+func UnpinFile(c *gin.Context) {
+	setUserFileState(c, db.SetFilePinned, false)
+}
+
+// listUserFiles lists the files found for the current user, limit defaults to and is at most 100
+func listUserFiles(c *gin.Context, find func(*gorm.DB, int64, int) ([]db.File, error)) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+	limit = min(limit, 100)
+	files, err := find(invoker.DB, getUserIdFromToken(c), limit)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, files)
+}
+
+func setUserFileState(c *gin.Context, set func(*gorm.DB, int64, int64, bool) error, on bool) {
+	userId := getUserIdFromToken(c)
+	file, err := db.FindFileByGuidAndUserId(invoker.DB, userId, c.Param("fileGuid"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if !file.Permissions["readable"] {
+		c.JSON(403, gin.H{"message": "requires readable permission"})
+		return
+	}
+	if err = set(invoker.DB, userId, file.ID, on); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(204, nil)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -304,6 +305,24 @@ func getDefaultAvatar() string {
 	}
 }
 
+// UserSummary is the public part of a user shown alongside other records, without its password or app
+type UserSummary struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar"`
+	Email  string `json:"email"`
+}
+
+// newUserSummary returns the public part of a user
+func newUserSummary(u *db.User) *UserSummary {
+	return &UserSummary{
+		Id:     strconv.FormatInt(u.ID, 10),
+		Name:   u.Name,
+		Avatar: u.Avatar,
+		Email:  u.Email,
+	}
+}
+
 type AnonymousUserInfo struct {
 	User     AnonymousUser `json:"user"`
 	Token    string        `json:"token"`
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/utils"
+	"sdk-demo-go/pkg/webhooks"
+)
+
+// maxWebhookSubscriptions is how many subscriptions a user may have, they are listed at once
+const maxWebhookSubscriptions = 50
+
+// WebhookReq is the body used to create or update a webhook subscription
+type WebhookReq struct {
+	// URL is the http(s) endpoint the events are posted to
+	URL string `json:"url"`
+	// EventTypes are the forwarded event types, empty forwards every type
+	EventTypes []string `json:"eventTypes"`
+	// Secret is the signing secret, generated on creation when empty
+	Secret string `json:"secret"`
+	// Active pauses or resumes forwarding, subscriptions are created active
+	Active *bool `json:"active"`
+}
+
+// CreateWebhook creates a webhook subscription, the response is the only one that contains the secret
+func CreateWebhook(c *gin.Context) {
+	req := WebhookReq{}
+	if err := c.BindJSON(&req); err != nil {
+		return
+	}
+	if err := webhooks.CheckURL(c.Request.Context(), req.URL); err != nil {
+		c.JSON(400, gin.H{"message": err.Error()})
+		return
+	}
+	count, err := db.CountWebhookSubscriptions(invoker.DB, getUserIdFromToken(c))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if count >= maxWebhookSubscriptions {
+		c.JSON(400, gin.H{"message": "too many webhook subscriptions"})
+		return
+	}
+	if req.Secret == "" {
+		buf := make([]byte, 32)
+		_, _ = rand.Read(buf)
+		req.Secret = hex.EncodeToString(buf)
+	}
+
+	s := db.WebhookSubscription{
+		Guid:       utils.GenFileGuid(),
+		UserId:     getUserIdFromToken(c),
+		URL:        req.URL,
+		EventTypes: joinEventTypes(req.EventTypes),
+		Secret:     req.Secret,
+		Active:     req.Active == nil || *req.Active,
+	}
+	if err := db.CreateWebhookSubscription(invoker.DB, &s); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, struct {
+		db.WebhookSubscription
+		Secret string `json:"secret"`
+	}{
+		WebhookSubscription: s,
+		Secret:              s.Secret,
+	})
+}
+
+// ListWebhooks returns the webhook subscriptions of the current user
+func ListWebhooks(c *gin.Context) {
+	subscriptions, err := db.FindWebhookSubscriptions(invoker.DB, getUserIdFromToken(c))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, subscriptions)
+}
+
+// GetWebhook returns a webhook subscription of the current user
+func GetWebhook(c *gin.Context) {
+	s, err := db.FindWebhookSubscription(invoker.DB, getUserIdFromToken(c), c.Param("webhookId"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, s)
+}
+
+// UpdateWebhook changes the url, event filter, secret or active flag of a subscription
+// Omitted fields are left unchanged
+func UpdateWebhook(c *gin.Context) {
+	s, err := db.FindWebhookSubscription(invoker.DB, getUserIdFromToken(c), c.Param("webhookId"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	req := WebhookReq{}
+	if err = c.BindJSON(&req); err != nil {
+		return
+	}
+
+	values := map[string]interface{}{}
+	if req.URL != "" {
+		if err := webhooks.CheckURL(c.Request.Context(), req.URL); err != nil {
+			c.JSON(400, gin.H{"message": err.Error()})
+			return
+		}
+		values["url"] = req.URL
+	}
+	if req.EventTypes != nil {
+		values["event_types"] = joinEventTypes(req.EventTypes)
+	}
+	if req.Secret != "" {
+		values["secret"] = req.Secret
+	}
+	if req.Active != nil {
+		values["active"] = *req.Active
+	}
+	if len(values) > 0 {
+		if err = db.UpdateWebhookSubscription(invoker.DB, s.ID, values); err != nil {
+			handleDBError(c, err)
+			return
+		}
+	}
+
+	s, err = db.FindWebhookSubscription(invoker.DB, s.UserId, s.Guid)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, s)
+}
+
+// DeleteWebhook removes a subscription, its undelivered events are dropped
+func DeleteWebhook(c *gin.Context) {
+	s, err := db.FindWebhookSubscription(invoker.DB, getUserIdFromToken(c), c.Param("webhookId"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if err = db.RemoveWebhookSubscription(invoker.DB, s.ID); err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(204, nil)
+}
+
+// ListWebhookDeliveries returns the deliveries of a subscription, newest first
+// Pass status=dead to list the dead-letter deliveries
+func ListWebhookDeliveries(c *gin.Context) {
+	s, err := db.FindWebhookSubscription(invoker.DB, getUserIdFromToken(c), c.Param("webhookId"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page <= 0 {
+		page = 1
+	}
+	size, _ := strconv.Atoi(c.Query("size"))
+	if size <= 0 {
+		size = 50
+	}
+	size = min(size, 100)
+	status := c.Query("status")
+
+	deliveries, err := db.FindWebhookDeliveries(invoker.DB, s.ID, status, page, size)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	count, err := db.CountWebhookDeliveries(invoker.DB, s.ID, status)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{
+		"list":  deliveries,
+		"count": count,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// RedeliverWebhookDelivery queues a dead or delivered event again
+func RedeliverWebhookDelivery(c *gin.Context) {
+	s, err := db.FindWebhookSubscription(invoker.DB, getUserIdFromToken(c), c.Param("webhookId"))
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"message": "invalid delivery id"})
+		return
+	}
+	d, err := db.FindWebhookDelivery(invoker.DB, s.ID, id)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
+	ok, err := db.RedeliverWebhookDelivery(invoker.DB, d.ID, time.Now().Unix())
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if !ok {
+		c.JSON(409, gin.H{"message": "delivery is " + d.Status})
+		return
+	}
+	c.JSON(202, gin.H{"message": "delivery queued"})
+}
+
+func joinEventTypes(types []string) string {
+	cleaned := make([]string, 0, len(types))
+	for _, t := range types {
+		if t = strings.TrimSpace(t); t != "" {
+			cleaned = append(cleaned, t)
+		}
+	}
+	return strings.Join(cleaned, ",")
+}
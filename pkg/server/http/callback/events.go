@@ -7,56 +7,14 @@ import (
 
 	sdkapi "github.com/shimo-open/sdk-kit-go/api"
 
+	"sdk-demo-go/pkg/events"
 	"sdk-demo-go/pkg/invoker"
 	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/stream"
 
 	"github.com/gin-gonic/gin"
 )
 
-type EventReq interface {
-	GetFileId() string
-	GetUserId() string
-}
-
-type Comment struct {
-	Guid             string   `json:"guid"`
-	Content          string   `json:"content"`
-	UserIds          []string `json:"userIds"`
-	SelectionGuid    string   `json:"selectionGuid"`
-	SelectionContent string   `json:"selectionContent"`
-}
-
-type Discussion struct {
-	Id       string `json:"id"`
-	Unixus   int64  `json:"unixus"`
-	Content  string `json:"content"`
-	Position string `json:"position"`
-}
-
-type Mention struct {
-	Guid             string   `json:"guid"`
-	Content          string   `json:"content"`
-	UserIds          []string `json:"userIds"`
-	SelectionGuid    string   `json:"selectionGuid"`
-	SelectionContent string   `json:"selectionContent"`
-}
-
-type EventBody struct {
-	Kind   string `json:"kind"`
-	Type   string `json:"type"`
-	Action string `json:"action"`
-	FileId string `json:"fileId"`
-	UserId string `json:"userId"`
-}
-
-func (e EventBody) GetFileId() string {
-	return e.FileId
-}
-
-func (e EventBody) GetUserId() string {
-	return e.UserId
-}
-
 func PushEvent(c *gin.Context) {
 	eventType := c.GetHeader(sdkapi.HeaderShimoSdkEvent)
 	rawBody, err := io.ReadAll(c.Request.Body)
@@ -65,8 +23,8 @@ func PushEvent(c *gin.Context) {
 		return
 	}
 
-	body := &EventBody{}
-	err = json.Unmarshal(rawBody, body)
+	// Reject payloads that do not match the event type, unknown types are passed through
+	payload, err := events.Decode(eventType, rawBody)
 	if err != nil {
 		c.JSON(400, gin.H{"message": err.Error()})
 		return
@@ -79,33 +37,47 @@ func PushEvent(c *gin.Context) {
 	}
 	header, _ := json.Marshal(&_rawHeader)
 
-	// Handle auto-mention events separately since they do not include userId
-	uid := body.UserId
-	if body.Type == "auto_mention" {
-		var raw map[string]any
-		if err := json.Unmarshal(rawBody, &raw); err == nil {
-			if m, ok := raw["autoMention"].(map[string]any); ok {
-				switch v := m["editorProviderId"].(type) {
-				case string:
-					uid = v
-				case float64:
-					uid = strconv.FormatInt(int64(v), 10)
-				}
-			}
-		}
-	}
-
-	err = db.SaveEvent(invoker.DB, &db.Event{
+	event := db.Event{
 		Type:    eventType,
-		FileId:  body.FileId,
-		UserId:  uid,
+		FileId:  string(payload.Common().FileId),
+		UserId:  payload.ActorId(),
+		Action:  payload.Common().Action,
 		RawData: string(rawBody),
 		Headers: string(header),
-	})
+	}
+	notifications, ok, err := db.IngestEvent(invoker.DB, &event)
 	if err != nil {
 		handleDBError(c, err)
 		return
 	}
+	// SDK retries of a stored delivery are acknowledged without being stored or published again
+	if !ok {
+		c.JSON(204, nil)
+		return
+	}
+	// The plain text is fetched later, once per file however many edits arrive meanwhile
+	if _, ok := payload.(*events.FileContent); ok {
+		invoker.Services.Search.Queue(event.FileId)
+	}
+	publishEvent(&event, payload.UserIds())
+	for _, n := range notifications {
+		stream.Publish(n.UserId, stream.TypeNotification, n)
+	}
 
 	c.JSON(204, nil)
 }
+
+// publishEvent pushes a stored event to the streams of every user it involves
+func publishEvent(e *db.Event, userIds []string) {
+	userIds = append(userIds, e.UserId)
+
+	seen := make(map[int64]bool, len(userIds))
+	for _, id := range userIds {
+		userId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || seen[userId] {
+			continue
+		}
+		seen[userId] = true
+		stream.Publish(userId, stream.TypeEvent, e)
+	}
+}
@@ -1,11 +1,9 @@
 package callback
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -15,25 +13,26 @@ import (
 	"github.com/gotomicro/cetus/l"
 	"github.com/gotomicro/ego/core/econf"
 	"github.com/gotomicro/ego/core/elog"
-	sdk "github.com/shimo-open/sdk-kit-go"
 	sdkapi "github.com/shimo-open/sdk-kit-go/api"
 
 	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/jobs"
 	"sdk-demo-go/pkg/models/db"
 	"sdk-demo-go/pkg/server/http/api"
 	"sdk-demo-go/pkg/utils"
 )
 
 type FileInfo struct {
-	Id          string          `json:"id"`
-	Name        string          `json:"name"`
-	Type        string          `json:"type"`
-	CreatorId   string          `json:"creatorId"`
-	Views       int             `json:"views"`
-	CreatedAt   string          `json:"createdAt"`
-	UpdatedAt   string          `json:"updatedAt"`
-	TeamGuid    string          `json:"teamGuid"`
-	Permissions map[string]bool `json:"permissions"`
+	Id          string            `json:"id"`
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	CreatorId   string            `json:"creatorId"`
+	Views       int               `json:"views"`
+	CreatedAt   string            `json:"createdAt"`
+	UpdatedAt   string            `json:"updatedAt"`
+	TeamGuid    string            `json:"teamGuid"`
+	Permissions map[string]bool   `json:"permissions"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
 }
 
 func GetFileInfo(c *gin.Context) {
@@ -43,11 +42,16 @@ func GetFileInfo(c *gin.Context) {
 	}
 	fileGuid := c.Param("fileGuid")
 
-	file, err := db.FindFileByGuidAndUserId(invoker.DB, userId, fileGuid)
+	file, err := db.FindFileByGuidWithShareLink(invoker.DB, userId, fileGuid, c.GetString("shareToken"))
 	if err != nil {
 		handleDBError(c, err)
 		return
 	}
+	if userId > 0 {
+		if err = db.RecordFileOpen(invoker.DB, userId, file.ID); err != nil {
+			elog.Warn("record file open failed", l.S("fileGuid", fileGuid), l.E(err))
+		}
+	}
 
 	if file.IsShimoFile == 1 {
 		sendShimoInfo(c, file)
@@ -88,18 +92,36 @@ func CreateFiles(c *gin.Context) {
 	if err != nil {
 		return
 	}
-	name := body.CreateLinkInfo.NewFileName
-	if name == "" {
-		name = body.CreateCopyInfo.NewFileName
+	var name, parentGuid string
+	if body.CreateLinkInfo != nil {
+		name, parentGuid = body.CreateLinkInfo.NewFileName, body.CreateLinkInfo.ParentFileID
+	}
+	if body.CreateCopyInfo != nil {
+		if name == "" {
+			name = body.CreateCopyInfo.NewFileName
+		}
+		if parentGuid == "" {
+			parentGuid = body.CreateCopyInfo.ParentFileID
+		}
 	}
 	if name == "" {
 		name = api.FormatCurrentTime() + " " + body.FileType
 	}
+	// Copies and links land in the requested folder when the user may add files to it, as for
+	// POST /api/folders: the manageable permission or being the creator. Other parents fall back to the root
+	if parentGuid != "" {
+		parent, err := db.FindFileByGuidAndUserId(invoker.DB, userId, parentGuid)
+		if err != nil || !parent.IsFolder || (parent.CreatorId != userId && !parent.Permissions["manageable"]) {
+			elog.Warn("ignore invalid parent folder", l.S("parentFileId", parentGuid), l.E(err))
+			parentGuid = ""
+		}
+	}
 	file := db.File{
 		Name:        name,
 		ShimoType:   body.FileType,
 		CreatorId:   userId,
 		IsShimoFile: api.IsShimoType(body.FileType),
+		ParentGuid:  parentGuid,
 	}
 	// Create the local metadata record
 	err, _ = db.CreateFile(invoker.DB, &file, userId)
@@ -142,6 +164,8 @@ func GetFilesByUser(c *gin.Context) {
 		handleDBError(c, err)
 		return
 	}
+	files = withoutFolders(files)
+	loadFileMetadata(files)
 
 	fileInfos := make([]FileInfo, len(files))
 	for i := range files {
@@ -170,15 +194,46 @@ func GetFileCollaborators(c *gin.Context) {
 		return
 	}
 
+	gps, err := db.FindFileGroupPermissionsByFileId(invoker.DB, file.ID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+
 	var userIds []int64
 	managerMap := make(map[int64]bool)
+	direct := make(map[int64]bool, len(fps))
 	for i := range fps {
 		userIds = append(userIds, fps[i].UserId)
+		direct[fps[i].UserId] = true
 		if fps[i].Permissions["manageable"] {
 			managerMap[fps[i].UserId] = true
 		}
 	}
 
+	// The members of the teams and departments the file is shared with are collaborators too,
+	// the grant of a user on the file wins over the grants of its groups
+	listed := make(map[int64]bool)
+	for i := range gps {
+		memberIds, err := db.FindGroupMemberIds(invoker.DB, gps[i].GroupType, gps[i].GroupId)
+		if err != nil {
+			handleDBError(c, err)
+			return
+		}
+		for _, id := range memberIds {
+			if direct[id] {
+				continue
+			}
+			if !listed[id] {
+				listed[id] = true
+				userIds = append(userIds, id)
+			}
+			if gps[i].Permissions["manageable"] {
+				managerMap[id] = true
+			}
+		}
+	}
+
 	users, err := db.FindUsersByIds(invoker.DB, userIds)
 
 	collInfos := make([]CollaboratorInfo, len(users))
@@ -223,11 +278,7 @@ func AdminGetFileInfo(c *gin.Context) {
 		return
 	}
 
-	if econf.GetBool("permissions.setNewFilePermission") {
-		file.Permissions = sdk.HandleFilePermission(true)
-	} else {
-		file.Permissions = sdk.HandleBasicFilePermission(true)
-	}
+	file.Permissions = db.AdminFilePermissions(file).Permissions
 
 	if file.IsShimoFile == 1 {
 		sendShimoInfo(c, file)
@@ -269,17 +320,7 @@ func DownloadFile(c *gin.Context) {
 		return
 	}
 
-	bytes, err := invoker.Services.AwosService.Get(fileGuid)
-	if err != nil {
-		c.JSON(500, gin.H{"message": "awos get file error"})
-		return
-	}
-	fileName := url.QueryEscape(file.Name)
-
-	c.Header("Content-Type", file.Type)
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
-	c.Header("Content-Length", strconv.Itoa(len(bytes)))
-	c.Data(200, file.Type, bytes)
+	api.ServeStorageObject(c, invoker.Services.Storage, fileGuid, file.Name, file.Type)
 }
 
 func getSDKClaims(c *gin.Context) *utils.SDKClaims {
@@ -320,7 +361,7 @@ func ImportFile(c *gin.Context) {
 	name := req.FileName
 	fileUrl := req.FileUrl
 	shimoType := req.Type
-	var content []byte
+	var content io.Reader
 
 	isShimoFile := 1
 	if req.Type == "file" {
@@ -347,8 +388,14 @@ func ImportFile(c *gin.Context) {
 			return
 		}
 		defer rs.Body.Close()
-		// Read the file content
-		content, e = io.ReadAll(rs.Body)
+		if rs.StatusCode != http.StatusOK {
+			c.AbortWithStatusJSON(500, gin.H{
+				"message": fmt.Sprintf("file download failed with status %d", rs.StatusCode),
+			})
+			return
+		}
+		// Use the same MIME detection logic as direct uploads, the body is streamed to storage afterwards
+		mimeType, body, e := api.SniffMimeType(name, rs.Body)
 		if e != nil {
 			c.AbortWithStatusJSON(500, gin.H{
 				"message": "file content read failed",
@@ -356,8 +403,7 @@ func ImportFile(c *gin.Context) {
 			})
 			return
 		}
-		// Use the same MIME detection logic as direct uploads
-		mimeType := api.DetectMimeType(name, content)
+		content = body
 		// Update the file type information in the database
 		file.Type = mimeType
 	}
@@ -370,24 +416,14 @@ func ImportFile(c *gin.Context) {
 	switch req.Type {
 	case "file":
 		// Cloud file
-		// Generate a temporary download link
-		uploadUrl, err := invoker.Services.AwosService.GetUploadURL(file.Guid, 1800)
+		// Stream the file into storage
+		err = invoker.Services.Storage.Upload(file.Guid, content)
 		if err != nil {
 			// Roll back the created file
 			rmErr := db.RemoveFileById(invoker.DB, file.ID)
 			if rmErr != nil {
 				elog.Warn("rollback file failed", l.E(rmErr))
 			}
-			c.AbortWithStatusJSON(500, gin.H{
-				"message": "GetUploadURL failed",
-				"error":   err,
-			})
-			return
-		}
-		// Use the download link to persist the file into storage
-		uploadReq, _ := http.NewRequest("PUT", uploadUrl, bytes.NewReader(content))
-		_, err = http.DefaultClient.Do(uploadReq)
-		if err != nil {
 			c.AbortWithStatusJSON(500, gin.H{
 				"message": "file save failed",
 				"error":   err,
@@ -433,75 +469,36 @@ func ImportFile(c *gin.Context) {
 			c.JSON(500, "taskId not found")
 			return
 		}
-		// Poll the import progress
-		var progressResp sdkapi.GetImportProgRes
-		progressParams := sdkapi.GetImportProgReq{
-			Metadata: auth,
+		// Poll the import in the background, the file is rolled back if it fails
+		job := db.Job{
+			UserId:   file.CreatorId,
+			Type:     jobs.TypeImport,
+			FileGuid: file.Guid,
 			TaskId:   taskId,
+			Payload: db.JobData{
+				"version":  econf.GetString("shimoSDK.importByUrlVersion"),
+				"rollback": true,
+			},
 		}
-		timeout := time.After(3 * time.Minute) // Wait at most 2 minutes
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-timeout:
-				rmErr := db.RemoveFileByGuid(invoker.DB, file.Guid)
-				if rmErr != nil {
-					elog.Warn("rollback file failed", l.E(rmErr))
-				}
-				c.JSON(504, "import progress timeout")
-				return
-			case <-ticker.C:
-				if econf.GetString("shimoSDK.importByUrlVersion") == "v2" {
-					progressResp, err = invoker.SdkMgr.GetImportV2Progress(c.Request.Context(), progressParams)
-				} else {
-					progressResp, err = invoker.SdkMgr.GetImportProgress(c.Request.Context(), progressParams)
-				}
-				if err != nil {
-					rmErr := db.RemoveFileByGuid(invoker.DB, file.Guid)
-					if rmErr != nil {
-						elog.Warn("rollback file failed", l.E(rmErr))
-					}
-					c.JSON(500, fmt.Errorf("get import progress failed: %w", err))
-					return
-				}
-				if progressResp.Data.Progress == 100 {
-					resp := struct {
-						db.File
-					}{
-						File: file,
-					}
-					c.JSON(200, resp)
-					return
-				}
-			}
+		if err = jobs.Enqueue(&job); err != nil {
+			handleDBError(c, err)
+			return
 		}
+		resp := struct {
+			db.File
+			TaskId string `json:"taskId"`
+			JobId  string `json:"jobId"`
+		}{
+			File:   file,
+			TaskId: taskId,
+			JobId:  job.Guid,
+		}
+		c.JSON(200, resp)
 	}
 }
 
 func sendShimoInfo(c *gin.Context, file *db.File) {
 	mode := getModeFromToken(c)
-	userId := getUserIdFromToken(c)
-
-	if file.ShimoType == "form" {
-		permissions := map[string]bool{
-			"readable":     file.Permissions["readable"],
-			"formFillable": true,
-			"editable":     file.Permissions["editable"],
-			"manageable":   file.Permissions["manageable"],
-			"commentable":  file.Permissions["commentable"],
-		}
-
-		if userId < 0 {
-			permissions = map[string]bool{
-				"readable":     false,
-				"formFillable": true,
-				"commentable":  false,
-				"editable":     false,
-			}
-		}
-		file.Permissions = permissions
-	}
 
 	fileInfo := loadFileInfo(file)
 
@@ -525,20 +522,12 @@ func sendShimoInfo(c *gin.Context, file *db.File) {
 }
 
 func sendFileInfo(c *gin.Context, file *db.File) {
-	downloadUrl, err := invoker.Services.AwosService.GetDownloadURL(file.Guid, file.Name, 3600)
+	downloadUrl, err := invoker.Services.Storage.GetDownloadURL(file.Guid, file.Name, 3600)
 	if err != nil {
-		c.JSON(500, gin.H{"message": "awos get file error"})
+		c.JSON(500, gin.H{"message": "storage get file error"})
 		return
 	}
 
-	// Replace the download URL prefix
-	publicEndpointReplacement := econf.GetString("awos.publicEndpointReplacement")
-	awosEndpoint := econf.GetString("awos.endpoint")
-
-	if publicEndpointReplacement != "" && awosEndpoint != "" {
-		downloadUrl = strings.Replace(downloadUrl, awosEndpoint, publicEndpointReplacement, 1)
-	}
-
 	extension := filepath.Ext(file.Name)
 	if len(extension) > 0 {
 		extension = strings.ToLower(extension[1:])
@@ -587,6 +576,42 @@ func loadFileInfo(file *db.File) *FileInfo {
 		CreatedAt:   ctime,
 		UpdatedAt:   utime,
 		TeamGuid:    "123",
+		Metadata:    callbackMetadata(file),
+	}
+}
+
+// callbackMetadata returns the metadata of a file listed in fileMetadata.callbackKeys, it uses the metadata
+// already loaded on the file (see loadFileMetadata) and queries it otherwise
+func callbackMetadata(file *db.File) map[string]string {
+	keys := econf.GetStringSlice("fileMetadata.callbackKeys")
+	if len(keys) == 0 {
+		return nil
+	}
+	metadata := file.Metadata
+	if metadata == nil {
+		var err error
+		if metadata, err = db.FindFileMetadata(invoker.DB, file.ID, keys...); err != nil {
+			elog.Warn("load file metadata failed", l.S("fileGuid", file.Guid), l.E(err))
+			return nil
+		}
+	}
+	res := make(map[string]string, len(keys))
+	for _, k := range keys {
+		if v, ok := metadata[k]; ok {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// loadFileMetadata loads at once the metadata returned by loadFileInfo for a list of files
+func loadFileMetadata(files []db.File) {
+	keys := econf.GetStringSlice("fileMetadata.callbackKeys")
+	if len(keys) == 0 {
+		return
+	}
+	if err := db.LoadFileTagsAndMetadata(invoker.DB, files, keys...); err != nil {
+		elog.Warn("load file metadata failed", l.E(err))
 	}
 }
 
@@ -598,7 +623,7 @@ func GetFileUrl(c *gin.Context) {
 	}
 	fileGuid := c.Param("fileGuid")
 	var u string
-	file, err := db.FindFileByGuidAndUserId(invoker.DB, userId, fileGuid)
+	file, err := db.FindFileByGuidWithShareLink(invoker.DB, userId, fileGuid, c.GetString("shareToken"))
 	if err != nil {
 		handleDBError(c, err)
 		return
@@ -620,3 +645,14 @@ func GetFileUrl(c *gin.Context) {
 		"url": u,
 	})
 }
+
+// withoutFolders drops the folders from a file list, the SDK only knows about files
+func withoutFolders(files []db.File) []db.File {
+	res := files[:0]
+	for _, f := range files {
+		if !f.IsFolder {
+			res = append(res, f)
+		}
+	}
+	return res
+}
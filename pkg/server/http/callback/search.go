@@ -10,40 +10,47 @@ import (
 	"sdk-demo-go/pkg/models/db"
 )
 
+const (
+	// relatedUserPageSize is how many related users are returned when no page size is given
+	relatedUserPageSize = 50
+	// maxUserPageSize is the largest page of users returned by the searches
+	maxUserPageSize = 100
+)
+
+// SearchRelatedUsers returns the users related to the current user: recent collaborators first,
+// then the members of the same departments and teams, then the other users of the app so that
+// a new user still gets suggestions. Paginated with the page and pageSize (at most 100) query parameters
 func SearchRelatedUsers(c *gin.Context) {
-	users, err := db.FindAllUsers(invoker.DB)
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+	if pageSize < 1 {
+		pageSize = relatedUserPageSize
+	}
+	pageSize = min(pageSize, maxUserPageSize)
+	users, _, err := db.SearchUsers(invoker.DB, db.UserQuery{
+		UserId:   getUserIdFromToken(c),
+		Page:     page,
+		PageSize: pageSize,
+	})
 	if err != nil {
 		handleDBError(c, err)
 		return
 	}
 
-	userId := getUserIdFromToken(c)
-	// This fetches all users and filters out the current user; redesign the logic for production use
-	res := make([]UserInfo, 0)
-	for i := range users {
-		if users[i].ID == userId {
-			continue
-		}
-		res = append(res, UserInfo{
-			Id:        strconv.FormatInt(users[i].ID, 10),
-			Name:      users[i].Name,
-			Avatar:    users[i].Avatar,
-			Email:     users[i].Email,
-			CanBother: users[i].CanBother,
-		})
-	}
-
-	c.JSON(200, res)
+	c.JSON(200, toUserInfos(users))
 }
 
+// SearchRelatedFiles returns the files the user opened recently, most recently opened first
 func SearchRelatedFiles(c *gin.Context) {
 	userId := getUserIdFromToken(c)
-	files, err := db.FindFileByUserId(invoker.DB, userId, 0, "")
+	files, err := db.FindRecentFiles(invoker.DB, userId, 0)
 	if err != nil {
 		handleDBError(c, err)
 		return
 	}
 
+	files = withoutFolders(files)
+	loadFileMetadata(files)
 	fileInfos := make([]FileInfo, 0)
 	for i := range files {
 		fileInfos = append(fileInfos, *loadFileInfo(&files[i]))
@@ -67,9 +74,13 @@ func SearchByKeyword(c *gin.Context) {
 		c.JSON(400, gin.H{"message": "invalid request"})
 		return
 	}
+	if req.Page < 1 {
+		req.Page = 1
+	}
 	if req.PageSize < 1 {
 		req.PageSize = 6
 	}
+	req.PageSize = min(req.PageSize, maxUserPageSize)
 
 	file, err := db.FindFileByGuid(invoker.DB, req.FileId)
 	if err != nil {
@@ -78,7 +89,7 @@ func SearchByKeyword(c *gin.Context) {
 	}
 
 	// Search collaborators
-	var resCollaborators []UserInfo
+	resCollaborators := emptyUserPage(req)
 	if strings.Contains(req.Type, "collaborator") {
 		fps, err := db.FindFilePermissionsByFileId(invoker.DB, file.ID)
 		if err != nil {
@@ -92,31 +103,10 @@ func SearchByKeyword(c *gin.Context) {
 				userIds = append(userIds, fps[i].UserId)
 			}
 		}
-		users, err := db.FindUsersByIds(invoker.DB, userIds)
-		if err != nil {
-			handleDBError(c, err)
+		var ok bool
+		if resCollaborators, ok = searchUserPage(c, req, userIds); !ok {
 			return
 		}
-		// Filter by keyword
-		for _, user := range users {
-			if strings.Contains(user.Name, req.Keyword) {
-				resCollaborators = append(resCollaborators, UserInfo{
-					Id:        strconv.FormatInt(user.ID, 10),
-					Name:      user.Name,
-					Avatar:    user.Avatar,
-					Email:     user.Email,
-					CanBother: user.CanBother,
-				})
-			} else if strings.Contains(req.Keyword, user.Email) {
-				resCollaborators = append(resCollaborators, UserInfo{
-					Id:        strconv.FormatInt(user.ID, 10),
-					Name:      user.Name,
-					Avatar:    user.Avatar,
-					Email:     user.Email,
-					CanBother: user.CanBother,
-				})
-			}
-		}
 	}
 
 	// Search files
@@ -127,7 +117,7 @@ func SearchByKeyword(c *gin.Context) {
 			handleDBError(c, err)
 			return
 		}
-		for _, file := range files {
+		for _, file := range withoutFolders(files) {
 			if strings.Contains(file.Name, req.Keyword) {
 				resFiles = append(resFiles, *loadFileInfo(&file))
 			}
@@ -190,7 +180,7 @@ func SearchByKeyword(c *gin.Context) {
 	}
 
 	// Search team members
-	var resTeamMembers []UserInfo
+	resTeamMembers := emptyUserPage(req)
 	if strings.Contains(req.Type, "team_member") {
 		teams, err := db.FindTeamsByUserId(invoker.DB, userId)
 		if err != nil {
@@ -204,92 +194,28 @@ func SearchByKeyword(c *gin.Context) {
 				handleDBError(c, err)
 				return
 			}
-
-			// Remove the current user
-			flag := false
-			for i := range userIds {
-				if userIds[i] == userId {
-					flag = true
-					userIds[i] = userIds[0]
-				}
-			}
-			if flag {
-				userIds = userIds[1:]
-			}
-
-			members, err := db.FindUsersByIds(invoker.DB, userIds)
-			if err != nil {
-				handleDBError(c, err)
+			var ok bool
+			if resTeamMembers, ok = searchUserPage(c, req, userIds); !ok {
 				return
 			}
-
-			for _, member := range members {
-				if strings.Contains(member.Name, req.Keyword) {
-					resTeamMembers = append(resTeamMembers, UserInfo{
-						Id:        strconv.FormatInt(member.ID, 10),
-						Name:      member.Name,
-						Avatar:    member.Avatar,
-						Email:     member.Email,
-						CanBother: member.CanBother,
-					})
-				} else if strings.Contains(req.Keyword, member.Email) {
-					resTeamMembers = append(resTeamMembers, UserInfo{
-						Id:        strconv.FormatInt(member.ID, 10),
-						Name:      member.Name,
-						Avatar:    member.Avatar,
-						Email:     member.Email,
-						CanBother: member.CanBother,
-					})
-				}
-			}
 		}
 	}
 
-	// Search recent contacts
-	var resRecentUsers []UserInfo
+	// Search recent contacts, the related users rank first
+	resRecentUsers := emptyUserPage(req)
 	if strings.Contains(req.Type, "recent") {
-		users, err := db.FindAllUsers(invoker.DB)
-		if err != nil {
-			handleDBError(c, err)
+		var ok bool
+		if resRecentUsers, ok = searchUserPage(c, req, nil); !ok {
 			return
 		}
-
-		for _, user := range users {
-			if strings.Contains(user.Name, req.Keyword) {
-				resRecentUsers = append(resRecentUsers, UserInfo{
-					Id:        strconv.FormatInt(user.ID, 10),
-					Name:      user.Name,
-					Avatar:    user.Avatar,
-					Email:     user.Email,
-					CanBother: user.CanBother,
-				})
-			} else if strings.Contains(req.Keyword, user.Email) {
-				resRecentUsers = append(resRecentUsers, UserInfo{
-					Id:        strconv.FormatInt(user.ID, 10),
-					Name:      user.Name,
-					Avatar:    user.Avatar,
-					Email:     user.Email,
-					CanBother: user.CanBother,
-				})
-			}
-		}
 	}
 
-	if len(resTeamMembers) > req.PageSize {
-		resTeamMembers = resTeamMembers[:req.PageSize]
-	}
-	if len(resCollaborators) > req.PageSize {
-		resCollaborators = resCollaborators[:req.PageSize]
-	}
 	if len(resDepts) > req.PageSize {
 		resDepts = resDepts[:req.PageSize]
 	}
 	if len(resFiles) > req.PageSize {
 		resFiles = resFiles[:req.PageSize]
 	}
-	if len(resRecentUsers) > req.PageSize {
-		resRecentUsers = resRecentUsers[:req.PageSize]
-	}
 
 	c.JSON(200, gin.H{
 		"files": gin.H{
@@ -299,20 +225,8 @@ func SearchByKeyword(c *gin.Context) {
 			"pageCount": 1,
 			"results":   resFiles,
 		},
-		"recentUsers": gin.H{
-			"count":     len(resRecentUsers),
-			"page":      0,
-			"pageSize":  req.PageSize,
-			"pageCount": 1,
-			"results":   resRecentUsers,
-		},
-		"collaborators": gin.H{
-			"count":     len(resCollaborators),
-			"page":      0,
-			"pageSize":  req.PageSize,
-			"pageCount": 1,
-			"results":   resCollaborators,
-		},
+		"recentUsers":   resRecentUsers,
+		"collaborators": resCollaborators,
 		"department": gin.H{
 			"count":     len(resDepts),
 			"page":      0,
@@ -320,12 +234,52 @@ func SearchByKeyword(c *gin.Context) {
 			"pageCount": 1,
 			"results":   resDepts,
 		},
-		"teamMembers": gin.H{
-			"count":     len(resTeamMembers),
-			"page":      0,
-			"pageSize":  req.PageSize,
-			"pageCount": 1,
-			"results":   resTeamMembers,
-		},
+		"teamMembers": resTeamMembers,
+	})
+}
+
+// searchUserPage searches the users matching the keyword of a request among userIds, or among every user when nil,
+// formatted as a page of results. The error response is sent when it returns false
+func searchUserPage(c *gin.Context, req SearchByKeywordReq, userIds []int64) (gin.H, bool) {
+	users, total, err := db.SearchUsers(invoker.DB, db.UserQuery{
+		UserId:   getUserIdFromToken(c),
+		Keyword:  req.Keyword,
+		UserIds:  userIds,
+		Page:     req.Page,
+		PageSize: req.PageSize,
 	})
+	if err != nil {
+		handleDBError(c, err)
+		return nil, false
+	}
+	page := emptyUserPage(req)
+	page["count"] = total
+	page["pageCount"] = max((total+req.PageSize-1)/req.PageSize, 1)
+	page["results"] = toUserInfos(users)
+	return page, true
+}
+
+// emptyUserPage is the page of results of a user search that was not requested
+func emptyUserPage(req SearchByKeywordReq) gin.H {
+	return gin.H{
+		"count":     0,
+		"page":      req.Page,
+		"pageSize":  req.PageSize,
+		"pageCount": 1,
+		"results":   []UserInfo{},
+	}
+}
+
+func toUserInfos(users []db.User) []UserInfo {
+	res := make([]UserInfo, 0, len(users))
+	for i := range users {
+		res = append(res, UserInfo{
+			Id:        strconv.FormatInt(users[i].ID, 10),
+			Name:      users[i].Name,
+			Avatar:    users[i].Avatar,
+			Email:     users[i].Email,
+			CanBother: users[i].CanBother,
+		})
+	}
+	return res
 }
@@ -0,0 +1,121 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/audit"
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+)
+
+// HeaderRequestId carries the request ID, generated when the client does not send one
+const HeaderRequestId = "X-Request-Id"
+
+const auditKey = "audit"
+
+// auditRecord collects what the handler reports about the request
+type auditRecord struct {
+	targetType string
+	targetId   string
+	before     interface{}
+	after      interface{}
+}
+
+// AuditMiddleware records every mutating request (anything but GET, HEAD and OPTIONS) in the
+// audit log once the handler returned. The target defaults to the last route parameter;
+// handlers report the target and its snapshots with AuditTarget, AuditBefore and AuditAfter
+func AuditMiddleware(c *gin.Context) {
+	requestId := c.GetHeader(HeaderRequestId)
+	if requestId == "" || len(requestId) > 64 {
+		requestId = uuid.New().String()
+	}
+	c.Header(HeaderRequestId, requestId)
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		c.Next()
+		return
+	}
+	rec := &auditRecord{}
+	if n := len(c.Params); n > 0 {
+		rec.targetType = c.Params[n-1].Key
+		rec.targetId = strings.TrimPrefix(c.Params[n-1].Value, "/")
+	}
+	c.Set(auditKey, rec)
+
+	c.Next()
+
+	if c.FullPath() == "" {
+		return
+	}
+	entry := db.AuditLog{
+		ActorId:    c.GetInt64("userId"),
+		Action:     c.Request.Method + " " + c.FullPath(),
+		Path:       c.Request.URL.Path,
+		TargetType: rec.targetType,
+		TargetId:   rec.targetId,
+		Status:     c.Writer.Status(),
+		Before:     auditJSON(rec.before),
+		After:      auditJSON(rec.after),
+		RequestId:  requestId,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if rec.before != nil || rec.after != nil {
+		if changes, err := audit.Diff(rec.before, rec.after); err == nil {
+			entry.Diff = auditJSON(changes)
+		}
+	}
+	if err := db.CreateAuditLog(invoker.DB, &entry); err != nil {
+		elog.Error("save audit log failed", l.S("action", entry.Action), l.S("requestId", requestId), l.E(err))
+	}
+}
+
+// AuditTarget sets the kind and ID of the object changed by the request
+func AuditTarget(c *gin.Context, targetType string, targetId string) {
+	if rec, ok := auditRecordOf(c); ok {
+		rec.targetType = targetType
+		rec.targetId = targetId
+	}
+}
+
+// AuditBefore stores the snapshot of the target before it is changed, nil for created targets
+func AuditBefore(c *gin.Context, snapshot interface{}) {
+	if rec, ok := auditRecordOf(c); ok {
+		rec.before = snapshot
+	}
+}
+
+// AuditAfter stores the snapshot of the target after it changed, nil for deleted targets
+func AuditAfter(c *gin.Context, snapshot interface{}) {
+	if rec, ok := auditRecordOf(c); ok {
+		rec.after = snapshot
+	}
+}
+
+func auditRecordOf(c *gin.Context) (*auditRecord, bool) {
+	v, ok := c.Get(auditKey)
+	if !ok {
+		return nil, false
+	}
+	rec, ok := v.(*auditRecord)
+	return rec, ok
+}
+
+func auditJSON(v interface{}) db.JSONText {
+	if v == nil {
+		return ""
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return db.JSONText(raw)
+}
@@ -86,6 +86,18 @@ func UserAuthMiddleware(c *gin.Context) {
 	_userId, _ := c.Get("userId")
 	userId, _ := _userId.(int64)
 
+	// Guests holding a share link token have no user, they act on behalf of the link creator's app
+	if v, ok := c.Get("shareLink"); ok && userId < 0 {
+		link := v.(*db.ShareLink)
+		if !shareLinkCovers(c, link) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"message": "share link does not cover this request",
+			})
+			return
+		}
+		userId = link.CreatorId
+	}
+
 	user, err := db.FindUserById(invoker.DB, userId)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
@@ -99,6 +111,28 @@ func UserAuthMiddleware(c *gin.Context) {
 	c.Next()
 }
 
+// shareLinkCovers reports whether a guest may send the request: only reads of the shared file,
+// or of a file below the shared folder
+func shareLinkCovers(c *gin.Context, link *db.ShareLink) bool {
+	fileGuid := c.Param("fileGuid")
+	if c.Request.Method != http.MethodGet || fileGuid == "" {
+		return false
+	}
+	if fileGuid == link.FileGuid {
+		return true
+	}
+	path, err := db.FindFolderPath(invoker.DB, fileGuid)
+	if err != nil {
+		return false
+	}
+	for _, f := range path {
+		if f.Guid == link.FileGuid {
+			return true
+		}
+	}
+	return false
+}
+
 func setAppClient(c *gin.Context, appId string) {
 	if c.GetBool("multipleClientMode") {
 		ac, _ := db.AppClientFindById(invoker.DB, appId)
@@ -113,6 +147,8 @@ func setAppClient(c *gin.Context, appId string) {
 }
 
 // ValidateUserToken verifies the token and stores userId in the context
+// Tokens issued for a share link also store the link (shareToken, shareLink) and stop working once
+// the link is revoked or expired
 func ValidateUserToken(c *gin.Context, token string) error {
 	if token == sdkapi.AnonymousToken {
 		// Anonymous mode: form_fill with userId -1
@@ -120,20 +156,24 @@ func ValidateUserToken(c *gin.Context, token string) error {
 		c.Set("mode", "form_fill")
 		return nil
 	} else {
-		decodedToken, err := jwt.ParseWithClaims(token, &utils.UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(econf.GetString("jwt.secret")), nil
-		})
-
-		if decodedToken == nil || !decodedToken.Valid {
+		claim, err := ParseUserToken(token)
+		if claim == nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"message": "invalid token",
 			})
 			return err
 		}
 
-		claim, ok := decodedToken.Claims.(*utils.UserClaims)
-		if !ok {
-			panic("parse token error")
+		if claim.ShareToken != "" {
+			link, err := db.FindActiveShareLink(invoker.DB, claim.ShareToken)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"message": "share link is no longer valid",
+				})
+				return err
+			}
+			c.Set("shareToken", claim.ShareToken)
+			c.Set("shareLink", link)
 		}
 		c.Set("userId", claim.UserId)
 		c.Set("mode", claim.Mode)
@@ -141,6 +181,22 @@ func ValidateUserToken(c *gin.Context, token string) error {
 	}
 }
 
+// ParseUserToken verifies a user token and returns its claims, nil when the token is invalid
+func ParseUserToken(token string) (*utils.UserClaims, error) {
+	decodedToken, err := jwt.ParseWithClaims(token, &utils.UserClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(econf.GetString("jwt.secret")), nil
+	})
+	if decodedToken == nil || !decodedToken.Valid {
+		return nil, err
+	}
+
+	claim, ok := decodedToken.Claims.(*utils.UserClaims)
+	if !ok {
+		panic("parse token error")
+	}
+	return claim, err
+}
+
 func FrontInspectAuthMiddleware(c *gin.Context) {
 	var user *db.User
 	token := c.GetHeader("Authorization")
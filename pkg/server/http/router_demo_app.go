@@ -9,12 +9,34 @@ import (
 
 // registerDemoAppAPIs for the demo project's own APIs
 func registerDemoAppAPIs(r *egin.Component) {
-	apiGroup := r.Group("/api")
+	apiGroup := r.Group("/api", middlewares.AuditMiddleware)
 	apiGroup.GET("/sign", api.SignJWT)
 
+	// storage api, authorized by the signed url rather than the user token
+	apiStorageGroup := apiGroup.Group("/storage")
+	apiStorageGroup.GET("/objects/*key", api.DownloadStorageObject)
+	apiStorageGroup.HEAD("/objects/*key", api.DownloadStorageObject)
+	apiStorageGroup.PUT("/objects/*key", api.UploadStorageObject)
+
+	// resumable upload api
+	apiUploadGroup := apiGroup.Group("/uploads", middlewares.UserAuthMiddleware)
+	apiUploadGroup.POST("", api.CreateUploadSession)
+	apiUploadGroup.GET("/:uploadId", api.GetUploadSession)
+	apiUploadGroup.PUT("/:uploadId/parts/:partNumber", api.UploadSessionPart)
+	apiUploadGroup.POST("/:uploadId/complete", api.CompleteUploadSession)
+	apiUploadGroup.DELETE("/:uploadId", api.AbortUploadSession)
+
+	// background job api
+	apiJobGroup := apiGroup.Group("/jobs", middlewares.UserAuthMiddleware)
+	apiJobGroup.GET("/:jobId", api.GetJob)
+
+	// push api
+	apiGroup.GET("/stream", middlewares.UserAuthMiddleware, api.Stream)
+
 	// app api
 	apiAppGroup := apiGroup.Group("/apps", middlewares.UserAuthMiddleware)
 	apiAppGroup.GET("/detail", api.GetAppDetails)
+	apiAppGroup.GET("/roles", api.GetRoles)
 	apiAppGroup.PUT("/endpoint-url", api.PutEndpointUrl)
 
 	// apiTest api
@@ -45,6 +67,20 @@ func registerDemoAppAPIs(r *egin.Component) {
 	apiFileGroup := apiGroup.Group("/files", middlewares.UserAuthMiddleware)
 	apiFileGroup.GET("/", api.GetUserFiles)
 	apiFileGroup.GET("", api.GetUserFiles)
+	apiFileGroup.GET("/recent", api.GetRecentFiles)
+	apiFileGroup.GET("/starred", api.GetStarredFiles)
+	apiFileGroup.GET("/pinned", api.GetPinnedFiles)
+	apiFileGroup.PUT("/:fileGuid/star", api.StarFile)
+	apiFileGroup.DELETE("/:fileGuid/star", api.UnstarFile)
+	apiFileGroup.PUT("/:fileGuid/pin", api.PinFile)
+	apiFileGroup.DELETE("/:fileGuid/pin", api.UnpinFile)
+	apiFileGroup.GET("/:fileGuid/tags", api.GetFileTags)
+	apiFileGroup.POST("/:fileGuid/tags", api.AddFileTags)
+	apiFileGroup.PUT("/:fileGuid/tags", api.ReplaceFileTags)
+	apiFileGroup.DELETE("/:fileGuid/tags/:tag", api.RemoveFileTag)
+	apiFileGroup.GET("/:fileGuid/metadata", api.GetFileMetadata)
+	apiFileGroup.PATCH("/:fileGuid/metadata", api.UpdateFileMetadata)
+	apiFileGroup.DELETE("/:fileGuid/metadata/:key", api.RemoveFileMetadata)
 	apiFileGroup.GET("/:fileGuid/thumbnail", api.GetFileThumbnail)
 	apiFileGroup.GET("/:fileGuid/open", api.OpenFile)
 	apiFileGroup.GET("/:fileGuid/download-plain-text", api.GetPlainText)
@@ -67,6 +103,15 @@ func registerDemoAppAPIs(r *egin.Component) {
 	apiFileGroup.DELETE("/batch/delete", api.BatchDeleteFile)
 	apiFileGroup.PATCH("/:fileGuid", api.RenameFile)
 	apiFileGroup.GET("/:fileGuid/collaborators", api.GetCollaborators)
+	apiFileGroup.GET("/:fileGuid/group-collaborators", api.GetGroupCollaborators)
+	apiFileGroup.PATCH("/:fileGuid/group-collaborators", api.UpdateGroupCollaborators)
+	apiFileGroup.GET("/:fileGuid/permissions/explain", api.ExplainFilePermissions)
+	apiFileGroup.GET("/:fileGuid/share-links", api.ListShareLinks)
+	apiFileGroup.POST("/:fileGuid/share-links", api.CreateShareLink)
+	apiFileGroup.DELETE("/:fileGuid/share-links/:linkId", api.RevokeShareLink)
+	apiFileGroup.GET("/:fileGuid/activity", api.GetFileActivity)
+	apiFileGroup.GET("/:fileGuid/path", api.GetFilePath)
+	apiFileGroup.POST("/:fileGuid/move", api.MoveFile)
 	apiFileGroup.PATCH(":fileGuid/collaborators", api.UpdateCollaborators)
 	apiFileGroup.GET("/:fileGuid/doc-sidebar-info", api.GetDocSidebar)
 	apiFileGroup.POST("/importUrl", api.GetImportUrl)
@@ -83,13 +128,54 @@ func registerDemoAppAPIs(r *egin.Component) {
 	apiUserGroup.GET("/", api.GetAllUsers)
 	apiUserGroup.GET("", api.GetAllUsers)
 
+	// share link api, the link token and its password authorize the exchange
+	apiShareLinkGroup := apiGroup.Group("/share-links")
+	apiShareLinkGroup.POST("/:shareToken/exchange", api.ExchangeShareLink)
+
+	// folder api
+	apiFolderGroup := apiGroup.Group("/folders", middlewares.UserAuthMiddleware)
+	apiFolderGroup.POST("", api.CreateFolder)
+	apiFolderGroup.DELETE("/:folderGuid", api.DeleteFolder)
+
+	// search api
+	apiGroup.GET("/search", middlewares.UserAuthMiddleware, api.Search)
+
+	// trash api
+	apiTrashGroup := apiGroup.Group("/trash", middlewares.UserAuthMiddleware)
+	apiTrashGroup.GET("", api.GetTrash)
+	apiTrashGroup.POST("/:fileGuid/restore", api.RestoreTrashItem)
+	apiTrashGroup.DELETE("/:fileGuid", api.PurgeTrashItem)
+
 	// event api
 	apiEventGroup := apiGroup.Group("/events", middlewares.UserAuthMiddleware)
 	apiEventGroup.GET("/", api.GetEvents)
 	apiEventGroup.GET("", api.GetEvents)
+	apiEventGroup.GET("/stats", api.GetEventStats)
 	apiEventGroup.GET("/system-messages", api.GetSystemMessages)
 	apiEventGroup.GET("/error_callback", api.ErrorCallback)
 
+	// notification api
+	apiNotificationGroup := apiGroup.Group("/notifications", middlewares.UserAuthMiddleware)
+	apiNotificationGroup.GET("", api.ListNotifications)
+	apiNotificationGroup.GET("/unread-count", api.CountUnreadNotifications)
+	apiNotificationGroup.POST("/read-all", api.ReadAllNotifications)
+	apiNotificationGroup.POST("/:notificationId/read", api.ReadNotification)
+
+	// audit log api
+	apiAuditLogGroup := apiGroup.Group("/audit-logs", middlewares.UserAuthMiddleware)
+	apiAuditLogGroup.GET("", api.ListAuditLogs)
+	apiAuditLogGroup.GET("/export", api.ExportAuditLogs)
+
+	// webhook api
+	apiWebhookGroup := apiGroup.Group("/webhooks", middlewares.UserAuthMiddleware)
+	apiWebhookGroup.POST("", api.CreateWebhook)
+	apiWebhookGroup.GET("", api.ListWebhooks)
+	apiWebhookGroup.GET("/:webhookId", api.GetWebhook)
+	apiWebhookGroup.PUT("/:webhookId", api.UpdateWebhook)
+	apiWebhookGroup.DELETE("/:webhookId", api.DeleteWebhook)
+	apiWebhookGroup.GET("/:webhookId/deliveries", api.ListWebhookDeliveries)
+	apiWebhookGroup.POST("/:webhookId/deliveries/:deliveryId/redeliver", api.RedeliverWebhookDelivery)
+
 	// front inspect api
 	apiFrontInspectGroup := apiGroup.Group("/internal", middlewares.FrontInspectAuthMiddleware)
 	apiFrontInspectGroup.POST("", api.FrontInspectCreate)
@@ -3,33 +3,49 @@ package awos
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/gotomicro/ego/core/econf"
+
+	"sdk-demo-go/pkg/services/storage"
 )
 
+var _ storage.Storage = (*AwosService)(nil)
+
 // AwosService provides object storage service using AWS S3 compatible API
 type AwosService struct {
-	svc *s3.S3
+	svc      *s3.S3
+	uploader *s3manager.Uploader
 }
 
 var bucket string
 
 // Init initializes a new AwosService instance with configured S3 client
 func Init() *AwosService {
+	endpoint := econf.GetString("awos.endpoint")
+	region := econf.GetString("awos.region")
+	if region == "" {
+		region = "us-east-1"
+	}
 	sess, err := session.NewSession(&aws.Config{
 		Credentials: credentials.NewStaticCredentials(
 			econf.GetString("awos.accessKeyId"),
 			econf.GetString("awos.secretAccessKey"),
 			""),
-		Region:           aws.String("minio"),
-		Endpoint:         aws.String(econf.GetString("awos.endpoint")),
+		Region:           aws.String(region),
+		Endpoint:         aws.String(endpoint),
 		S3ForcePathStyle: aws.Bool(econf.GetBool("awos.s3ForcePathStyle")),
-		DisableSSL:       aws.Bool(true),
+		// Only fall back to plain HTTP when the endpoint explicitly asks for it
+		DisableSSL: aws.Bool(strings.HasPrefix(endpoint, "http://")),
 	})
 	if err != nil {
 		panic(err)
@@ -37,7 +53,7 @@ func Init() *AwosService {
 	svc := s3.New(sess)
 	bucket = econf.GetString("awos.bucket")
 
-	return &AwosService{svc: svc}
+	return &AwosService{svc: svc, uploader: s3manager.NewUploaderWithClient(svc)}
 }
 
 // Save uploads data to the object storage with the specified key
@@ -50,6 +66,113 @@ func (a *AwosService) Save(key string, data []byte) error {
 	return err
 }
 
+// Upload streams r into the object storage with the specified key
+// Large bodies are sent as a multipart upload so only one part is held in memory at a time
+func (a *AwosService) Upload(key string, r io.Reader) error {
+	_, err := a.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   r,
+	})
+	return err
+}
+
+// Stat returns the size, modification time and ETag of the object with the specified key
+func (a *AwosService) Stat(key string) (storage.ObjectInfo, error) {
+	result, err := a.svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return storage.ObjectInfo{}, notFound(err)
+	}
+	return storage.ObjectInfo{
+		Size:    aws.Int64Value(result.ContentLength),
+		ModTime: aws.TimeValue(result.LastModified),
+		ETag:    aws.StringValue(result.ETag),
+	}, nil
+}
+
+// OpenRange streams the object with the specified key from offset, a negative length reads to the end
+func (a *AwosService) OpenRange(key string, offset, length int64) (io.ReadCloser, error) {
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+	result, err := a.svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(byteRange),
+	})
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return result.Body, nil
+}
+
+// CreateMultipart starts an S3 multipart upload and returns its upload ID
+func (a *AwosService) CreateMultipart(key string) (string, error) {
+	result, err := a.svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(result.UploadId), nil
+}
+
+// UploadPart streams a numbered part to S3 and returns its ETag
+// The body is sent with an unsigned payload so it does not have to be buffered to be hashed
+func (a *AwosService) UploadPart(key, uploadId string, number int64, r io.Reader, size int64) (string, error) {
+	req, result := a.svc.UploadPartRequest(&s3.UploadPartInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadId),
+		PartNumber:    aws.Int64(number),
+		ContentLength: aws.Int64(size),
+		Body:          aws.ReadSeekCloser(r),
+	})
+	req.HTTPRequest.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	// A streamed body cannot be replayed, the client retries the part instead
+	req.Retryer = client.NoOpRetryer{}
+	if err := req.Send(); err != nil {
+		return "", notFound(err)
+	}
+	return aws.StringValue(result.ETag), nil
+}
+
+// CompleteMultipart assembles the uploaded parts into the object
+func (a *AwosService) CompleteMultipart(key, uploadId string, parts []storage.Part) error {
+	completed := make([]*s3.CompletedPart, len(parts))
+	for i, part := range parts {
+		completed[i] = &s3.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int64(part.Number),
+		}
+	}
+	_, err := a.svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadId),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	return notFound(err)
+}
+
+// AbortMultipart discards the multipart upload and its parts
+func (a *AwosService) AbortMultipart(key, uploadId string) error {
+	_, err := a.svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+	})
+	if notFound(err) == storage.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
 // GetUploadURL creates an upload URL valid for the given number of seconds
 func (a *AwosService) GetUploadURL(key string, expireSeconds int64) (string, error) {
 	req, _ := a.svc.PutObjectRequest(&s3.PutObjectInput{
@@ -76,6 +199,7 @@ func (a *AwosService) Get(key string) ([]byte, error) {
 }
 
 // GetDownloadURL creates a download URL valid for the given number of seconds
+// The endpoint prefix is swapped for awos.publicEndpointReplacement so the URL is reachable from outside
 func (a *AwosService) GetDownloadURL(key string, filename string, expireSeconds int64) (string, error) {
 	req, _ := a.svc.GetObjectRequest(&s3.GetObjectInput{
 		Bucket:                     aws.String(bucket),
@@ -83,7 +207,18 @@ func (a *AwosService) GetDownloadURL(key string, filename string, expireSeconds
 		ResponseContentDisposition: aws.String(fmt.Sprintf(`attachment; filename="%s"`, filename)),
 	})
 
-	return req.Presign(time.Duration(expireSeconds) * time.Second)
+	downloadUrl, err := req.Presign(time.Duration(expireSeconds) * time.Second)
+	if err != nil {
+		return "", err
+	}
+
+	// Replace the download URL prefix
+	publicEndpointReplacement := econf.GetString("awos.publicEndpointReplacement")
+	awosEndpoint := econf.GetString("awos.endpoint")
+	if publicEndpointReplacement != "" && awosEndpoint != "" {
+		downloadUrl = strings.Replace(downloadUrl, awosEndpoint, publicEndpointReplacement, 1)
+	}
+	return downloadUrl, nil
 }
 
 // Remove deletes an object from the storage by key
@@ -98,3 +233,14 @@ func (a *AwosService) Remove(key string) error {
 
 	return nil
 }
+
+// notFound maps the S3 missing object errors to storage.ErrNotFound
+func notFound(err error) error {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, s3.ErrCodeNoSuchUpload, "NotFound":
+			return storage.ErrNotFound
+		}
+	}
+	return err
+}
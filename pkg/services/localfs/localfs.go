@@ -0,0 +1,346 @@
+package localfs
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"sdk-demo-go/pkg/services/storage"
+)
+
+// RoutePrefix is the path under which the demo serves signed object URLs
+const RoutePrefix = "/api/storage/objects/"
+
+var (
+	// ErrInvalidKey is returned for empty keys or keys that escape the storage root
+	ErrInvalidKey = errors.New("invalid object key")
+	// ErrInvalidSignature is returned when a signed URL has been tampered with
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrExpired is returned when a signed URL is past its expiry time
+	ErrExpired = errors.New("signed url expired")
+)
+
+// Config holds the settings of the local filesystem storage
+type Config struct {
+	// Root is the directory objects are stored under
+	Root string
+	// BaseURL is the externally reachable address of the demo server, used to build signed URLs
+	BaseURL string
+	// Secret is the HMAC key used to sign URLs
+	Secret string
+}
+
+var _ storage.Storage = (*LocalService)(nil)
+
+// LocalService stores objects as plain files on disk and hands out
+// signed, expiring URLs that are served by the demo server itself
+type LocalService struct {
+	root    string
+	baseURL string
+	secret  []byte
+	now     func() time.Time
+}
+
+// New creates a LocalService, creating the root directory if needed
+func New(cfg Config) (*LocalService, error) {
+	if cfg.Root == "" {
+		return nil, errors.New("local storage root is empty")
+	}
+	if cfg.Secret == "" {
+		return nil, errors.New("local storage secret is empty")
+	}
+	if err := os.MkdirAll(cfg.Root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalService{
+		root:    cfg.Root,
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		secret:  []byte(cfg.Secret),
+		now:     time.Now,
+	}, nil
+}
+
+// Save writes data to the object with the specified key
+func (s *LocalService) Save(key string, data []byte) error {
+	return s.Upload(key, bytes.NewReader(data))
+}
+
+// Upload streams r into the object with the specified key
+// The content is written to a temporary file first so readers never see partial content
+func (s *LocalService) Upload(key string, r io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Get reads the object with the specified key
+func (s *LocalService) Get(key string) ([]byte, error) {
+	rc, err := s.OpenRange(key, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
+
+// Stat returns the size, modification time and ETag of the object with the specified key
+// The ETag is derived from the modification time and size, which change on every Upload
+func (s *LocalService) Stat(key string) (storage.ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return storage.ObjectInfo{}, notFound(err)
+	}
+	return storage.ObjectInfo{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		ETag:    fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()),
+	}, nil
+}
+
+// OpenRange opens the object with the specified key from offset, a negative length reads to the end
+func (s *LocalService) OpenRange(key string, offset, length int64) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return f, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, nil
+}
+
+// Remove deletes the object with the specified key, removing a missing object is not an error
+func (s *LocalService) Remove(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// multipartDir is where parts of unfinished multipart uploads are kept, relative to the root
+const multipartDir = ".multipart"
+
+// CreateMultipart starts a multipart upload, parts are kept in their own directory until completed
+func (s *LocalService) CreateMultipart(key string) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	uploadId := hex.EncodeToString(buf)
+
+	dir, err := s.uploadDir(uploadId)
+	if err != nil {
+		return "", err
+	}
+	return uploadId, os.MkdirAll(dir, 0o755)
+}
+
+// UploadPart stores a numbered part, replacing any earlier upload of the same part
+func (s *LocalService) UploadPart(key, uploadId string, number int64, r io.Reader, size int64) (string, error) {
+	p, err := s.partPath(uploadId, number)
+	if err != nil {
+		return "", err
+	}
+	if _, err = os.Stat(filepath.Dir(p)); err != nil {
+		return "", notFound(err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".part-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err == nil && n != size {
+		err = storage.ErrPartSizeMismatch
+	}
+	if err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err = tmp.Close(); err != nil {
+		return "", err
+	}
+	if err = os.Rename(tmp.Name(), p); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+// CompleteMultipart concatenates the parts into the object and removes the upload directory
+func (s *LocalService) CompleteMultipart(key, uploadId string, parts []storage.Part) error {
+	files := make([]io.Reader, 0, len(parts))
+	for _, part := range parts {
+		p, err := s.partPath(uploadId, part.Number)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return notFound(err)
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+
+	if err := s.Upload(key, io.MultiReader(files...)); err != nil {
+		return err
+	}
+	return s.AbortMultipart(key, uploadId)
+}
+
+// AbortMultipart removes the upload directory with every part uploaded so far
+func (s *LocalService) AbortMultipart(key, uploadId string) error {
+	dir, err := s.uploadDir(uploadId)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// uploadDir is the directory holding the parts of a multipart upload
+func (s *LocalService) uploadDir(uploadId string) (string, error) {
+	if strings.Contains(uploadId, "/") {
+		return "", ErrInvalidKey
+	}
+	return s.path(multipartDir + "/" + uploadId)
+}
+
+func (s *LocalService) partPath(uploadId string, number int64) (string, error) {
+	dir, err := s.uploadDir(uploadId)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, strconv.FormatInt(number, 10)), nil
+}
+
+// GetUploadURL creates a signed PUT URL valid for the given number of seconds
+func (s *LocalService) GetUploadURL(key string, expireSeconds int64) (string, error) {
+	return s.signedURL("PUT", key, "", expireSeconds)
+}
+
+// GetDownloadURL creates a signed GET URL valid for the given number of seconds
+func (s *LocalService) GetDownloadURL(key string, filename string, expireSeconds int64) (string, error) {
+	return s.signedURL("GET", key, filename, expireSeconds)
+}
+
+// Verify checks the signature and expiry carried in the query of a signed URL
+func (s *LocalService) Verify(method, key string, query url.Values) error {
+	if _, err := s.path(key); err != nil {
+		return err
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	want := s.sign(method, key, query.Get("filename"), expires)
+	got, err := hex.DecodeString(query.Get("signature"))
+	if err != nil || !hmac.Equal(want, got) {
+		return ErrInvalidSignature
+	}
+	if s.now().Unix() > expires {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (s *LocalService) signedURL(method, key, filename string, expireSeconds int64) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	expires := s.now().Unix() + expireSeconds
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	if filename != "" {
+		query.Set("filename", filename)
+	}
+	query.Set("signature", hex.EncodeToString(s.sign(method, key, filename, expires)))
+
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return s.baseURL + RoutePrefix + strings.Join(segments, "/") + "?" + query.Encode(), nil
+}
+
+func (s *LocalService) sign(method, key, filename string, expires int64) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(method + "\n" + key + "\n" + filename + "\n" + strconv.FormatInt(expires, 10)))
+	return mac.Sum(nil)
+}
+
+// path maps a key to a file under the root, rejecting keys that would escape it
+func (s *LocalService) path(key string) (string, error) {
+	if key == "" || path.Clean("/" + key)[1:] != key {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// notFound maps a missing file to storage.ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return storage.ErrNotFound
+	}
+	return err
+}
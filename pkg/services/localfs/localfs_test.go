@@ -0,0 +1,158 @@
+package localfs
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"sdk-demo-go/pkg/services/storage"
+)
+
+func newTestService(t *testing.T) *LocalService {
+	s, err := New(Config{Root: t.TempDir(), BaseURL: "http://127.0.0.1:9301/", Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return s
+}
+
+func TestLocalService_SaveGetRemove(t *testing.T) {
+	s := newTestService(t)
+
+	if err := s.Save("dir/file-guid", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get("dir/file-guid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("Get() = %q, want %q", got, "hello")
+	}
+	if err = s.Remove("dir/file-guid"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Get("dir/file-guid"); err == nil {
+		t.Error("Get() after Remove() should fail")
+	}
+	if err = s.Remove("dir/file-guid"); err != nil {
+		t.Errorf("Remove() of missing object = %v, want nil", err)
+	}
+}
+
+func TestLocalService_path(t *testing.T) {
+	s := newTestService(t)
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{name: "guid", key: "A1b2C3d4", wantErr: false},
+		{name: "nested", key: "archive/2024/events.jsonl.gz", wantErr: false},
+		{name: "empty", key: "", wantErr: true},
+		{name: "parent", key: "../etc/passwd", wantErr: true},
+		{name: "nested parent", key: "a/../../b", wantErr: true},
+		{name: "absolute", key: "/etc/passwd", wantErr: true},
+		{name: "double slash", key: "a//b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.path(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("path(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalService_Verify(t *testing.T) {
+	s := newTestService(t)
+
+	download, err := s.GetDownloadURL("file-guid", "report 1.docx", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload, err := s.GetUploadURL("file-guid", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloadURL, _ := url.Parse(download)
+	uploadURL, _ := url.Parse(upload)
+
+	if downloadURL.Path != RoutePrefix+"file-guid" {
+		t.Errorf("download path = %q", downloadURL.Path)
+	}
+
+	tampered := downloadURL.Query()
+	tampered.Set("filename", "other.docx")
+
+	tests := []struct {
+		name    string
+		method  string
+		key     string
+		query   url.Values
+		after   time.Duration
+		wantErr error
+	}{
+		{name: "download", method: "GET", key: "file-guid", query: downloadURL.Query()},
+		{name: "upload", method: "PUT", key: "file-guid", query: uploadURL.Query()},
+		{name: "wrong method", method: "PUT", key: "file-guid", query: downloadURL.Query(), wantErr: ErrInvalidSignature},
+		{name: "wrong key", method: "GET", key: "other-guid", query: downloadURL.Query(), wantErr: ErrInvalidSignature},
+		{name: "tampered filename", method: "GET", key: "file-guid", query: tampered, wantErr: ErrInvalidSignature},
+		{name: "missing signature", method: "GET", key: "file-guid", query: url.Values{}, wantErr: ErrInvalidSignature},
+		{name: "expired", method: "GET", key: "file-guid", query: downloadURL.Query(), after: 61 * time.Second, wantErr: ErrExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0).Add(tt.after)
+			s.now = func() time.Time { return now }
+
+			if err := s.Verify(tt.method, tt.key, tt.query); err != tt.wantErr {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalService_Multipart(t *testing.T) {
+	s := newTestService(t)
+
+	uploadId, err := s.CreateMultipart("file-guid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.UploadPart("file-guid", uploadId, 2, strings.NewReader("world"), 5); err != nil {
+		t.Fatal(err)
+	}
+	// A retried part replaces the earlier attempt
+	if _, err = s.UploadPart("file-guid", uploadId, 1, strings.NewReader("HELLO "), 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.UploadPart("file-guid", uploadId, 1, strings.NewReader("hello "), 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.UploadPart("file-guid", uploadId, 3, strings.NewReader("cut"), 10); err != storage.ErrPartSizeMismatch {
+		t.Errorf("UploadPart() of a truncated body error = %v, want %v", err, storage.ErrPartSizeMismatch)
+	}
+	if _, err = s.UploadPart("file-guid", "../escape", 1, strings.NewReader("x"), 1); err == nil {
+		t.Error("UploadPart() with an invalid upload id should fail")
+	}
+
+	err = s.CompleteMultipart("file-guid", uploadId, []storage.Part{{Number: 1}, {Number: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get("file-guid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("Get() = %q, want %q", got, "hello world")
+	}
+	if _, err = s.UploadPart("file-guid", uploadId, 1, strings.NewReader("x"), 1); err != storage.ErrNotFound {
+		t.Errorf("UploadPart() after completion error = %v, want %v", err, storage.ErrNotFound)
+	}
+}
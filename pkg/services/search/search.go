@@ -0,0 +1,317 @@
+// Package search is an embedded in-memory inverted index over the plain text of files.
+// Latin text is split into lower-cased words, CJK text into overlapping bigrams so that
+// queries match inside sentences without a dictionary. Results are ranked with BM25.
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// bm25K1 controls how fast repeated terms stop adding to the score
+	bm25K1 = 1.2
+	// bm25B controls how much long documents are penalized
+	bm25B = 0.75
+	// highlightStart and highlightEnd wrap the matched terms of a snippet
+	highlightStart = "<em>"
+	highlightEnd   = "</em>"
+)
+
+// Hit is a document matching a query
+type Hit struct {
+	// Id is the document ID
+	Id string
+	// Score is the BM25 score of the document, higher is better
+	Score float64
+}
+
+// Index is an inverted index, safe for concurrent use
+type Index struct {
+	mu sync.RWMutex
+	// docs holds the indexed text of every document
+	docs map[string]*document
+	// postings maps a term to the documents containing it and how many times
+	postings map[string]map[string]int
+	// totalLen is the number of tokens of all documents, for the average document length
+	totalLen int
+	// pending holds the documents waiting to be (re)indexed
+	pending map[string]struct{}
+}
+
+type document struct {
+	text   string
+	length int
+}
+
+// token is a term and the rune range it was read from
+type token struct {
+	term       string
+	start, end int
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		docs:     map[string]*document{},
+		postings: map[string]map[string]int{},
+		pending:  map[string]struct{}{},
+	}
+}
+
+// Add indexes the text of a document, replacing its previous text
+func (x *Index) Add(id string, text string) {
+	tokens := tokenize(text)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(id)
+	x.docs[id] = &document{text: text, length: len(tokens)}
+	x.totalLen += len(tokens)
+	for _, t := range tokens {
+		if x.postings[t.term] == nil {
+			x.postings[t.term] = map[string]int{}
+		}
+		x.postings[t.term][id]++
+	}
+}
+
+// Remove drops a document from the index
+func (x *Index) Remove(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(id)
+}
+
+func (x *Index) remove(id string) {
+	doc, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	for _, t := range tokenize(doc.text) {
+		delete(x.postings[t.term], id)
+		if len(x.postings[t.term]) == 0 {
+			delete(x.postings, t.term)
+		}
+	}
+	x.totalLen -= doc.length
+	delete(x.docs, id)
+}
+
+// Len returns the number of indexed documents
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// Search returns every document containing at least one term of the query, best first
+func (x *Index) Search(query string) []Hit {
+	terms := queryTerms(query)
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if len(terms) == 0 || len(x.docs) == 0 {
+		return nil
+	}
+
+	n := float64(len(x.docs))
+	avgLen := float64(x.totalLen) / n
+	scores := map[string]float64{}
+	for term := range terms {
+		docs := x.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+		for id, tf := range docs {
+			f := float64(tf)
+			norm := 1 - bm25B + bm25B*float64(x.docs[id].length)/avgLen
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{Id: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Id < hits[j].Id
+	})
+	return hits
+}
+
+// Snippet returns about width runes of the text of a document around the first match of the query,
+// HTML-escaped, with the matched terms wrapped in <em></em>. It is empty for unknown documents
+func (x *Index) Snippet(id string, query string, width int) string {
+	x.mu.RLock()
+	doc, ok := x.docs[id]
+	x.mu.RUnlock()
+	if !ok {
+		return ""
+	}
+	return snippet(doc.text, queryTerms(query), width)
+}
+
+// Queue marks a document to be (re)indexed by the next call to Pending
+func (x *Index) Queue(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.pending[id] = struct{}{}
+}
+
+// Pending returns and clears the queued documents, sorted
+func (x *Index) Pending() []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	ids := make([]string, 0, len(x.pending))
+	for id := range x.pending {
+		ids = append(ids, id)
+	}
+	x.pending = map[string]struct{}{}
+	sort.Strings(ids)
+	return ids
+}
+
+func snippet(text string, terms map[string]bool, width int) string {
+	runes := []rune(text)
+	var matches []token
+	for _, t := range tokenize(text) {
+		if terms[t.term] {
+			matches = append(matches, t)
+		}
+	}
+
+	start, end := 0, len(runes)
+	if len(matches) > 0 && matches[0].end > width {
+		start = max(matches[0].start-width/4, 0)
+		// Start at a word boundary when there is one before the match
+		if i := indexSpace(runes[start:matches[0].start]); i >= 0 {
+			start += i + 1
+		}
+	}
+	if end-start > width {
+		end = start + width
+		if i := lastIndexSpace(runes[start:end]); i > 0 && (len(matches) == 0 || start+i >= matches[0].end) {
+			end = start + i
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for i := 0; i < len(matches); i++ {
+		m := matches[i]
+		if m.start >= end {
+			break
+		}
+		if m.end <= pos {
+			continue
+		}
+		// Merge overlapping matches, CJK bigrams overlap by one rune
+		mEnd := m.end
+		for i+1 < len(matches) && matches[i+1].start <= mEnd {
+			i++
+			mEnd = max(mEnd, matches[i].end)
+		}
+		mStart := max(m.start, pos)
+		mEnd = min(mEnd, end)
+		b.WriteString(html.EscapeString(string(runes[pos:mStart])))
+		b.WriteString(highlightStart)
+		b.WriteString(html.EscapeString(string(runes[mStart:mEnd])))
+		b.WriteString(highlightEnd)
+		pos = mEnd
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func indexSpace(runes []rune) int {
+	for i, r := range runes {
+		if unicode.IsSpace(r) {
+			return i
+		}
+	}
+	return -1
+}
+
+func lastIndexSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// queryTerms returns the distinct terms of a query
+func queryTerms(query string) map[string]bool {
+	terms := map[string]bool{}
+	for _, t := range tokenize(query) {
+		terms[t.term] = true
+	}
+	return terms
+}
+
+// tokenize splits text into lower-cased words and CJK bigrams, a lone CJK character is its own term
+func tokenize(text string) []token {
+	var tokens []token
+	wordStart, cjkStart := -1, -1
+	var word, cjk []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, token{term: string(word), start: wordStart, end: wordStart + len(word)})
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			tokens = append(tokens, token{term: string(cjk), start: cjkStart, end: cjkStart + 1})
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			tokens = append(tokens, token{term: string(cjk[i : i+2]), start: cjkStart + i, end: cjkStart + i + 2})
+		}
+		cjk = cjk[:0]
+	}
+
+	i := 0
+	for _, r := range text {
+		r = unicode.ToLower(r)
+		switch {
+		case isCJK(r):
+			flushWord()
+			if len(cjk) == 0 {
+				cjkStart = i
+			}
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			if len(word) == 0 {
+				wordStart = i
+			}
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+		i++
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
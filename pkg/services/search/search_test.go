@@ -0,0 +1,110 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "words", text: "Hello, World 42!", want: []string{"hello", "world", "42"}},
+		{name: "cjk bigrams", text: "石墨文档", want: []string{"石墨", "墨文", "文档"}},
+		{name: "single cjk", text: "文", want: []string{"文"}},
+		{name: "mixed", text: "SDK接入指南", want: []string{"sdk", "接入", "入指", "指南"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, tok := range tokenize(tt.text) {
+				got = append(got, tok.term)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex_Search(t *testing.T) {
+	x := NewIndex()
+	x.Add("a", "the quarterly report of the sales team")
+	x.Add("b", "sales sales sales figures")
+	x.Add("c", "石墨文档接入指南")
+	x.Add("d", "nothing relevant here")
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "ranked by frequency", query: "sales", want: []string{"b", "a"}},
+		{name: "shorter document first", query: "report figures", want: []string{"b", "a"}},
+		{name: "case insensitive", query: "QUARTERLY", want: []string{"a"}},
+		{name: "cjk", query: "接入", want: []string{"c"}},
+		{name: "no match", query: "missing", want: nil},
+		{name: "empty query", query: " ,", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, h := range x.Search(tt.query) {
+				got = append(got, h.Id)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	x.Add("b", "updated text")
+	x.Remove("a")
+	if hits := x.Search("sales"); len(hits) != 0 {
+		t.Errorf("Search() after update = %v, want none", hits)
+	}
+	if x.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", x.Len())
+	}
+}
+
+func TestIndex_Snippet(t *testing.T) {
+	x := NewIndex()
+	x.Add("a", "Intro <b>text</b> then the sales figures of the year, and more sales")
+	x.Add("c", "关于石墨文档的接入说明")
+
+	tests := []struct {
+		name  string
+		id    string
+		query string
+		width int
+		want  string
+	}{
+		{name: "escaped and highlighted", id: "a", query: "text", width: 20, want: "Intro &lt;b&gt;<em>text</em>&lt;/b&gt;…"},
+		{name: "window around match", id: "a", query: "figures", width: 16, want: "…<em>figures</em> of the…"},
+		{name: "cjk bigrams merged", id: "c", query: "石墨文档", width: 20, want: "关于<em>石墨文档</em>的接入说明"},
+		{name: "unknown document", id: "x", query: "text", width: 20, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := x.Snippet(tt.id, tt.query, tt.width); got != tt.want {
+				t.Errorf("Snippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex_Pending(t *testing.T) {
+	x := NewIndex()
+	x.Queue("b")
+	x.Queue("a")
+	x.Queue("b")
+	if got := x.Pending(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Pending() = %v, want [a b]", got)
+	}
+	if got := x.Pending(); len(got) != 0 {
+		t.Errorf("Pending() = %v, want empty", got)
+	}
+}
@@ -1,8 +1,9 @@
 package services
 
 import (
-	"sdk-demo-go/pkg/services/awos"
+	"sdk-demo-go/pkg/services/search"
 	"sdk-demo-go/pkg/services/signature"
+	"sdk-demo-go/pkg/services/storage"
 
 	"github.com/gotomicro/ego/client/ehttp"
 )
@@ -11,17 +12,20 @@ import (
 type Services struct {
 	// SignatureService handles signature generation
 	SignatureService *signature.SignatureService
-	// AwosService handles object storage operations
-	AwosService *awos.AwosService
+	// Storage handles object storage operations (S3 or local filesystem)
+	Storage storage.Storage
 	// InspectHttp is the HTTP client for inspection service
 	InspectHttp *ehttp.Component
+	// Search is the full-text index over the plain text of collaborative files
+	Search *search.Index
 }
 
 // NewServices creates and initializes a new Services instance
 func NewServices() *Services {
 	return &Services{
 		SignatureService: signature.Init(),
-		AwosService:      awos.Init(),
+		Storage:          newStorage(),
 		InspectHttp:      ehttp.Load("frontInspect.http").Build(),
+		Search:           search.NewIndex(),
 	}
 }
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/services/awos"
+	"sdk-demo-go/pkg/services/localfs"
+	"sdk-demo-go/pkg/services/storage"
+)
+
+const (
+	// StorageDriverS3 stores objects in S3/MinIO as configured in the awos section
+	StorageDriverS3 = "s3"
+	// StorageDriverLocal stores objects on the local disk and serves signed URLs from the demo itself
+	StorageDriverLocal = "local"
+)
+
+// newStorage builds the storage backend selected by storage.driver, defaulting to S3
+func newStorage() storage.Storage {
+	switch driver := econf.GetString("storage.driver"); driver {
+	case "", StorageDriverS3:
+		return awos.Init()
+	case StorageDriverLocal:
+		s, err := localfs.New(localfs.Config{
+			Root:    econf.GetString("storage.local.root"),
+			BaseURL: econf.GetString("storage.local.baseUrl"),
+			Secret:  localStorageSecret(),
+		})
+		if err != nil {
+			elog.Panic("init local storage failed", l.E(err))
+		}
+		return s
+	default:
+		elog.Panic("unknown storage driver", l.S("driver", driver))
+		return nil
+	}
+}
+
+// storageSecretLabel separates the key derived for storage URLs from the JWT secret it is derived from
+const storageSecretLabel = "sdk-demo-go/storage-url-signing"
+
+// localStorageSecret returns the key used to sign local storage URLs
+// Without a configured secret one is derived from jwt.secret, so a signed URL can never be used as a JWT
+// signature or the other way round; without either a random one is used, so URLs only stay valid until restart
+func localStorageSecret() string {
+	if secret := econf.GetString("storage.local.secret"); secret != "" {
+		return secret
+	}
+	if secret := econf.GetString("jwt.secret"); secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(storageSecretLabel))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	elog.Warn("storage.local.secret is not set, signed storage urls will not survive a restart")
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
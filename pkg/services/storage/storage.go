@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the requested object does not exist
+	ErrNotFound = errors.New("object not found")
+	// ErrPartSizeMismatch is returned when a part body is shorter or longer than announced
+	ErrPartSizeMismatch = errors.New("part size mismatch")
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	// Size is the object size in bytes
+	Size int64
+	// ModTime is the last modification time of the object
+	ModTime time.Time
+	// ETag is the quoted entity tag of the object
+	ETag string
+}
+
+// Part identifies an uploaded part of a multipart upload
+type Part struct {
+	// Number is the 1-based part number
+	Number int64
+	// ETag is the entity tag returned when the part was uploaded
+	ETag string
+}
+
+// Storage is the object storage backend used for uploaded and imported files
+type Storage interface {
+	// Save stores data under the specified key
+	Save(key string, data []byte) error
+	// Upload streams the content of r into the object stored under the specified key
+	Upload(key string, r io.Reader) error
+	// Get retrieves the data stored under the specified key
+	Get(key string) ([]byte, error)
+	// Stat returns the metadata of the object stored under the specified key
+	Stat(key string) (ObjectInfo, error)
+	// OpenRange streams the object from offset, a negative length reads to the end
+	OpenRange(key string, offset, length int64) (io.ReadCloser, error)
+	// Remove deletes the object stored under the specified key
+	Remove(key string) error
+	// CreateMultipart starts a multipart upload for the specified key and returns its upload ID
+	CreateMultipart(key string) (string, error)
+	// UploadPart streams a numbered part of exactly size bytes and returns its ETag
+	UploadPart(key, uploadId string, number int64, r io.Reader, size int64) (string, error)
+	// CompleteMultipart assembles the parts, in the given order, into the object
+	CompleteMultipart(key, uploadId string, parts []Part) error
+	// AbortMultipart discards a multipart upload and every part uploaded so far
+	AbortMultipart(key, uploadId string) error
+	// GetUploadURL creates an upload URL valid for the given number of seconds
+	GetUploadURL(key string, expireSeconds int64) (string, error)
+	// GetDownloadURL creates a publicly reachable download URL valid for the given number of seconds
+	GetDownloadURL(key string, filename string, expireSeconds int64) (string, error)
+}
+
+// ObjectReader adapts a stored object to io.ReadSeeker so it can be passed to http.ServeContent
+// Seeking is free, the object is only opened (from the current offset) on the next Read
+type ObjectReader struct {
+	s      Storage
+	key    string
+	size   int64
+	offset int64
+	rc     io.ReadCloser
+}
+
+// NewObjectReader creates an ObjectReader for the object of the given size
+func NewObjectReader(s Storage, key string, size int64) *ObjectReader {
+	return &ObjectReader{s: s, key: key, size: size}
+}
+
+// Read reads from the current offset, opening the object lazily
+func (r *ObjectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.rc == nil {
+		rc, err := r.s.OpenRange(r.key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.rc = rc
+	}
+	n, err := r.rc.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Seek moves the offset, dropping the open stream if the position changes
+func (r *ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	if abs != r.offset {
+		r.closeStream()
+		r.offset = abs
+	}
+	return abs, nil
+}
+
+// Close releases the underlying stream
+func (r *ObjectReader) Close() error {
+	return r.closeStream()
+}
+
+func (r *ObjectReader) closeStream() error {
+	if r.rc == nil {
+		return nil
+	}
+	err := r.rc.Close()
+	r.rc = nil
+	return err
+}
@@ -0,0 +1,63 @@
+package storage_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sdk-demo-go/pkg/services/localfs"
+	"sdk-demo-go/pkg/services/storage"
+)
+
+func TestObjectReader_ServeContent(t *testing.T) {
+	s, err := localfs.New(localfs.Config{Root: t.TempDir(), Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Save("file-guid", []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := s.Stat("file-guid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		header     map[string]string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "full", wantStatus: http.StatusOK, wantBody: "0123456789"},
+		{name: "range", header: map[string]string{"Range": "bytes=2-5"}, wantStatus: http.StatusPartialContent, wantBody: "2345"},
+		{name: "suffix range", header: map[string]string{"Range": "bytes=-3"}, wantStatus: http.StatusPartialContent, wantBody: "789"},
+		{name: "unsatisfiable range", header: map[string]string{"Range": "bytes=20-"}, wantStatus: http.StatusRequestedRangeNotSatisfiable},
+		{name: "etag match", header: map[string]string{"If-None-Match": info.ETag}, wantStatus: http.StatusNotModified},
+		{name: "etag mismatch", header: map[string]string{"If-None-Match": `"other"`}, wantStatus: http.StatusOK, wantBody: "0123456789"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			rec.Header().Set("ETag", info.ETag)
+
+			content := storage.NewObjectReader(s, "file-guid", info.Size)
+			http.ServeContent(rec, req, "file.txt", info.ModTime, content)
+			content.Close()
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" {
+				body, _ := io.ReadAll(rec.Body)
+				if string(body) != tt.wantBody {
+					t.Errorf("body = %q, want %q", body, tt.wantBody)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Package stream fans out server-side updates (job progress, API test progress, new events)
+// to the /api/stream connections of the user they belong to.
+// Delivery is best effort and in-process: a subscriber that falls behind loses messages
+// and can always fall back to the polling endpoints.
+package stream
+
+import (
+	"sync"
+)
+
+const (
+	// TypeJob carries a db.Job whenever a background job has been polled
+	TypeJob = "job"
+	// TypeApiTest carries the progress of an API test run
+	TypeApiTest = "apiTest"
+	// TypeEvent carries a newly stored db.Event
+	TypeEvent = "event"
+	// TypeNotification carries a new db.Notification for its recipient
+	TypeNotification = "notification"
+)
+
+// bufferSize is the number of messages queued per subscriber before messages are dropped
+const bufferSize = 64
+
+// Message is a single update pushed to subscribers
+type Message struct {
+	// Type is the message type, used as the SSE event name
+	Type string `json:"type"`
+	// Data is the JSON encoded payload
+	Data interface{} `json:"data"`
+}
+
+// Subscription receives the messages published to one user
+type Subscription struct {
+	// C delivers the messages, it is closed by Close
+	C      <-chan Message
+	c      chan Message
+	userId int64
+	once   sync.Once
+}
+
+var (
+	mu   sync.RWMutex
+	subs = map[int64]map[*Subscription]struct{}{}
+)
+
+// Subscribe registers a subscription for the user's messages
+func Subscribe(userId int64) *Subscription {
+	c := make(chan Message, bufferSize)
+	s := &Subscription{C: c, c: c, userId: userId}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if subs[userId] == nil {
+		subs[userId] = map[*Subscription]struct{}{}
+	}
+	subs[userId][s] = struct{}{}
+	return s
+}
+
+// Close unregisters the subscription and closes its channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(subs[s.userId], s)
+		if len(subs[s.userId]) == 0 {
+			delete(subs, s.userId)
+		}
+		close(s.c)
+	})
+}
+
+// Publish sends a message to every subscription of the user without blocking
+func Publish(userId int64, typ string, data interface{}) {
+	msg := Message{Type: typ, Data: data}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for s := range subs[userId] {
+		select {
+		case s.c <- msg:
+		default:
+			// The subscriber is not keeping up, drop the message
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package stream
+
+import (
+	"testing"
+)
+
+func TestPublish(t *testing.T) {
+	a := Subscribe(1)
+	b := Subscribe(2)
+	defer b.Close()
+
+	Publish(1, TypeJob, "a")
+	Publish(2, TypeEvent, "b")
+	Publish(3, TypeEvent, "nobody")
+
+	tests := []struct {
+		name string
+		sub  *Subscription
+		want Message
+	}{
+		{"user 1", a, Message{Type: TypeJob, Data: "a"}},
+		{"user 2", b, Message{Type: TypeEvent, Data: "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			select {
+			case got := <-tt.sub.C:
+				if got != tt.want {
+					t.Errorf("got %+v, want %+v", got, tt.want)
+				}
+			default:
+				t.Fatal("no message received")
+			}
+			select {
+			case got := <-tt.sub.C:
+				t.Errorf("unexpected message %+v", got)
+			default:
+			}
+		})
+	}
+
+	a.Close()
+	a.Close()
+	if _, ok := <-a.C; ok {
+		t.Error("channel still open after Close")
+	}
+	Publish(1, TypeJob, "after close")
+}
+
+func TestPublishDropsWhenFull(t *testing.T) {
+	s := Subscribe(1)
+	defer s.Close()
+
+	for i := 0; i < bufferSize+10; i++ {
+		Publish(1, TypeApiTest, i)
+	}
+	if got := len(s.C); got != bufferSize {
+		t.Errorf("queued %d messages, want %d", got, bufferSize)
+	}
+	if got := (<-s.C).Data; got != 0 {
+		t.Errorf("first message %v, want 0", got)
+	}
+}
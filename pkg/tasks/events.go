@@ -0,0 +1,134 @@
+package tasks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+)
+
+// eventPurgeBatch is how many expired events are archived and deleted at a time
+const eventPurgeBatch = 1000
+
+// retention holds the [events] retention policy, a zero duration keeps events forever
+type retention struct {
+	// def applies to the event types without their own entry
+	def time.Duration
+	// byType is keyed by the lower-cased event type
+	byType map[string]time.Duration
+}
+
+func loadRetention() retention {
+	r := retention{
+		def:    econf.GetDuration("events.retention"),
+		byType: map[string]time.Duration{},
+	}
+	for typ, v := range econf.GetStringMapString("events.retentionByType") {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			elog.Warn("invalid event retention", l.S("type", typ), l.S("retention", v))
+			continue
+		}
+		r.byType[strings.ToLower(typ)] = d
+	}
+	return r
+}
+
+// of returns the retention of an event type
+func (r retention) of(eventType string) time.Duration {
+	if d, ok := r.byType[strings.ToLower(eventType)]; ok {
+		return d
+	}
+	return r.def
+}
+
+// PurgeEvents deletes the events older than the retention of their type, archiving them
+// to object storage first when events.archive is enabled
+func PurgeEvents() error {
+	r := loadRetention()
+	types, err := db.FindEventTypes(invoker.DB)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, typ := range types {
+		d := r.of(typ)
+		if d <= 0 {
+			continue
+		}
+		if err = purgeEventType(typ, now.Add(-d).Unix()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeEventType purges the events of one type created before the cutoff, oldest first
+func purgeEventType(eventType string, before int64) error {
+	var purged int
+	for {
+		rows, err := db.FindExpiredEvents(invoker.DB, eventType, before, eventPurgeBatch)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		if econf.GetBool("events.archive") {
+			if err = archiveEvents(eventType, rows); err != nil {
+				return err
+			}
+		}
+
+		ids := make([]int64, len(rows))
+		for i := range rows {
+			ids[i] = rows[i].ID
+		}
+		if err = db.PurgeEvents(invoker.DB, ids); err != nil {
+			return err
+		}
+		purged += len(rows)
+		if len(rows) < eventPurgeBatch {
+			break
+		}
+	}
+	if purged > 0 {
+		elog.Info("expired events purged", l.S("type", eventType), l.I("count", purged))
+	}
+	return nil
+}
+
+// archiveEvents stores the rows as one gzip-compressed JSON Lines object under
+// <events.archivePrefix>/<yyyy>/<mm>/<dd>/<type>-<first id>-<last id>.jsonl.gz
+func archiveEvents(eventType string, rows []db.Event) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for i := range rows {
+		if err := enc.Encode(&rows[i]); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	prefix := econf.GetString("events.archivePrefix")
+	if prefix == "" {
+		prefix = "archive/events"
+	}
+	name := fmt.Sprintf("%s-%d-%d.jsonl.gz", strings.ReplaceAll(eventType, "/", "_"), rows[0].ID, rows[len(rows)-1].ID)
+	key := path.Join(prefix, time.Now().UTC().Format("2006/01/02"), name)
+	return invoker.Services.Storage.Save(key, buf.Bytes())
+}
@@ -0,0 +1,81 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/elog"
+	sdkapi "github.com/shimo-open/sdk-kit-go/api"
+	"gorm.io/gorm"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/utils"
+)
+
+// searchLoadBatch is how many stored texts are read at a time when the index is rebuilt
+const searchLoadBatch = 500
+
+// LoadSearchIndex rebuilds the search index from the stored plain text of the files
+func LoadSearchIndex() error {
+	var afterId int64
+	for {
+		texts, err := db.FindFileTexts(invoker.DB, afterId, searchLoadBatch)
+		if err != nil {
+			return err
+		}
+		for _, t := range texts {
+			invoker.Services.Search.Add(t.FileGuid, t.Content)
+		}
+		if len(texts) < searchLoadBatch {
+			break
+		}
+		afterId = texts[len(texts)-1].ID
+	}
+	elog.Info("search index loaded", l.I("files", invoker.Services.Search.Len()))
+	return nil
+}
+
+// IndexPendingFiles fetches the plain text of the files queued by FileContent events and (re)indexes it.
+// Queuing debounces the events, a file edited many times between two runs is fetched once.
+// Files that could not be indexed are queued again for the next run
+func IndexPendingFiles() error {
+	pending := invoker.Services.Search.Pending()
+	for i, guid := range pending {
+		file, err := db.FindFileByGuid(invoker.DB, guid)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			requeueSearch(pending[i:])
+			return err
+		}
+		if file.IsShimoFile != 1 || file.IsFolder {
+			continue
+		}
+
+		res, err := invoker.SdkMgr.GetPlainText(context.Background(), sdkapi.GetPlainTextReq{
+			FileID:   file.Guid,
+			Metadata: utils.GetAuth(file.CreatorId),
+		})
+		if err != nil {
+			elog.Warn("get plain text failed", l.S("fileGuid", file.Guid), l.E(err))
+			invoker.Services.Search.Queue(file.Guid)
+			continue
+		}
+		if err = db.SaveFileText(invoker.DB, file.Guid, res.Content); err != nil {
+			requeueSearch(pending[i:])
+			return err
+		}
+		invoker.Services.Search.Add(file.Guid, res.Content)
+	}
+	return nil
+}
+
+// requeueSearch queues files again for the next run
+func requeueSearch(guids []string) {
+	for _, guid := range guids {
+		invoker.Services.Search.Queue(guid)
+	}
+}
@@ -0,0 +1,50 @@
+// Package tasks holds the periodic background work of the server, such as
+// garbage-collecting abandoned upload sessions, sending webhook deliveries,
+// purging expired events, emptying the expired recycle bin items and indexing
+// the plain text of edited files
+package tasks
+
+import (
+	"time"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/webhooks"
+)
+
+// Start launches the background tasks, it is meant to run after invoker.Init
+func Start() error {
+	go every("upload session gc", interval("uploads.gcInterval", 10*time.Minute), CleanupUploadSessions)
+	go every("webhook delivery", interval("webhooks.pollInterval", time.Second), webhooks.DeliverDue)
+	go every("event purge", interval("events.purgeInterval", time.Hour), PurgeEvents)
+	go every("trash purge", interval("trash.purgeInterval", time.Hour), PurgeTrash)
+	go func() {
+		if err := LoadSearchIndex(); err != nil {
+			elog.Error("background task failed", l.S("task", "search index load"), l.E(err))
+		}
+		every("search index", interval("search.indexInterval", 30*time.Second), IndexPendingFiles)
+	}()
+	return nil
+}
+
+// every runs fn once per interval for the lifetime of the process
+func every(name string, d time.Duration, fn func() error) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := fn(); err != nil {
+			elog.Error("background task failed", l.S("task", name), l.E(err))
+		}
+	}
+}
+
+// interval reads a duration from config, falling back to def when unset
+func interval(key string, def time.Duration) time.Duration {
+	if d := econf.GetDuration(key); d > 0 {
+		return d
+	}
+	return def
+}
@@ -0,0 +1,40 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/trash"
+)
+
+// trashPurgeBatch is how many expired recycle bin items are purged per run
+const trashPurgeBatch = 100
+
+// PurgeTrash permanently deletes the recycle bin items older than trash.retentionDays,
+// a zero retention keeps them until they are purged by hand
+func PurgeTrash() error {
+	days := econf.GetInt("trash.retentionDays")
+	if days <= 0 {
+		return nil
+	}
+	before := time.Now().AddDate(0, 0, -days).Unix()
+	items, err := db.FindExpiredTrashItems(invoker.DB, before, trashPurgeBatch)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		files, err := trash.Purge(context.Background(), items[i].DeleterId, &items[i])
+		if err != nil {
+			return err
+		}
+		elog.Info("expired trash item purged", l.S("fileGuid", items[i].FileGuid), l.I("files", len(files)))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package tasks
+
+import (
+	"time"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+)
+
+// uploadGCBatch is how many expired sessions are cleaned up per run
+const uploadGCBatch = 100
+
+// CleanupUploadSessions aborts upload sessions that went idle past their expiry,
+// discarding their stored parts
+func CleanupUploadSessions() error {
+	sessions, err := db.FindExpiredUploadSessions(invoker.DB, time.Now().Unix(), uploadGCBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		err = invoker.Services.Storage.AbortMultipart(session.FileGuid, session.UploadId)
+		if err != nil {
+			elog.Warn("abort expired upload failed", l.S("uploadId", session.Guid), l.E(err))
+			continue
+		}
+		ok, err := db.FinishUploadSession(invoker.DB, session.ID, db.UploadSessionExpired)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err = db.RemoveUploadParts(invoker.DB, session.ID); err != nil {
+			return err
+		}
+		elog.Info("expired upload session removed", l.S("uploadId", session.Guid))
+	}
+	return nil
+}
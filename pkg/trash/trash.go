@@ -0,0 +1,51 @@
+// Package trash permanently deletes recycle bin items, for the HTTP handlers and the background purge alike.
+// The rows go first, then the content of the files is removed from the SDK or the object storage and
+// from the search index on behalf of the user who deleted them.
+package trash
+
+import (
+	"context"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/elog"
+	sdkapi "github.com/shimo-open/sdk-kit-go/api"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+	"sdk-demo-go/pkg/utils"
+)
+
+// Purge permanently deletes a file, or a folder with everything deleted with it, and removes their content
+// It returns the purged files
+func Purge(ctx context.Context, userId int64, item *db.TrashItem) ([]db.File, error) {
+	files, err := db.PurgeTrashItem(invoker.DB, item)
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		removeFileContent(ctx, userId, &files[i])
+	}
+	return files, nil
+}
+
+// removeFileContent deletes the content of a file from the SDK or the object storage on behalf of a user,
+// and drops it from the search index. Failures are only logged
+func removeFileContent(ctx context.Context, userId int64, file *db.File) {
+	if file.IsFolder {
+		return
+	}
+	invoker.Services.Search.Remove(file.Guid)
+	if file.IsShimoFile == 1 {
+		params := sdkapi.DeleteFileReq{
+			Metadata: utils.GetAuth(userId),
+			FileID:   file.Guid,
+		}
+		if _, err := invoker.SdkMgr.DeleteFile(ctx, params); err != nil {
+			elog.Warn("file remove failed", l.S("fileGuid", file.Guid), l.E(err))
+		}
+		return
+	}
+	if err := invoker.Services.Storage.Remove(file.Guid); err != nil {
+		elog.Warn("file remove failed", l.S("fileGuid", file.Guid), l.E(err))
+	}
+}
@@ -76,6 +76,8 @@ type UserClaims struct {
 	*jwt.StandardClaims
 	UserId int64  `json:"userId"`
 	Mode   string `json:"mode"`
+	// ShareToken is the share link the token was issued for, empty for regular user tokens
+	ShareToken string `json:"shareToken,omitempty"`
 }
 
 // SignUserJWT issues a user token
@@ -122,6 +124,26 @@ func SignUserJWTWithMode(userId int64, mode string) string {
 	return tokenStr
 }
 
+// SignShareJWT issues a token for a user (sdkapi.Anonymous for guests) who exchanged a share link
+func SignShareJWT(userId int64, shareToken string, expires time.Duration) string {
+	secret := econf.GetString("jwt.secret")
+	token := jwt.NewWithClaims(
+		jwt.SigningMethodHS256, &UserClaims{
+			StandardClaims: &jwt.StandardClaims{
+				ExpiresAt: time.Now().Add(expires).Unix(),
+			},
+			UserId:     userId,
+			ShareToken: shareToken,
+		})
+	token.Header["kid"] = econf.GetString("shimoSDK.appId")
+	tokenStr, err := token.SignedString([]byte(secret))
+	if err != nil {
+		panic(err)
+	}
+
+	return tokenStr
+}
+
 // SDKClaims represents JWT claims for SDK operations
 type SDKClaims struct {
 	*jwt.StandardClaims
@@ -0,0 +1,77 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+)
+
+// lookupTimeout bounds the resolution of the host of an endpoint
+const lookupTimeout = 5 * time.Second
+
+var (
+	// ErrInvalidURL is returned for endpoints that are not absolute http(s) URLs
+	ErrInvalidURL = errors.New("invalid webhook url")
+	// ErrInternalAddress is returned for endpoints on a loopback, private, link-local or unspecified address
+	ErrInternalAddress = errors.New("webhook url must resolve to a public address")
+)
+
+// CheckURL validates an endpoint: an http(s) URL whose host only resolves to public addresses,
+// unless webhooks.allowPrivateAddresses is set
+func CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	if econf.GetBool("webhooks.allowPrivateAddresses") {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return ErrInvalidURL
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return ErrInternalAddress
+		}
+	}
+	return nil
+}
+
+// newClient returns the client deliveries are sent with. Unless private addresses are allowed,
+// every connection is checked once the host is resolved, so that a DNS change or a redirect
+// cannot reach an internal address after the URL was validated
+func newClient(cfg config) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.allowPrivateAddresses {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return ErrInternalAddress
+			}
+			return nil
+		}
+		// A proxy would be the only address checked
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: cfg.timeout, Transport: transport}
+}
+
+// publicIP reports whether an address may be the target of a webhook
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsUnspecified()
+}
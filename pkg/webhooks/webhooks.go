@@ -0,0 +1,202 @@
+// Package webhooks relays the stored callback events to the outbound webhook subscriptions.
+// Deliveries are queued in the webhook_deliveries table when an event is ingested and sent
+// by DeliverDue, retried with exponential backoff and moved to the dead-letter list once
+// every attempt failed.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gotomicro/cetus/l"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+
+	"sdk-demo-go/pkg/invoker"
+	"sdk-demo-go/pkg/models/db"
+)
+
+const (
+	// HeaderEvent carries the event type of a delivery
+	HeaderEvent = "X-Webhook-Event"
+	// HeaderDelivery carries the delivery ID, stable across retries so receivers can deduplicate
+	HeaderDelivery = "X-Webhook-Delivery"
+	// HeaderSignature carries "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// deliveryBatch is how many due deliveries are sent per run
+const deliveryBatch = 20
+
+// config holds the [webhooks] settings
+type config struct {
+	timeout     time.Duration
+	maxAttempts int
+	backoffMin  time.Duration
+	backoffMax  time.Duration
+	// allowPrivateAddresses lets deliveries reach internal addresses, for local development
+	allowPrivateAddresses bool
+}
+
+func loadConfig() config {
+	c := config{
+		timeout:     econf.GetDuration("webhooks.timeout"),
+		maxAttempts: econf.GetInt("webhooks.maxAttempts"),
+		backoffMin:  econf.GetDuration("webhooks.backoffMin"),
+		backoffMax:  econf.GetDuration("webhooks.backoffMax"),
+
+		allowPrivateAddresses: econf.GetBool("webhooks.allowPrivateAddresses"),
+	}
+	if c.timeout <= 0 {
+		c.timeout = 10 * time.Second
+	}
+	if c.maxAttempts <= 0 {
+		c.maxAttempts = 8
+	}
+	if c.backoffMin <= 0 {
+		c.backoffMin = 10 * time.Second
+	}
+	if c.backoffMax < c.backoffMin {
+		c.backoffMax = time.Hour
+	}
+	return c
+}
+
+// DeliverDue sends the deliveries whose next attempt is due
+func DeliverDue() error {
+	cfg := loadConfig()
+	now := time.Now().Unix()
+	due, err := db.FindDueWebhookDeliveries(invoker.DB, now, deliveryBatch)
+	if err != nil {
+		return err
+	}
+
+	// A delivery is owned for twice the request timeout, a crashed worker releases it afterwards
+	lockedUntil := now + int64(2*cfg.timeout/time.Second)
+	client := newClient(cfg)
+	subscriptions := map[int64]*db.WebhookSubscription{}
+	var wg sync.WaitGroup
+	for i := range due {
+		d := &due[i]
+		s, ok := subscriptions[d.SubscriptionId]
+		if !ok {
+			s, err = findSubscription(d.SubscriptionId)
+			if err != nil {
+				return err
+			}
+			subscriptions[d.SubscriptionId] = s
+		}
+
+		ok, err = db.ClaimWebhookDelivery(invoker.DB, d, now, lockedUntil)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempt(cfg, client, s, d)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// findSubscription loads a subscription by ID, nil when it has been deleted
+func findSubscription(id int64) (*db.WebhookSubscription, error) {
+	var subscriptions []db.WebhookSubscription
+	err := invoker.DB.Where("id = ?", id).Limit(1).Find(&subscriptions).Error
+	if err != nil || len(subscriptions) == 0 {
+		return nil, err
+	}
+	return &subscriptions[0], nil
+}
+
+// attempt sends a claimed delivery once and records the outcome
+func attempt(cfg config, client *http.Client, s *db.WebhookSubscription, d *db.WebhookDelivery) {
+	d.Attempts++
+	var err error
+	if s == nil {
+		err = fmt.Errorf("subscription deleted")
+		d.Attempts = cfg.maxAttempts
+	} else {
+		d.ResponseCode, err = send(client, s, d)
+	}
+
+	now := time.Now()
+	switch {
+	case err == nil:
+		d.Status = db.WebhookDeliverySucceeded
+		d.LastError = ""
+		d.DeliveredAt = now.Unix()
+	case d.Attempts >= cfg.maxAttempts:
+		d.Status = db.WebhookDeliveryDead
+		d.LastError = err.Error()
+		elog.Warn("webhook delivery dead", l.I64("deliveryId", d.ID), l.E(err))
+	default:
+		d.Status = db.WebhookDeliveryPending
+		d.LastError = err.Error()
+		d.NextRunAt = now.Add(backoff(cfg, d.Attempts)).Unix()
+	}
+	d.LockedUntil = 0
+
+	if err = db.SaveWebhookDelivery(invoker.DB, d); err != nil {
+		elog.Error("save webhook delivery failed", l.I64("deliveryId", d.ID), l.E(err))
+	}
+}
+
+// send posts the event payload and fails on anything but a 2xx response
+func send(client *http.Client, s *db.WebhookSubscription, d *db.WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(s.Secret, time.Now().Unix(), body))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// Sign builds the signature header value for a body sent at the given time
+func Sign(secret string, timestamp int64, body []byte) string {
+	ts := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff doubles the retry delay with every attempt, from backoffMin up to backoffMax
+func backoff(cfg config, attempts int) time.Duration {
+	d := cfg.backoffMin
+	for i := 1; i < attempts && d < cfg.backoffMax; i++ {
+		d *= 2
+	}
+	if d > cfg.backoffMax {
+		d = cfg.backoffMax
+	}
+	return d
+}